@@ -1,14 +1,29 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/service/address"
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
 	"github.com/gitKashish/ecommerce-api-go/service/cart"
+	"github.com/gitKashish/ecommerce-api-go/service/category"
+	"github.com/gitKashish/ecommerce-api-go/service/coupon"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
+	"github.com/gitKashish/ecommerce-api-go/service/inventory"
+	"github.com/gitKashish/ecommerce-api-go/service/media"
 	"github.com/gitKashish/ecommerce-api-go/service/order"
 	"github.com/gitKashish/ecommerce-api-go/service/product"
+	"github.com/gitKashish/ecommerce-api-go/service/search"
 	"github.com/gitKashish/ecommerce-api-go/service/user"
+	"github.com/gitKashish/ecommerce-api-go/service/variant"
+	"github.com/gitKashish/ecommerce-api-go/service/warehouse"
+	"github.com/gitKashish/ecommerce-api-go/types"
 )
 
 type APIServer struct {
@@ -28,21 +43,104 @@ func (s *APIServer) Run() error {
 	subrouter := http.NewServeMux()
 	subrouter.Handle("/v1/", http.StripPrefix("/v1", router))
 
-	// User handler service
+	// Public token verification keys, at the well-known (unversioned) path.
+	subrouter.HandleFunc("GET /.well-known/jwks.json", auth.HandleJWKS)
+
 	userStore := user.NewStore(s.db)
-	userHandler := user.NewHandler(userStore)
+	productStore := product.NewStore(s.db)
+	transactor := db.NewTransactor(s.db)
+	// Guest carts left unused for `GUEST_CART_TTL_DAYS` are swept in the background.
+	cartStore := cart.NewStore(s.db)
+	if sweepInterval := time.Second * time.Duration(config.Envs.GuestCartSweepIntervalInSeconds); sweepInterval > 0 {
+		go cart.SweepExpiredGuestCarts(context.Background(), cartStore, sweepInterval)
+	}
+	variantStore := variant.NewStore(s.db)
+	// Stock held for carts & unpaid orders, expired reservations are swept in the background.
+	// The same store keeps the stock ledger.
+	inventoryStore := inventory.NewStore(s.db)
+	if sweepInterval := time.Second * time.Duration(config.Envs.ReservationSweepIntervalInSeconds); sweepInterval > 0 {
+		go inventory.SweepExpiredReservations(context.Background(), inventoryStore, sweepInterval)
+	}
+
+	// User handler service, guest carts are merged on login & registration.
+	refreshTokenStore := auth.NewStore(s.db)
+	userHandler := user.NewHandler(userStore, refreshTokenStore, cart.NewMerger(cartStore, productStore, variantStore, transactor))
 	userHandler.RegisterRoutes(router)
 
+	// Shared by mutating endpoints honouring the `Idempotency-Key` header.
+	idempotencyStore := idempotency.NewStore(s.db)
+
+	// Search index, rebuilt from the products on startup & kept in sync by the product handler.
+	// Rebuilt again every `SEARCH_REFRESH_INTERVAL` seconds for changes made by other processes.
+	searchIndex := search.NewIndex()
+	products, err := productStore.GetProducts()
+	if err != nil {
+		return err
+	}
+	searchIndex.Rebuild(products)
+	if refreshInterval := time.Second * time.Duration(config.Envs.SearchRefreshIntervalInSeconds); refreshInterval > 0 {
+		go search.RefreshIndex(context.Background(), searchIndex, productStore, refreshInterval)
+	}
+
+	// Search handler service, `/products/search` is more specific than `/products/{id}` & wins over it.
+	searchHandler := search.NewHandler(searchIndex, productStore)
+	searchHandler.RegisterRoutes(router)
+
 	// Product handler service
-	productStore := product.NewStore(s.db)
-	productHandler := product.NewHandler(productStore)
+	productHandler := product.NewHandler(productStore, userStore, inventoryStore, idempotencyStore, searchIndex, transactor)
 	productHandler.RegisterRoutes(router)
 
+	// Product image handler service, images are stored in the configured blob storage.
+	var storage types.BlobStorage
+	switch config.Envs.MediaStorage {
+	case "local":
+		local := media.NewLocalStorage(config.Envs.MediaDir, config.Envs.PublicHost+"/media")
+		// Stored files are served as is, at the (unversioned) `/media/` path.
+		subrouter.Handle("GET /media/", http.StripPrefix("/media/", local.Handler()))
+		storage = local
+	default:
+		return fmt.Errorf("unknown media storage %q", config.Envs.MediaStorage)
+	}
+	imageHandler := media.NewHandler(media.NewStore(s.db), productStore, storage, userStore, idempotencyStore, transactor)
+	imageHandler.RegisterRoutes(router)
+
+	// Variant (product options) handler service
+	variantHandler := variant.NewHandler(variantStore, productStore, inventoryStore, userStore, idempotencyStore, transactor)
+	variantHandler.RegisterRoutes(router)
+
+	// Category handler service
+	categoryStore := category.NewStore(s.db)
+	categoryHandler := category.NewHandler(categoryStore, userStore, idempotencyStore)
+	categoryHandler.RegisterRoutes(router)
+
+	// Warehouse handler service
+	warehouseStore := warehouse.NewStore(s.db)
+	warehouseHandler := warehouse.NewHandler(warehouseStore, userStore, idempotencyStore)
+	warehouseHandler.RegisterRoutes(router)
+
+	// Inventory (stock ledger) handler service
+	inventoryHandler := inventory.NewHandler(inventoryStore, productStore, warehouseStore, userStore, idempotencyStore)
+	inventoryHandler.RegisterRoutes(router)
+
+	// Address book handler service
+	addressStore := address.NewStore(s.db)
+	addressHandler := address.NewHandler(addressStore, userStore, idempotencyStore)
+	addressHandler.RegisterRoutes(router)
+
+	// Coupon handler service
+	couponStore := coupon.NewStore(s.db)
+	couponHandler := coupon.NewHandler(couponStore, userStore, idempotencyStore)
+	couponHandler.RegisterRoutes(router)
+
 	// Cart handler service
 	orderStore := order.NewStore(s.db)
-	cartHandler := cart.NewHandler(orderStore, userStore, productStore)
+	cartHandler := cart.NewHandler(cartStore, orderStore, userStore, productStore, variantStore, addressStore, couponStore, inventoryStore, warehouseStore, idempotencyStore, transactor)
 	cartHandler.RegisterRoutes(router)
 
+	// Order handler service
+	orderHandler := order.NewHandler(orderStore, userStore, productStore, variantStore, inventoryStore, inventoryStore, warehouseStore, idempotencyStore, transactor)
+	orderHandler.RegisterRoutes(router)
+
 	fmt.Printf("Starting server at %s\n", s.addr)
 	return http.ListenAndServe(s.addr, subrouter)
 }
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/service/inventory"
+	"github.com/gitKashish/ecommerce-api-go/service/product"
+	"github.com/gitKashish/ecommerce-api-go/service/search"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+const usage = `Bulk product import & export.
+
+Usage:
+  catalog import [-format csv|jsonl] [-columns column=field,...] [-dry-run] FILE
+  catalog export [-format csv|jsonl] [FILE]
+
+The format defaults to the file's extension (.csv, .jsonl or .ndjson), exports
+without a file go to stdout as CSV. Import reports are written to stdout, the
+exit status is 1 if any row failed. A running API shows imported products in
+search after its next index refresh (SEARCH_REFRESH_INTERVAL seconds).
+`
+
+func main() {
+	log.SetFlags(0)
+	types.SetDefaultCurrency(config.Envs.Currency)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "import":
+		runImport(os.Args[2:])
+	case "export":
+		runExport(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "file format, csv or jsonl")
+	columns := flags.String("columns", "", `column mapping, e.g. "title=name,cost=price,notes=-"`)
+	dryRun := flags.Bool("dry-run", false, "report what the import would do without saving anything")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal("import: expected a single file")
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = formatOf(path)
+	}
+	mapping, err := product.ParseColumnMapping(*columns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	records, err := product.NewRecordReader(*format, file, mapping)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := newHandler().ImportProducts(context.Background(), records, product.ImportOptions{DryRun: *dryRun})
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "", "file format, csv or jsonl")
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		log.Fatal("export: expected at most one file")
+	}
+
+	var out io.Writer = os.Stdout
+	if path := flags.Arg(0); path != "" {
+		if *format == "" {
+			*format = formatOf(path)
+		}
+		file, err := os.Create(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if *format == "" {
+		*format = types.ProductFileCSV
+	}
+
+	records, err := product.NewRecordWriter(*format, out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := newHandler().ExportProducts(context.Background(), records); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// The format of a file by its extension, empty if unknown.
+func formatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return types.ProductFileCSV
+	case ".jsonl", ".ndjson":
+		return types.ProductFileJSONL
+	}
+	return ""
+}
+
+// Product handler on the configured DB, its routes aren't served.
+func newHandler() *product.Handler {
+	// Creating a new DB instance with configs from `config.Env`.
+	database, err := db.NewMySQLStorage(mysql.Config{
+		User:                 config.Envs.DBUser,
+		Passwd:               config.Envs.DBPassword,
+		Addr:                 config.Envs.DBAdress,
+		DBName:               config.Envs.DBName,
+		Net:                  "tcp",
+		AllowNativePasswords: true,
+		ParseTime:            true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := database.Ping(); err != nil {
+		log.Fatal(err)
+	}
+
+	// A running API keeps its own search index, it picks up imported
+	// products on its next refresh (`SEARCH_REFRESH_INTERVAL`).
+	return product.NewHandler(product.NewStore(database), nil, inventory.NewStore(database), nil, search.NewIndex(), db.NewTransactor(database))
+}
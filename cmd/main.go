@@ -7,10 +7,15 @@ import (
 	"github.com/gitKashish/ecommerce-api-go/cmd/api"
 	"github.com/gitKashish/ecommerce-api-go/config"
 	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/types"
 	"github.com/go-sql-driver/mysql"
 )
 
 func main() {
+	// Prices & totals are in the configured currency.
+	types.SetDefaultCurrency(config.Envs.Currency)
+
 	// Creating a new DB instance with configs from `config.Env`.
 	db, err := db.NewMySQLStorage(mysql.Config{
 		User:                 config.Envs.DBUser,
@@ -28,6 +33,11 @@ func main() {
 	// Establishing Connecting with DB.
 	initStorage(db)
 
+	// Loading JWT signing keys, failing fast on a broken key configuration.
+	if err := auth.LoadKeys(); err != nil {
+		log.Fatal(err)
+	}
+
 	// Creating an Starting a new HTTP server.
 	server := api.NewAPIServer(":8080", db)
 	err = server.Run()
@@ -23,6 +23,8 @@ func main() {
 		Net:                  "tcp",
 		AllowNativePasswords: true,
 		ParseTime:            true,
+		// Some migration files hold more than one statement.
+		MultiStatements: true,
 	}
 
 	// initiating a new DB Instance (Handle).
@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN `role` ENUM ('customer', 'admin') NOT NULL DEFAULT 'customer';
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE `order_items`
+    ADD COLUMN `productName` VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN `productImage` VARCHAR(255) NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,21 @@
+ALTER TABLE orders
+    MODIFY `status` ENUM ('pending', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelled', 'refunded', 'completed') NOT NULL DEFAULT 'pending';
+
+UPDATE orders SET `status` = 'delivered' WHERE `status` = 'completed';
+
+ALTER TABLE orders
+    MODIFY `status` ENUM ('pending', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelled', 'refunded') NOT NULL DEFAULT 'pending';
+
+CREATE TABLE IF NOT EXISTS `order_status_history` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `orderId` INT UNSIGNED NOT NULL,
+    `fromStatus` VARCHAR(16) NOT NULL DEFAULT '',
+    `toStatus` VARCHAR(16) NOT NULL,
+    `changedBy` INT UNSIGNED NULL,
+    `reason` VARCHAR(255) NOT NULL DEFAULT '',
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY (`orderId`),
+    FOREIGN KEY (`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY (`changedBy`) REFERENCES users(`id`)
+);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP COLUMN `billingAddress`;
+
+DROP TABLE IF EXISTS `addresses`;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE orders DROP COLUMN `discount`, DROP COLUMN `subtotal`;
+
+DROP TABLE IF EXISTS `order_discounts`;
+DROP TABLE IF EXISTS `coupon_products`;
+DROP TABLE IF EXISTS `coupons`;
\ No newline at end of file
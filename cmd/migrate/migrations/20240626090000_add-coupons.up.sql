@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS `coupons` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `code` VARCHAR(64) NOT NULL,
+    `description` VARCHAR(255) NOT NULL DEFAULT '',
+    `type` ENUM ('percentage', 'fixed') NOT NULL,
+    `percentOff` TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    `amountOff` DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    `minSpend` DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    `usageLimit` INT UNSIGNED NULL,
+    `perUserLimit` INT UNSIGNED NULL,
+    `startsAt` TIMESTAMP NULL,
+    `endsAt` TIMESTAMP NULL,
+    `stackable` BOOLEAN NOT NULL DEFAULT FALSE,
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY (`code`)
+);
+
+CREATE TABLE IF NOT EXISTS `coupon_products` (
+    `couponId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`couponId`, `productId`),
+    FOREIGN KEY (`couponId`) REFERENCES coupons(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`productId`) REFERENCES products(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `order_discounts` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `orderId` INT UNSIGNED NOT NULL,
+    `couponId` INT UNSIGNED NOT NULL,
+    `code` VARCHAR(64) NOT NULL,
+    `amount` DECIMAL(10, 2) NOT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY (`orderId`),
+    KEY (`couponId`),
+    FOREIGN KEY (`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY (`couponId`) REFERENCES coupons(`id`)
+);
+
+ALTER TABLE orders
+    ADD COLUMN `subtotal` DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `userId`,
+    ADD COLUMN `discount` DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `subtotal`;
+
+UPDATE orders SET `subtotal` = `total`;
\ No newline at end of file
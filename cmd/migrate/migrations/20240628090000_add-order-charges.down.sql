@@ -0,0 +1 @@
+ALTER TABLE orders DROP COLUMN `tax`, DROP COLUMN `shipping`;
\ No newline at end of file
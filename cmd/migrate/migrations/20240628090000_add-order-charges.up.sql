@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    ADD COLUMN `shipping` DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `discount`,
+    ADD COLUMN `tax` DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `shipping`;
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS `carts` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `userId` INT UNSIGNED NOT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY (`userId`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `cart_items` (
+    `cartId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+    `priceAtAdd` DECIMAL(10, 2) NOT NULL,
+    `addedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`cartId`, `productId`),
+    FOREIGN KEY (`cartId`) REFERENCES carts(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
\ No newline at end of file
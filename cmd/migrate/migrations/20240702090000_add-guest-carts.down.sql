@@ -0,0 +1,6 @@
+DELETE FROM carts WHERE `userId` IS NULL;
+
+ALTER TABLE carts
+    DROP INDEX `tokenHash`,
+    DROP COLUMN `tokenHash`,
+    MODIFY `userId` INT UNSIGNED NOT NULL;
\ No newline at end of file
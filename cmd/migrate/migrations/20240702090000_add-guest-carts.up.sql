@@ -0,0 +1,4 @@
+ALTER TABLE carts
+    MODIFY `userId` INT UNSIGNED NULL,
+    ADD COLUMN `tokenHash` CHAR(64) NULL AFTER `userId`,
+    ADD UNIQUE KEY (`tokenHash`);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE orders
+    DROP COLUMN `stockCommitted`;
+
+DROP TABLE IF EXISTS `stock_reservations`;
\ No newline at end of file
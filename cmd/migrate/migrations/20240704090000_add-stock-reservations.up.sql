@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS `stock_reservations` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `productId` INT UNSIGNED NOT NULL,
+    `cartId` INT UNSIGNED NULL,
+    `orderId` INT UNSIGNED NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+    `expiresAt` TIMESTAMP NOT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY (`productId`, `expiresAt`),
+    KEY (`expiresAt`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`cartId`) REFERENCES carts(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`orderId`) REFERENCES orders(`id`) ON DELETE CASCADE
+);
+
+-- Stock of existing orders was taken at checkout.
+ALTER TABLE orders
+    ADD COLUMN `stockCommitted` BOOLEAN NOT NULL DEFAULT TRUE AFTER `status`;
\ No newline at end of file
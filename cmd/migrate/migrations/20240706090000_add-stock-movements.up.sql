@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS `stock_movements` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `productId` INT UNSIGNED NOT NULL,
+    `type` ENUM ('sale', 'restock', 'adjustment', 'return', 'import') NOT NULL,
+    `delta` INT NOT NULL,
+    `balance` INT UNSIGNED NOT NULL,
+    `orderId` INT UNSIGNED NULL,
+    `actorId` INT UNSIGNED NULL,
+    `reason` VARCHAR(255) NOT NULL DEFAULT '',
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY (`productId`, `id`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY (`actorId`) REFERENCES users(`id`)
+);
+
+-- Opening balances, the ledger starts from the current stock.
+INSERT INTO stock_movements (productId, type, delta, balance, reason)
+SELECT id, 'import', quantity, quantity, 'opening balance' FROM products;
\ No newline at end of file
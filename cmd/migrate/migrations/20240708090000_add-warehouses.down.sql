@@ -0,0 +1,14 @@
+ALTER TABLE order_items
+    DROP FOREIGN KEY `order_items_warehouse`,
+    DROP COLUMN `warehouseId`;
+
+ALTER TABLE stock_reservations
+    DROP FOREIGN KEY `stock_reservations_warehouse`,
+    DROP COLUMN `warehouseId`;
+
+ALTER TABLE stock_movements
+    DROP FOREIGN KEY `stock_movements_warehouse`,
+    DROP COLUMN `warehouseId`;
+
+DROP TABLE IF EXISTS `warehouse_stock`;
+DROP TABLE IF EXISTS `warehouses`;
\ No newline at end of file
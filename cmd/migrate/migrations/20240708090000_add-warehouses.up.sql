@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS `warehouses` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `code` VARCHAR(32) NOT NULL,
+    `name` VARCHAR(255) NOT NULL,
+    `priority` INT UNSIGNED NOT NULL DEFAULT 0,
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY (`code`)
+);
+
+-- Existing stock is all held at the default warehouse.
+INSERT INTO warehouses (id, code, name) VALUES (1, 'main', 'Main warehouse');
+
+CREATE TABLE IF NOT EXISTS `warehouse_stock` (
+    `warehouseId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`warehouseId`, `productId`),
+    KEY (`productId`),
+    FOREIGN KEY (`warehouseId`) REFERENCES warehouses(`id`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
+
+INSERT INTO warehouse_stock (warehouseId, productId, quantity)
+SELECT 1, id, quantity FROM products;
+
+ALTER TABLE stock_movements
+    ADD COLUMN `warehouseId` INT UNSIGNED NOT NULL DEFAULT 1 AFTER `productId`,
+    ADD CONSTRAINT `stock_movements_warehouse` FOREIGN KEY (`warehouseId`) REFERENCES warehouses(`id`);
+
+-- Only orders reserve stock at a warehouse, carts reserve it across all of them.
+ALTER TABLE stock_reservations
+    ADD COLUMN `warehouseId` INT UNSIGNED NULL AFTER `orderId`,
+    ADD CONSTRAINT `stock_reservations_warehouse` FOREIGN KEY (`warehouseId`) REFERENCES warehouses(`id`);
+
+ALTER TABLE order_items
+    ADD COLUMN `warehouseId` INT UNSIGNED NULL AFTER `productId`,
+    ADD CONSTRAINT `order_items_warehouse` FOREIGN KEY (`warehouseId`) REFERENCES warehouses(`id`);
+
+UPDATE order_items SET `warehouseId` = 1;
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `coupon_categories`;
+DROP TABLE IF EXISTS `product_tags`;
+DROP TABLE IF EXISTS `product_categories`;
+DROP TABLE IF EXISTS `categories`;
\ No newline at end of file
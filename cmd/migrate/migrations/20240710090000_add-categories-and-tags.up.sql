@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS `categories` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `parentId` INT UNSIGNED NULL,
+    `name` VARCHAR(255) NOT NULL,
+    `slug` VARCHAR(128) NOT NULL,
+    `description` TEXT NOT NULL,
+    `position` INT UNSIGNED NOT NULL DEFAULT 0,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY (`slug`),
+    KEY (`parentId`),
+    -- Categories with children can't be deleted, their children would be orphaned.
+    FOREIGN KEY (`parentId`) REFERENCES categories(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `product_categories` (
+    `productId` INT UNSIGNED NOT NULL,
+    `categoryId` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`productId`, `categoryId`),
+    KEY (`categoryId`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`categoryId`) REFERENCES categories(`id`) ON DELETE CASCADE
+);
+
+-- Tags are free-form, stored lower case.
+CREATE TABLE IF NOT EXISTS `product_tags` (
+    `productId` INT UNSIGNED NOT NULL,
+    `tag` VARCHAR(64) NOT NULL,
+    PRIMARY KEY (`productId`, `tag`),
+    KEY (`tag`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
+
+-- No cascade: deleting a category would silently change what its coupons apply to.
+CREATE TABLE IF NOT EXISTS `coupon_categories` (
+    `couponId` INT UNSIGNED NOT NULL,
+    `categoryId` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`couponId`, `categoryId`),
+    KEY (`categoryId`),
+    FOREIGN KEY (`couponId`) REFERENCES coupons(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`categoryId`) REFERENCES categories(`id`)
+);
\ No newline at end of file
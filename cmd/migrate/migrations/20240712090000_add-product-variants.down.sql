@@ -0,0 +1,25 @@
+ALTER TABLE order_items
+    DROP FOREIGN KEY `order_items_variant`,
+    DROP COLUMN `sku`,
+    DROP COLUMN `variantId`;
+
+ALTER TABLE stock_movements
+    DROP FOREIGN KEY `stock_movements_variant`,
+    DROP COLUMN `variantId`;
+
+ALTER TABLE stock_reservations
+    DROP FOREIGN KEY `stock_reservations_variant`,
+    DROP COLUMN `variantId`;
+
+-- Lines of different variants of a product would share the primary key.
+DELETE FROM cart_items WHERE `variantId` <> 0;
+
+ALTER TABLE cart_items
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (`cartId`, `productId`),
+    DROP COLUMN `variantId`;
+
+DROP TABLE IF EXISTS `variant_option_values`;
+DROP TABLE IF EXISTS `product_variants`;
+DROP TABLE IF EXISTS `product_option_values`;
+DROP TABLE IF EXISTS `product_options`;
\ No newline at end of file
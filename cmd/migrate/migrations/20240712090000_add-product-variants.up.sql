@@ -0,0 +1,63 @@
+CREATE TABLE IF NOT EXISTS `product_options` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `productId` INT UNSIGNED NOT NULL,
+    `name` VARCHAR(64) NOT NULL,
+    `position` INT UNSIGNED NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY (`productId`, `name`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `product_option_values` (
+    `optionId` INT UNSIGNED NOT NULL,
+    `value` VARCHAR(64) NOT NULL,
+    `position` INT UNSIGNED NOT NULL DEFAULT 0,
+    PRIMARY KEY (`optionId`, `value`),
+    FOREIGN KEY (`optionId`) REFERENCES product_options(`id`) ON DELETE CASCADE
+);
+
+-- `price` NULL means the product's price, `image` empty the product's image.
+CREATE TABLE IF NOT EXISTS `product_variants` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `productId` INT UNSIGNED NOT NULL,
+    `sku` VARCHAR(64) NOT NULL,
+    `price` DECIMAL(10, 2) NULL,
+    `image` VARCHAR(255) NOT NULL DEFAULT '',
+    `quantity` INT UNSIGNED NOT NULL DEFAULT 0,
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY (`sku`),
+    KEY (`productId`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
+
+-- Values in use are checked by the store before options change.
+CREATE TABLE IF NOT EXISTS `variant_option_values` (
+    `variantId` INT UNSIGNED NOT NULL,
+    `optionId` INT UNSIGNED NOT NULL,
+    `value` VARCHAR(64) NOT NULL,
+    PRIMARY KEY (`variantId`, `optionId`),
+    KEY (`optionId`, `value`),
+    FOREIGN KEY (`variantId`) REFERENCES product_variants(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`optionId`, `value`) REFERENCES product_option_values(`optionId`, `value`) ON DELETE CASCADE
+);
+
+-- Products without variants keep `variantId` 0 in carts, the primary key can't hold NULLs.
+ALTER TABLE cart_items
+    ADD COLUMN `variantId` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `productId`,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (`cartId`, `productId`, `variantId`);
+
+ALTER TABLE stock_reservations
+    ADD COLUMN `variantId` INT UNSIGNED NULL AFTER `productId`,
+    ADD CONSTRAINT `stock_reservations_variant` FOREIGN KEY (`variantId`) REFERENCES product_variants(`id`) ON DELETE CASCADE;
+
+ALTER TABLE stock_movements
+    ADD COLUMN `variantId` INT UNSIGNED NULL AFTER `productId`,
+    ADD CONSTRAINT `stock_movements_variant` FOREIGN KEY (`variantId`) REFERENCES product_variants(`id`);
+
+ALTER TABLE order_items
+    ADD COLUMN `variantId` INT UNSIGNED NULL AFTER `productId`,
+    ADD COLUMN `sku` VARCHAR(64) NOT NULL DEFAULT '' AFTER `variantId`,
+    ADD CONSTRAINT `order_items_variant` FOREIGN KEY (`variantId`) REFERENCES product_variants(`id`);
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- Files live in blob storage under `key`, rows only describe them.
+CREATE TABLE IF NOT EXISTS `product_images` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `productId` INT UNSIGNED NOT NULL,
+    `position` INT UNSIGNED NOT NULL DEFAULT 0,
+    `alt` VARCHAR(255) NOT NULL DEFAULT '',
+    `contentType` VARCHAR(32) NOT NULL,
+    `width` INT UNSIGNED NOT NULL,
+    `height` INT UNSIGNED NOT NULL,
+    `key` VARCHAR(255) NOT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY (`productId`, `position`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- Products are matched by SKU on import, NULL for products without one.
+-- Separate from the SKUs of variants (`product_variants.sku`).
+ALTER TABLE products
+    ADD COLUMN `sku` VARCHAR(64) NULL,
+    ADD UNIQUE KEY `sku` (`sku`);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE carts
+    DROP INDEX `expiresAt`,
+    DROP COLUMN `expiresAt`;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- Guest carts expire `GUEST_CART_TTL_DAYS` after their last use, NULL for user carts.
+ALTER TABLE carts
+    ADD COLUMN `expiresAt` TIMESTAMP NULL DEFAULT NULL AFTER `tokenHash`,
+    ADD KEY (`expiresAt`);
+UPDATE carts SET `expiresAt` = `updatedAt` + INTERVAL 30 DAY WHERE `userId` IS NULL;
\ No newline at end of file
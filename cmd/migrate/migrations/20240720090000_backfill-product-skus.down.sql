@@ -0,0 +1 @@
+UPDATE products SET `sku` = NULL WHERE `sku` = CONCAT('P-', `id`);
\ No newline at end of file
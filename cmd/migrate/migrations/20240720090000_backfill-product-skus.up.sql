@@ -0,0 +1,3 @@
+-- Bulk imports match products by SKU, products from before SKUs get the
+-- default one new products without a SKU are given (`types.DefaultProductSKU()`).
+UPDATE products SET `sku` = CONCAT('P-', `id`) WHERE `sku` IS NULL;
\ No newline at end of file
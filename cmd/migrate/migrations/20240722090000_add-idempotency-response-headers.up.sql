@@ -0,0 +1,3 @@
+-- Headers of the stored response (JSON object of lists), replayed along with it.
+ALTER TABLE idempotency_keys
+    ADD COLUMN `responseHeaders` JSON NULL DEFAULT NULL AFTER `statusCode`;
\ No newline at end of file
@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"slices"
 	"strconv"
 
 	"github.com/joho/godotenv"
@@ -19,7 +21,56 @@ type Config struct {
 	DBAdress               string
 	DBName                 string
 	JWTExpirationInSeconds int64
-	JWTSecret              string
+	// HS256 secret, only signed with when `JWTAllowHS256` is set & `JWTKeys` is empty.
+	JWTSecret string
+	// Opt-in to HS256 without `JWTKeys`, for local development.
+	JWTAllowHS256 bool
+	// `kid=path.pem` list of asymmetric signing keys, see `auth.LoadKeys()`.
+	JWTKeys        string
+	JWTActiveKeyID string
+	JWTIssuer      string
+	JWTAudience    string
+	// Leeway when checking `exp`, `nbf` & `iat` of incoming tokens.
+	JWTClockSkewInSeconds int64
+	// Lifetime of refresh tokens, access tokens (above) are kept short-lived.
+	RefreshTokenExpirationInSeconds int64
+	// ISO 4217 code of all prices & totals stored in the DB.
+	Currency string
+	// Flat shipping fee per order (decimal, e.g. "4.99"), waived once the
+	// discounted subtotal reaches `FreeShippingThreshold` (empty: never).
+	ShippingFee           string
+	FreeShippingThreshold string
+	// Tax on the discounted subtotal in basis points (825 = 8.25%).
+	TaxRateBasisPoints int64
+	// How a guest cart is merged into the user's cart on login, for products
+	// in both: "sum" the quantities or keep the "latest" changed item.
+	// Merged quantities are optionally capped at the product's stock.
+	CartMergeStrategy   string
+	CartMergeCapAtStock bool
+	// How long a guest cart is kept after its last use, and how often
+	// expired guest carts are swept.
+	GuestCartTTLInDays              int64
+	GuestCartSweepIntervalInSeconds int64
+	// How long reservations hold stock for a cart or an unpaid order, and how
+	// often expired reservations are swept.
+	ReservationTTLInMinutes           int64
+	ReservationSweepIntervalInSeconds int64
+	// How checkout picks the warehouses an order ships from: by "priority",
+	// a "single" warehouse holding the whole order, or the "most-stock".
+	// Without splitting an order must ship from a single warehouse.
+	StockAllocationStrategy   string
+	StockAllocationAllowSplit bool
+	// Where uploaded product images are kept, "local" stores them under
+	// `MediaDir` & serves them at `PublicHost`/media/.
+	MediaStorage string
+	MediaDir     string
+	// Largest accepted image upload.
+	MaxImageUploadBytes int64
+	// Largest accepted bulk product import file (`POST /products/import`).
+	MaxImportBytes int64
+	// How often the search index is rebuilt from the DB, picking up changes
+	// made outside the API (e.g. `cmd/catalog` imports).
+	SearchRefreshIntervalInSeconds int64
 }
 
 func initConfig() Config {
@@ -27,14 +78,39 @@ func initConfig() Config {
 	godotenv.Load()
 
 	return Config{
-		PublicHost:             getEnv("PUBLIC_HOST", "http://localhost"),
+		PublicHost:             getEnv("PUBLIC_HOST", "http://localhost:8080"),
 		Port:                   getEnv("PORT", "8080"),
 		DBUser:                 getEnv("DB_USER", "root"),
 		DBPassword:             getEnv("DB_PASSWORD", "root"),
 		DBAdress:               fmt.Sprintf("%s:%s", getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "3306")),
 		DBName:                 getEnv("DB_NAME", "ecom"),
-		JWTExpirationInSeconds: getEnvAsInt("JWT_EXP", 3600*24*7),
-		JWTSecret:              getEnv("JWT_SECRET", "default_secret_?"),
+		JWTExpirationInSeconds: getEnvAsInt("JWT_EXP", 60*15),
+		JWTSecret:              getEnv("JWT_SECRET", ""),
+		JWTAllowHS256:          getEnvAsBool("JWT_ALLOW_HS256", false),
+		JWTKeys:                getEnv("JWT_KEYS", ""),
+		JWTActiveKeyID:         getEnv("JWT_ACTIVE_KEY_ID", ""),
+		JWTIssuer:              getEnv("JWT_ISSUER", "ecommerce-api-go"),
+		JWTAudience:            getEnv("JWT_AUDIENCE", "ecommerce-api-go"),
+		JWTClockSkewInSeconds:  getEnvAsInt("JWT_CLOCK_SKEW", 30),
+
+		RefreshTokenExpirationInSeconds:   getEnvAsInt("REFRESH_TOKEN_EXP", 3600*24*30),
+		Currency:                          getEnv("CURRENCY", "USD"),
+		ShippingFee:                       getEnv("SHIPPING_FEE", "0"),
+		FreeShippingThreshold:             getEnv("FREE_SHIPPING_THRESHOLD", ""),
+		TaxRateBasisPoints:                getEnvAsInt("TAX_RATE_BPS", 0),
+		CartMergeStrategy:                 getEnvAsOneOf("CART_MERGE_STRATEGY", "sum", "sum", "latest"), // `cart.MergeStrategy*`
+		CartMergeCapAtStock:               getEnvAsBool("CART_MERGE_CAP_AT_STOCK", true),
+		GuestCartTTLInDays:                getEnvAsInt("GUEST_CART_TTL_DAYS", 30),
+		GuestCartSweepIntervalInSeconds:   getEnvAsInt("GUEST_CART_SWEEP_INTERVAL", 3600),
+		ReservationTTLInMinutes:           getEnvAsInt("RESERVATION_TTL_MINUTES", 15),
+		ReservationSweepIntervalInSeconds: getEnvAsInt("RESERVATION_SWEEP_INTERVAL", 60),
+		StockAllocationStrategy:           getEnvAsOneOf("STOCK_ALLOCATION_STRATEGY", "priority", "priority", "single", "most-stock"), // `warehouse.Strategy*`
+		StockAllocationAllowSplit:         getEnvAsBool("STOCK_ALLOCATION_ALLOW_SPLIT", true),
+		MediaStorage:                      getEnv("MEDIA_STORAGE", "local"),
+		MediaDir:                          getEnv("MEDIA_DIR", "media"),
+		MaxImageUploadBytes:               getEnvAsInt("MAX_IMAGE_UPLOAD_BYTES", 10<<20),
+		MaxImportBytes:                    getEnvAsInt("MAX_IMPORT_BYTES", 50<<20),
+		SearchRefreshIntervalInSeconds:    getEnvAsInt("SEARCH_REFRESH_INTERVAL", 300),
 	}
 }
 
@@ -47,6 +123,16 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// Same as `getEnv()` but exits if the value isn't one of `allowed`...
+// so a typo fails on startup instead of silently picking another behaviour.
+func getEnvAsOneOf(key, fallback string, allowed ...string) string {
+	value := getEnv(key, fallback)
+	if !slices.Contains(allowed, value) {
+		log.Fatalf("invalid %s %q, expected one of %v", key, value, allowed)
+	}
+	return value
+}
+
 // Get environment variables and return as `int`...
 // Same functionality as `getEnv()`.
 func getEnvAsInt(key string, fallback int64) int64 {
@@ -60,3 +146,16 @@ func getEnvAsInt(key string, fallback int64) int64 {
 	return fallback
 
 }
+
+// Get environment variables and return as `bool`...
+// Same functionality as `getEnv()`.
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fallback
+		}
+		return b
+	}
+	return fallback
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"log"
+	"strings"
 
 	"github.com/go-sql-driver/mysql"
 )
@@ -16,3 +17,45 @@ func NewMySQLStorage(cfg mysql.Config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// Querier is satisfied by both *sql.DB and *sql.Tx...
+// so store queries can run with or without a transaction.
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Placeholders (`?, ?, ?`) & args for an `IN (...)` list of `values`.
+// `ok` is false without values: `IN ()` is invalid SQL, and there is nothing
+// to look up anyway.
+func InList[T any](values []T) (placeholders string, args []any, ok bool) {
+	if len(values) == 0 {
+		return "", nil, false
+	}
+
+	args = make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return "?" + strings.Repeat(", ?", len(values)-1), args, true
+}
+
+// Check the result of an UPDATE of the `table` row with ID `id`, returning
+// `notFound` if there is no such row. Unchanged rows report 0 affected rows
+// too, so existence is checked separately.
+func CheckUpdated(q Querier, res sql.Result, table string, id int, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	var exists bool
+	if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = ?)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return notFound
+	}
+	return nil
+}
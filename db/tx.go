@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Transactor runs a function inside a single database transaction.
+type Transactor struct {
+	db *sql.DB
+}
+
+func NewTransactor(db *sql.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+// Begin a transaction, run `fn` with it and commit...
+// if `fn` returns an error (or panics) the transaction is rolled back.
+func (t *Transactor) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rolling back on panic, then re-panicking so the caller still sees it.
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Run a locking read (`SELECT ... FOR UPDATE`) for its locks only, the rows
+// are read & discarded. The locks are held until `tx` ends.
+func LockRows(tx *sql.Tx, query string, args ...any) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+
+	return rows.Close()
+}
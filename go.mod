@@ -3,12 +3,14 @@ module github.com/gitKashish/ecommerce-api-go
 go 1.22.3
 
 require (
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/go-playground/validator/v10 v10.21.0
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/golang-migrate/migrate/v4 v4.17.1
 	github.com/joho/godotenv v1.5.1
 	golang.org/x/crypto v0.24.0
+	golang.org/x/image v0.18.0
 )
 
 require (
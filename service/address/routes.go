@@ -0,0 +1,157 @@
+package address
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+type Handler struct {
+	store            types.AddressStore
+	userStore        types.UserStore
+	idempotencyStore types.IdempotencyStore
+}
+
+func NewHandler(store types.AddressStore, userStore types.UserStore, idempotencyStore types.IdempotencyStore) *Handler {
+	return &Handler{store: store, userStore: userStore, idempotencyStore: idempotencyStore}
+}
+
+// Address book of the authenticated user.
+func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /addresses", auth.WithJWTAuth(h.handleGetAddresses, h.userStore))
+	router.HandleFunc("POST /addresses", auth.WithJWTAuth(idempotency.WithIdempotencyKey(h.handleCreateAddress, h.idempotencyStore), h.userStore))
+	router.HandleFunc("GET /addresses/{id}", auth.WithJWTAuth(h.handleGetAddress, h.userStore))
+	router.HandleFunc("PUT /addresses/{id}", auth.WithJWTAuth(idempotency.WithIdempotencyKey(h.handleUpdateAddress, h.idempotencyStore), h.userStore))
+	router.HandleFunc("DELETE /addresses/{id}", auth.WithJWTAuth(idempotency.WithIdempotencyKey(h.handleDeleteAddress, h.idempotencyStore), h.userStore))
+}
+
+// HandlerFunc to list the user's addresses.
+func (h *Handler) handleGetAddresses(w http.ResponseWriter, r *http.Request) {
+	addresses, err := h.store.GetAddressesByUserID(auth.GetUseIDFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, addresses)
+}
+
+// HandlerFunc to get a single address of the user.
+func (h *Handler) handleGetAddress(w http.ResponseWriter, r *http.Request) {
+	address, ok := h.getOwnAddressFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, address)
+}
+
+// HandlerFunc to add an address to the user's address book.
+func (h *Handler) handleCreateAddress(w http.ResponseWriter, r *http.Request) {
+	payload, ok := parseAddressPayload(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := h.store.CreateAddress(payload.ToAddress(auth.GetUseIDFromContext(r.Context())))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Reading it back, the first address is made default by the store.
+	address, err := h.store.GetAddressByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, address)
+}
+
+// HandlerFunc to replace an address of the user.
+func (h *Handler) handleUpdateAddress(w http.ResponseWriter, r *http.Request) {
+	existing, ok := h.getOwnAddressFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	payload, ok := parseAddressPayload(w, r)
+	if !ok {
+		return
+	}
+
+	address := payload.ToAddress(existing.UserID)
+	address.ID = existing.ID
+	address.CreatedAt = existing.CreatedAt
+
+	if err := h.store.UpdateAddress(address); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, address)
+}
+
+// HandlerFunc to remove an address from the user's address book.
+func (h *Handler) handleDeleteAddress(w http.ResponseWriter, r *http.Request) {
+	address, ok := h.getOwnAddressFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteAddress(address.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Util function to parse & validate an address payload.
+// Writes the error response itself and returns false if it is invalid.
+func parseAddressPayload(w http.ResponseWriter, r *http.Request) (types.AddressPayload, bool) {
+	var payload types.AddressPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return payload, false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return payload, false
+	}
+
+	return payload, true
+}
+
+// Util function to load the user's address addressed by the `{id}` path wildcard.
+// Addresses of other users are reported as not found.
+// Writes the error response itself and returns false on failure.
+func (h *Handler) getOwnAddressFromPath(w http.ResponseWriter, r *http.Request) (*types.Address, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid address id %q", r.PathValue("id")))
+		return nil, false
+	}
+
+	address, err := h.store.GetAddressByID(id)
+	if errors.Is(err, types.ErrAddressNotFound) || (err == nil && address.UserID != auth.GetUseIDFromContext(r.Context())) {
+		utils.WriteError(w, http.StatusNotFound, types.ErrAddressNotFound)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return address, true
+}
@@ -0,0 +1,181 @@
+package address
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const addressColumns = "id, userId, fullName, line1, line2, city, state, postalCode, country, phone, isDefaultShipping, isDefaultBilling, createdAt"
+
+// Get all addresses of a user, defaults first.
+func (s *Store) GetAddressesByUserID(userID int) ([]types.Address, error) {
+	rows, err := s.db.Query("SELECT "+addressColumns+" FROM addresses WHERE userId = ? ORDER BY isDefaultShipping DESC, isDefaultBilling DESC, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := make([]types.Address, 0)
+	for rows.Next() {
+		a, err := scanRowIntoAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, *a)
+	}
+
+	return addresses, rows.Err()
+}
+
+// Get a single address by its ID.
+// Returns `types.ErrAddressNotFound` if there is no such address.
+func (s *Store) GetAddressByID(id int) (*types.Address, error) {
+	rows, err := s.db.Query("SELECT "+addressColumns+" FROM addresses WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, types.ErrAddressNotFound
+	}
+
+	return scanRowIntoAddress(rows)
+}
+
+// Create a new address and return its ID.
+// A user's first address becomes their default shipping & billing address.
+func (s *Store) CreateAddress(address types.Address) (int, error) {
+	err := db.NewTransactor(s.db).WithTx(context.Background(), func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM addresses WHERE userId = ? FOR UPDATE", address.UserID).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			address.IsDefaultShipping = true
+			address.IsDefaultBilling = true
+		}
+
+		res, err := tx.Exec("INSERT INTO addresses (userId, fullName, line1, line2, city, state, postalCode, country, phone, isDefaultShipping, isDefaultBilling) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			address.UserID, address.FullName, address.Line1, address.Line2, address.City, address.State,
+			address.PostalCode, address.Country, address.Phone, address.IsDefaultShipping, address.IsDefaultBilling)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		address.ID = int(id)
+
+		return clearOtherDefaults(tx, address)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return address.ID, nil
+}
+
+// Update address values in DB.
+func (s *Store) UpdateAddress(address types.Address) error {
+	return db.NewTransactor(s.db).WithTx(context.Background(), func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE addresses SET fullName = ?, line1 = ?, line2 = ?, city = ?, state = ?, postalCode = ?, country = ?, phone = ?, isDefaultShipping = ?, isDefaultBilling = ? WHERE id = ?",
+			address.FullName, address.Line1, address.Line2, address.City, address.State, address.PostalCode,
+			address.Country, address.Phone, address.IsDefaultShipping, address.IsDefaultBilling, address.ID)
+		if err != nil {
+			return err
+		}
+
+		return clearOtherDefaults(tx, address)
+	})
+}
+
+// Delete an address.
+// Orders keep their own copy of the address, so this never rewrites history.
+// Default flags of the deleted address move to the user's latest remaining
+// address, so checkout keeps finding a default.
+func (s *Store) DeleteAddress(id int) error {
+	return db.NewTransactor(s.db).WithTx(context.Background(), func(tx *sql.Tx) error {
+		var address types.Address
+		err := tx.QueryRow("SELECT userId, isDefaultShipping, isDefaultBilling FROM addresses WHERE id = ? FOR UPDATE", id).
+			Scan(&address.UserID, &address.IsDefaultShipping, &address.IsDefaultBilling)
+		if err == sql.ErrNoRows {
+			return types.ErrAddressNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM addresses WHERE id = ?", id); err != nil {
+			return err
+		}
+
+		if address.IsDefaultShipping {
+			if _, err := tx.Exec("UPDATE addresses SET isDefaultShipping = TRUE WHERE userId = ? ORDER BY id DESC LIMIT 1", address.UserID); err != nil {
+				return err
+			}
+		}
+		if address.IsDefaultBilling {
+			if _, err := tx.Exec("UPDATE addresses SET isDefaultBilling = TRUE WHERE userId = ? ORDER BY id DESC LIMIT 1", address.UserID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// A user has at most one default shipping & one default billing address.
+func clearOtherDefaults(tx *sql.Tx, address types.Address) error {
+	if address.IsDefaultShipping {
+		if _, err := tx.Exec("UPDATE addresses SET isDefaultShipping = FALSE WHERE userId = ? AND id != ?", address.UserID, address.ID); err != nil {
+			return err
+		}
+	}
+	if address.IsDefaultBilling {
+		if _, err := tx.Exec("UPDATE addresses SET isDefaultBilling = FALSE WHERE userId = ? AND id != ?", address.UserID, address.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanRowIntoAddress(rows *sql.Rows) (*types.Address, error) {
+	address := new(types.Address)
+	err := rows.Scan(
+		&address.ID,
+		&address.UserID,
+		&address.FullName,
+		&address.Line1,
+		&address.Line2,
+		&address.City,
+		&address.State,
+		&address.PostalCode,
+		&address.Country,
+		&address.Phone,
+		&address.IsDefaultShipping,
+		&address.IsDefaultBilling,
+		&address.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
@@ -2,10 +2,13 @@ package auth
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gitKashish/ecommerce-api-go/config"
@@ -16,24 +19,76 @@ import (
 
 type contextKey string
 
-const UserKey contextKey = "userID"
+const (
+	UserKey contextKey = "userID"
+	RoleKey contextKey = "role"
+)
 
-func CreateJWT(secret []byte, userID int) (string, error) {
-	expiration := time.Second * time.Duration(config.Envs.JWTExpirationInSeconds)
+// Realm advertised in `WWW-Authenticate` challenges.
+const realm = "ecommerce-api-go"
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errInvalidToken = errors.New("invalid or expired token")
+)
+
+// Access token claims, only registered claims (RFC 7519 4.1) are used.
+// The user ID is carried in `sub`.
+type Claims struct {
+	jwt.StandardClaims
+}
+
+// Called by the jwt parser once the signature checks out.
+// Unlike `jwt.StandardClaims.Valid()` every claim is required and
+// time based claims are checked with `JWT_CLOCK_SKEW` of leeway.
+func (c Claims) Valid() error {
+	now := jwt.TimeFunc().Unix()
+	skew := config.Envs.JWTClockSkewInSeconds
+
+	switch {
+	case !c.VerifyExpiresAt(now-skew, true):
+		return fmt.Errorf("token is expired")
+	case !c.VerifyNotBefore(now+skew, true):
+		return fmt.Errorf("token is not valid yet")
+	case !c.VerifyIssuedAt(now+skew, true):
+		return fmt.Errorf("token used before issued")
+	case !c.VerifyIssuer(config.Envs.JWTIssuer, true):
+		return fmt.Errorf("unexpected issuer %q", c.Issuer)
+	case !c.VerifyAudience(config.Envs.JWTAudience, true):
+		return fmt.Errorf("unexpected audience %q", c.Audience)
+	case c.Id == "":
+		return fmt.Errorf("missing token id")
+	}
+
+	if _, err := strconv.Atoi(c.Subject); err != nil {
+		return fmt.Errorf("invalid subject %q", c.Subject)
+	}
+
+	return nil
+}
 
-	// Create a new JWT Token establishing its signing method (not yet signed).
-	// Along with mapped claims (using `jwt.MapClaims()` method).
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userID":    strconv.Itoa(userID),
-		"expiredAt": time.Now().Add(expiration).Unix(),
-	})
+// Create a signed access token for the user.
+// Signed with the active key (see `LoadKeys()`).
+func CreateJWT(userID int) (string, error) {
+	now := time.Now()
+	expiration := time.Second * time.Duration(config.Envs.JWTExpirationInSeconds)
 
-	// Signing the token with pre-determined signing method.
-	tokenString, err := token.SignedString(secret)
+	// Unique token ID (`jti`).
+	id, err := randomBytes(16)
 	if err != nil {
 		return "", err
 	}
-	return tokenString, nil
+
+	// Registered claims only, signed with the active key.
+	return keys.sign(Claims{jwt.StandardClaims{
+		Subject:   strconv.Itoa(userID),
+		ExpiresAt: now.Add(expiration).Unix(),
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		Issuer:    config.Envs.JWTIssuer,
+		Audience:  config.Envs.JWTAudience,
+		Id:        hex.EncodeToString(id),
+	}})
 }
 
 // JWT Authorization middleware.
@@ -41,39 +96,42 @@ func CreateJWT(secret []byte, userID int) (string, error) {
 // Traditional closures -> Middleware Chaining.
 func WithJWTAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// get token from the user request
-		tokenString := getTokenFromRequest(r)
+		// get bearer token from the user request
+		tokenString, err := getTokenFromRequest(r)
+		if err != nil {
+			unauthorized(w, err)
+			return
+		}
 
 		// validate JWT token
 		token, err := validateToken(tokenString)
 		if err != nil {
 			log.Printf("failed to validate token: %v", err)
-			permissionDenied(w)
+			unauthorized(w, errInvalidToken)
 			return
 		}
 
 		if !token.Valid {
 			log.Println("invalid token")
-			permissionDenied(w)
+			unauthorized(w, errInvalidToken)
 			return
 		}
 
 		// if it is we need to fetch the userID from the DB (id from the token)
-		claims := token.Claims.(jwt.MapClaims)
-		str := claims["userID"].(string)
-
-		userID, _ := strconv.Atoi(str)
+		claims := token.Claims.(*Claims)
+		userID, _ := strconv.Atoi(claims.Subject) // Checked by `Claims.Valid()`.
 
 		user, err := store.GetUserByID(userID)
 		if err != nil {
 			log.Printf("failed to get user by id: %v", err)
-			permissionDenied(w)
+			unauthorized(w, errInvalidToken)
 			return
 		}
 
-		// set context "userID" to the userID.
+		// set context "userID" to the userID & "role" to the user's role.
 		ctx := r.Context()
 		ctx = context.WithValue(ctx, UserKey, user.ID)
+		ctx = context.WithValue(ctx, RoleKey, user.Role)
 		r = r.WithContext(ctx)
 
 		// Execute wrapped HandlerFunc. It will now execute with...
@@ -82,35 +140,76 @@ func WithJWTAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.Handl
 	}
 }
 
+// Same as `WithJWTAuth()` but lets requests without an "Authorization" header
+// through anonymously (`GetUseIDFromContext()` returns -1 for them).
+// Invalid credentials are still rejected.
+func WithOptionalJWTAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
+	authenticated := WithJWTAuth(handlerFunc, store)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			handlerFunc(w, r)
+			return
+		}
+
+		authenticated(w, r)
+	}
+}
+
+// Role based authorization middleware, layered on top of `WithJWTAuth()`.
+// Only users having `role` reach the wrapped HandlerFunc.
+func WithRole(handlerFunc http.HandlerFunc, store types.UserStore, role string) http.HandlerFunc {
+	return WithJWTAuth(func(w http.ResponseWriter, r *http.Request) {
+		if GetRoleFromContext(r.Context()) != role {
+			log.Printf("user %d is missing role %q", GetUseIDFromContext(r.Context()), role)
+			permissionDenied(w)
+			return
+		}
+
+		handlerFunc(w, r)
+	}, store)
+}
+
 // Check if a token string received in a request is valid or not.
 func validateToken(tokenString string) (*jwt.Token, error) {
-	// `jwt.Parse` takes in the token string and the JWT secret to...
-	// extract theh fields of the Token.
-	return jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		// jwt.KeyFunc(this) returns the secrete key (loaded from environment)...
-		// for parsing if the token signing etc. is proper.
-		// If it returns `nil` then the `jwt.Parse()` method would return error...
-		// indicating an invalid token.
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-		}
-		return []byte(config.Envs.JWTSecret), nil
-	})
+	// `jwt.ParseWithClaims` takes in the token string and a key lookup to...
+	// extract the fields of the Token & validate them (see `Claims.Valid()`).
+	// `keyFunc` returns the verification key named by the token's `kid`,
+	// if it errors `jwt.ParseWithClaims()` returns an invalid token error.
+	return jwt.ParseWithClaims(tokenString, &Claims{}, keys.keyFunc)
 }
 
 // Util function to get token string from HTTP "Authorization" header.
-func getTokenFromRequest(r *http.Request) string {
-	tokenAuth := r.Header.Get("Authorization")
+// Expects the RFC 6750 form `Authorization: Bearer <token>` (scheme is case-insensitive).
+func getTokenFromRequest(r *http.Request) (string, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", errMissingToken
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errMissingToken
+	}
 
-	if tokenAuth != "" {
-		return tokenAuth
+	return token, nil
+}
+
+// Util function for missing or invalid credentials.
+// Responds with http.StatusUnauthorized and a `WWW-Authenticate` challenge (RFC 6750 3).
+func unauthorized(w http.ResponseWriter, err error) {
+	challenge := fmt.Sprintf("Bearer realm=%q", realm)
+	// A request without credentials gets a bare challenge, no error code.
+	if !errors.Is(err, errMissingToken) {
+		challenge += fmt.Sprintf(", error=\"invalid_token\", error_description=%q", err.Error())
 	}
 
-	return ""
+	w.Header().Set("WWW-Authenticate", challenge)
+	utils.WriteError(w, http.StatusUnauthorized, err)
 }
 
-// Util function (to make code cleaner).
+// Util function for authenticated users lacking permissions (e.g. role).
 func permissionDenied(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q, error=\"insufficient_scope\"", realm))
 	utils.WriteError(w, http.StatusForbidden, fmt.Errorf("permission denied"))
 }
 
@@ -124,3 +223,14 @@ func GetUseIDFromContext(ctx context.Context) int {
 
 	return userID
 }
+
+// Get authorized user's role from current context.
+// Should be used once context has been updated.
+func GetRoleFromContext(ctx context.Context) string {
+	role, ok := ctx.Value(RoleKey).(string)
+	if !ok {
+		return ""
+	}
+
+	return role
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/golang-jwt/jwt"
+)
+
+func TestWithJWTAuth(t *testing.T) {
+	handler := WithJWTAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, &mockUserStore{})
+
+	send := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	// Signs claims issued `age` ago with the default claim values.
+	sign := func(age time.Duration, modify func(c *Claims)) string {
+		issued := time.Now().Add(-age)
+		claims := Claims{jwt.StandardClaims{
+			Subject:   "1",
+			ExpiresAt: issued.Add(time.Minute).Unix(),
+			IssuedAt:  issued.Unix(),
+			NotBefore: issued.Unix(),
+			Issuer:    config.Envs.JWTIssuer,
+			Audience:  config.Envs.JWTAudience,
+			Id:        "test",
+		}}
+		if modify != nil {
+			modify(&claims)
+		}
+
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Envs.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	t.Run("should accept a valid bearer token", func(t *testing.T) {
+		token, err := CreateJWT(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rr := send("bearer " + token); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should challenge requests without a bearer token", func(t *testing.T) {
+		token, _ := CreateJWT(1)
+
+		for _, authorization := range []string{"", token, "Basic " + token} {
+			rr := send(authorization)
+
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+			}
+			if challenge := rr.Header().Get("WWW-Authenticate"); !strings.HasPrefix(challenge, "Bearer") || strings.Contains(challenge, "error=") {
+				t.Errorf("expected bare bearer challenge, got %q", challenge)
+			}
+		}
+	})
+
+	t.Run("should tolerate clock skew on expiry", func(t *testing.T) {
+		// Expired 10 seconds ago, within the default 30 seconds of skew.
+		if rr := send("Bearer " + sign(time.Minute+10*time.Second, nil)); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	invalid := map[string]string{
+		"expired":        sign(2*time.Minute, nil),
+		"not yet valid":  sign(-2*time.Minute, nil),
+		"wrong audience": sign(0, func(c *Claims) { c.Audience = "someone-else" }),
+		"wrong issuer":   sign(0, func(c *Claims) { c.Issuer = "someone-else" }),
+		"missing expiry": sign(0, func(c *Claims) { c.ExpiresAt = 0 }),
+	}
+	for name, token := range invalid {
+		t.Run("should reject "+name+" tokens", func(t *testing.T) {
+			rr := send("Bearer " + token)
+
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+			}
+			if challenge := rr.Header().Get("WWW-Authenticate"); !strings.Contains(challenge, `error="invalid_token"`) {
+				t.Errorf("expected invalid_token challenge, got %q", challenge)
+			}
+		})
+	}
+}
+
+func TestWithOptionalJWTAuth(t *testing.T) {
+	var userID int
+	handler := WithOptionalJWTAuth(func(w http.ResponseWriter, r *http.Request) {
+		userID = GetUseIDFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}, &mockUserStore{})
+
+	t.Run("should let anonymous requests through", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rr.Code != http.StatusOK || userID != -1 {
+			t.Errorf("expected anonymous request to pass, got status %d & user %d", rr.Code, userID)
+		}
+	})
+
+	t.Run("should authenticate requests with a bearer token", func(t *testing.T) {
+		token, _ := CreateJWT(7)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		if rr.Code != http.StatusOK || userID != 7 {
+			t.Errorf("expected user 7, got status %d & user %d", rr.Code, userID)
+		}
+	})
+
+	t.Run("should reject invalid credentials", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer nonsense")
+
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
+
+type mockUserStore struct{}
+
+func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
+	return nil, nil
+}
+
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	return &types.User{ID: id, Role: types.RoleCustomer}, nil
+}
+
+func (m *mockUserStore) CreateUser(user types.User) error {
+	return nil
+}
@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/golang-jwt/jwt"
+)
+
+// A key tokens are signed and/or verified with, identified by `kid`.
+// `private` is nil for verify-only (retiring) keys.
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private any
+	public  any
+}
+
+// Keys currently in use. Tokens are signed with `active`...
+// and verified with whichever key their `kid` header names.
+type keySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// HS256 with `JWT_SECRET` (empty by default) until `LoadKeys()` is called...
+// which keeps tests without key files working. Servers must call `LoadKeys()`.
+var keys = hmacKeySet([]byte(config.Envs.JWTSecret))
+
+// Shortest `JWT_SECRET` accepted for HS256, 256 bits.
+const minHMACSecretLength = 32
+
+func hmacKeySet(secret []byte) *keySet {
+	key := &signingKey{id: "", method: jwt.SigningMethodHS256, private: secret, public: secret}
+	return &keySet{active: key, keys: map[string]*signingKey{"": key}}
+}
+
+// Load signing keys from the PEM files configured in `JWT_KEYS`.
+//
+// `JWT_KEYS` is a comma separated list of `kid=path/to/key.pem` entries.
+// Each file holds an RSA (RS256) or Ed25519 (EdDSA) key, private keys can
+// sign & verify, public keys can only verify. `JWT_ACTIVE_KEY_ID` picks the
+// key new tokens are signed with (defaults to the first private key).
+//
+// Rotating: add the new key, make it active, keep the old one listed until
+// all tokens it signed have expired, then remove it (retire).
+//
+// Without `JWT_KEYS` it fails, unless `JWT_ALLOW_HS256` opts in to signing
+// with HS256 and `JWT_SECRET` (see `hmacFallback()`).
+func LoadKeys() error {
+	if config.Envs.JWTKeys == "" {
+		set, err := hmacFallback(config.Envs.JWTSecret, config.Envs.JWTAllowHS256)
+		if err != nil {
+			return err
+		}
+
+		keys = set
+		log.Println("JWT : no signing keys configured, signing with HS256 and JWT_SECRET (JWT_ALLOW_HS256).")
+		return nil
+	}
+
+	set, err := parseKeySet(config.Envs.JWTKeys, config.Envs.JWTActiveKeyID, os.ReadFile)
+	if err != nil {
+		return err
+	}
+
+	keys = set
+	log.Printf("JWT : loaded %d key(s), signing with %q.", len(set.keys), set.active.id)
+	return nil
+}
+
+// HS256 key set of `secret`, only if explicitly `allowed` and the secret is
+// long enough to not be guessable.
+func hmacFallback(secret string, allowed bool) (*keySet, error) {
+	if !allowed {
+		return nil, fmt.Errorf("no JWT signing keys configured, set JWT_KEYS (or JWT_ALLOW_HS256 & JWT_SECRET for local development)")
+	}
+	if len(secret) < minHMACSecretLength {
+		return nil, fmt.Errorf("JWT_SECRET must be at least %d characters to sign with HS256", minHMACSecretLength)
+	}
+	return hmacKeySet([]byte(secret)), nil
+}
+
+func parseKeySet(spec, activeID string, readFile func(string) ([]byte, error)) (*keySet, error) {
+	set := &keySet{keys: make(map[string]*signingKey)}
+
+	for _, entry := range strings.Split(spec, ",") {
+		id, path, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || id == "" || path == "" {
+			return nil, fmt.Errorf("invalid JWT key entry %q, expected kid=path", entry)
+		}
+		if _, ok := set.keys[id]; ok {
+			return nil, fmt.Errorf("duplicate JWT key id %q", id)
+		}
+
+		data, err := readFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWT key %q: %w", id, err)
+		}
+
+		key, err := parsePEMKey(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWT key %q: %w", id, err)
+		}
+		set.keys[id] = key
+
+		if set.active == nil && activeID == "" && key.private != nil {
+			set.active = key
+		}
+	}
+
+	if activeID != "" {
+		set.active = set.keys[activeID]
+	}
+	if set.active == nil || set.active.private == nil {
+		return nil, fmt.Errorf("no private key to sign with (JWT_ACTIVE_KEY_ID=%q)", activeID)
+	}
+
+	return set, nil
+}
+
+func parsePEMKey(id string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{id: id, method: jwt.SigningMethodRS256, private: k, public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &signingKey{id: id, method: jwt.SigningMethodRS256, public: k}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{id: id, method: jwt.SigningMethodEdDSA, private: k, public: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &signingKey{id: id, method: jwt.SigningMethodEdDSA, public: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, expected RSA or Ed25519", parsed)
+	}
+}
+
+// Sign claims with the active key, naming it in the `kid` header.
+func (ks *keySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	if ks.active.id != "" {
+		token.Header["kid"] = ks.active.id
+	}
+	return token.SignedString(ks.active.private)
+}
+
+// `jwt.Keyfunc` picking the verification key by the token's `kid` header.
+// The algorithm must be the one of that key, so a token cannot pick
+// e.g. HS256 to be checked against an RSA public key.
+func (ks *keySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	id, _ := t.Header["kid"].(string)
+
+	key, ok := ks.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	}
+
+	return key.public, nil
+}
+
+// JSON Web Key (RFC 7517) of a public key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 (OKP)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+func (ks *keySet) jwks() []jwk {
+	set := make([]jwk, 0, len(ks.keys))
+
+	for _, key := range ks.keys {
+		k := jwk{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			k.Kty = "RSA"
+			k.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			k.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			k.Kty = "OKP"
+			k.Crv = "Ed25519"
+			k.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			// Shared HMAC secrets are never published.
+			continue
+		}
+
+		set = append(set, k)
+	}
+
+	return set
+}
+
+// HandlerFunc serving the public verification keys as a JWK Set...
+// so other services can verify tokens without the signing keys.
+func HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.WriteJSONWithETag(w, r, http.StatusOK, map[string]any{
+		"keys": keys.jwks(),
+	})
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestKeySet(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaDER, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	edPublicDER, _ := x509.MarshalPKIXPublicKey(edKey.Public())
+
+	files := map[string][]byte{
+		"old.pem":    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rsaDER}),
+		"new.pem":    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}),
+		"public.pem": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: edPublicDER}),
+	}
+	readFile := func(path string) ([]byte, error) {
+		data, ok := files[path]
+		if !ok {
+			return nil, fmt.Errorf("no such file %q", path)
+		}
+		return data, nil
+	}
+
+	claims := jwt.StandardClaims{Subject: "1"}
+
+	before, err := parseKeySet("old=old.pem", "", readFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := before.sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := parseKeySet("old=old.pem, new=new.pem", "new", readFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should sign with the active key", func(t *testing.T) {
+		token, err := after.sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := jwt.ParseWithClaims(token, &jwt.StandardClaims{}, after.keyFunc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Header["kid"] != "new" || parsed.Method.Alg() != "EdDSA" {
+			t.Errorf("expected EdDSA token with kid new, got %v", parsed.Header)
+		}
+	})
+
+	t.Run("should keep verifying tokens of rotated keys", func(t *testing.T) {
+		if _, err := jwt.ParseWithClaims(oldToken, &jwt.StandardClaims{}, after.keyFunc); err != nil {
+			t.Errorf("expected old token to verify, got %v", err)
+		}
+	})
+
+	t.Run("should reject tokens of retired keys", func(t *testing.T) {
+		retired, err := parseKeySet("new=new.pem", "", readFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := jwt.ParseWithClaims(oldToken, &jwt.StandardClaims{}, retired.keyFunc); err == nil {
+			t.Error("expected token of retired key to be rejected")
+		}
+	})
+
+	t.Run("should reject HMAC tokens", func(t *testing.T) {
+		token, _ := hmacKeySet([]byte("secret")).sign(claims)
+
+		if _, err := jwt.ParseWithClaims(token, &jwt.StandardClaims{}, after.keyFunc); err == nil {
+			t.Error("expected HMAC token to be rejected")
+		}
+	})
+
+	t.Run("should publish every public key", func(t *testing.T) {
+		if set := after.jwks(); len(set) != 2 {
+			t.Errorf("expected 2 keys, got %+v", set)
+		}
+		if set := hmacKeySet([]byte("secret")).jwks(); len(set) != 0 {
+			t.Errorf("expected HMAC secret not to be published, got %+v", set)
+		}
+	})
+
+	t.Run("should only fall back to HMAC when allowed with a long secret", func(t *testing.T) {
+		secret := "0123456789abcdef0123456789abcdef"
+
+		if _, err := hmacFallback(secret, false); err == nil {
+			t.Error("expected error without the opt-in")
+		}
+		if _, err := hmacFallback("", true); err == nil {
+			t.Error("expected error for an empty secret")
+		}
+		if _, err := hmacFallback(secret[:31], true); err == nil {
+			t.Error("expected error for a short secret")
+		}
+		if set, err := hmacFallback(secret, true); err != nil || set.active.method != jwt.SigningMethodHS256 {
+			t.Errorf("expected an HS256 key set, got %v", err)
+		}
+	})
+
+	t.Run("should not sign with a public key", func(t *testing.T) {
+		if _, err := parseKeySet("public=public.pem", "public", readFile); err == nil {
+			t.Error("expected error for a public active key")
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, please log in again")
+)
+
+// Issue a new refresh token for the user.
+// Pass an empty `familyID` to start a new family (login).
+// Returns the plain token, only its hash is stored.
+func IssueRefreshToken(store types.RefreshTokenStore, userID int, familyID string) (string, error) {
+	if familyID == "" {
+		id, err := randomBytes(16)
+		if err != nil {
+			return "", err
+		}
+		familyID = hex.EncodeToString(id)
+	}
+
+	b, err := randomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	expiration := time.Second * time.Duration(config.Envs.RefreshTokenExpirationInSeconds)
+	err = store.CreateRefreshToken(types.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: HashRefreshToken(token),
+		ExpiresAt: time.Now().Add(expiration),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Exchange a refresh token for a new one of the same family (rotation).
+// Presenting a token that was already used or revoked means it leaked:
+// the whole family is revoked and `ErrRefreshTokenReused` returned.
+func RotateRefreshToken(store types.RefreshTokenStore, token string) (userID int, newToken string, err error) {
+	record, err := store.GetRefreshTokenByHash(HashRefreshToken(token))
+	if err != nil {
+		return 0, "", err
+	}
+
+	if record.UsedAt != nil || record.RevokedAt != nil {
+		revokeFamily(store, record)
+		return 0, "", ErrRefreshTokenReused
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return 0, "", ErrInvalidRefreshToken
+	}
+
+	// Losing this race means another request rotated the same token first.
+	ok, err := store.MarkRefreshTokenUsed(record.ID)
+	if err != nil {
+		return 0, "", err
+	}
+	if !ok {
+		revokeFamily(store, record)
+		return 0, "", ErrRefreshTokenReused
+	}
+
+	newToken, err = IssueRefreshToken(store, record.UserID, record.FamilyID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return record.UserID, newToken, nil
+}
+
+// Revoke the family the refresh token belongs to (logout).
+func RevokeRefreshToken(store types.RefreshTokenStore, token string) error {
+	record, err := store.GetRefreshTokenByHash(HashRefreshToken(token))
+	if err != nil {
+		return err
+	}
+
+	return store.RevokeRefreshTokenFamily(record.FamilyID)
+}
+
+// Refresh tokens are high entropy random strings...
+// a plain (unsalted) SHA-256 is enough to keep them unusable if the DB leaks.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func revokeFamily(store types.RefreshTokenStore, record *types.RefreshToken) {
+	log.Printf("refresh token reuse for user %d, revoking family %s", record.UserID, record.FamilyID)
+	if err := store.RevokeRefreshTokenFamily(record.FamilyID); err != nil {
+		log.Printf("failed to revoke refresh token family: %v", err)
+	}
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Create a new refresh token record in `refresh_tokens` table.
+func (s *Store) CreateRefreshToken(token types.RefreshToken) error {
+	_, err := s.db.Exec("INSERT INTO refresh_tokens (userId, familyId, tokenHash, expiresAt) VALUES (?, ?, ?, ?)",
+		token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt)
+	return err
+}
+
+// Get a refresh token record by the hash of the token.
+func (s *Store) GetRefreshTokenByHash(hash string) (*types.RefreshToken, error) {
+	row := s.db.QueryRow("SELECT id, userId, familyId, tokenHash, expiresAt, usedAt, revokedAt, createdAt FROM refresh_tokens WHERE tokenHash = ?", hash)
+
+	token := new(types.RefreshToken)
+	var usedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&usedAt,
+		&revokedAt,
+		&token.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return token, nil
+}
+
+// Mark a refresh token as used (rotated).
+// The `usedAt IS NULL` guard makes this atomic: of two concurrent refreshes
+// with the same token only one gets `true`.
+func (s *Store) MarkRefreshTokenUsed(id int) (bool, error) {
+	res, err := s.db.Exec("UPDATE refresh_tokens SET usedAt = CURRENT_TIMESTAMP WHERE id = ? AND usedAt IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// Revoke every token of a family (logout / reuse detection).
+func (s *Store) RevokeRefreshTokenFamily(familyID string) error {
+	_, err := s.db.Exec("UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP WHERE familyId = ? AND revokedAt IS NULL", familyID)
+	return err
+}
@@ -0,0 +1,161 @@
+package cart
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Merge strategies for products in both the guest & the user's cart (`CART_MERGE_STRATEGY`).
+const (
+	// Add up both quantities.
+	MergeStrategySum = "sum"
+	// Keep the item changed last.
+	MergeStrategyLatest = "latest"
+)
+
+// How long a guest cart is kept after its last use (`GUEST_CART_TTL_DAYS`).
+func guestCartTTL() time.Duration {
+	return time.Duration(config.Envs.GuestCartTTLInDays) * 24 * time.Hour
+}
+
+// Create a random guest cart token (base64url of 32 random bytes).
+func newCartToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Tokens aren't stored, only their hash (hex encoded SHA-256).
+func hashCartToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Merges guest carts into user carts (see `types.CartMerger`).
+type Merger struct {
+	cartStore    types.CartStore
+	productStore types.ProductStore
+	variantStore types.VariantStore
+	transactor   types.Transactor
+}
+
+func NewMerger(cartStore types.CartStore, productStore types.ProductStore, variantStore types.VariantStore, transactor types.Transactor) *Merger {
+	return &Merger{cartStore: cartStore, productStore: productStore, variantStore: variantStore, transactor: transactor}
+}
+
+// Cart items are keyed by product & variant (0 for products without variants).
+type itemKey struct{ productID, variantID int }
+
+// Move the items of the guest cart named by `token` into the user's cart,
+// then delete the guest cart. Unknown tokens (e.g. already merged) are ignored.
+func (m *Merger) MergeGuestCart(ctx context.Context, token string, userID int) error {
+	guest, err := m.cartStore.GetCartByTokenHash(hashCartToken(token))
+	if errors.Is(err, types.ErrCartNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	userCart, err := m.cartStore.GetCartByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	return m.transactor.WithTx(ctx, func(tx *sql.Tx) error {
+		guestLines, err := m.cartStore.GetCartLinesForUpdate(tx, guest.ID)
+		if err != nil {
+			return err
+		}
+
+		userLines, err := m.cartStore.GetCartLinesForUpdate(tx, userCart.ID)
+		if err != nil {
+			return err
+		}
+
+		productIDs := make([]int, len(guestLines))
+		for i, l := range guestLines {
+			productIDs[i] = l.ProductID
+		}
+
+		// Products that are gone are left out of the map, they aren't capped.
+		products, err := m.productStore.GetProductByIDs(productIDs)
+		if err != nil {
+			return err
+		}
+		stock := make(map[itemKey]int, len(products))
+		for _, p := range products {
+			stock[itemKey{p.ID, 0}] = p.Quantity
+		}
+
+		// Variants are capped at their own stock.
+		variants, err := m.variantStore.GetVariantsByProductIDs(productIDs)
+		if err != nil {
+			return err
+		}
+		for _, v := range variants {
+			stock[itemKey{v.ProductID, v.ID}] = v.Quantity
+		}
+
+		strategy, capAtStock := config.Envs.CartMergeStrategy, config.Envs.CartMergeCapAtStock
+		for _, l := range mergeLines(userLines, guestLines, stock, strategy, capAtStock) {
+			if err := m.cartStore.SetCartItemTx(tx, userCart.ID, l); err != nil {
+				return err
+			}
+		}
+
+		return m.cartStore.DeleteCartTx(tx, guest.ID)
+	})
+}
+
+// Work out the user's cart items changed by merging in the guest's items.
+//
+// Rules:
+//   - products (variants) only in the guest cart are added as is.
+//   - products (variants) in both are merged by `strategy` (see `MergeStrategySum`).
+//   - with `capAtStock` merged quantities don't exceed the product's (variant's)
+//     `stock`, except for those out of stock or gone (no stock entry), which
+//     the cart's quote warns about instead.
+//
+// Unchanged user items are left out.
+func mergeLines(userLines, guestLines []types.CartLine, stock map[itemKey]int, strategy string, capAtStock bool) []types.CartLine {
+	existing := make(map[itemKey]types.CartLine, len(userLines))
+	for _, l := range userLines {
+		existing[itemKey{l.ProductID, l.VariantID}] = l
+	}
+
+	merged := make([]types.CartLine, 0, len(guestLines))
+	for _, guest := range guestLines {
+		l, k := guest, itemKey{guest.ProductID, guest.VariantID}
+		if user, ok := existing[k]; ok {
+			switch {
+			case strategy == MergeStrategyLatest && !guest.UpdatedAt.After(user.UpdatedAt):
+				l = user
+			case strategy != MergeStrategyLatest:
+				l.Quantity = user.Quantity + guest.Quantity
+			}
+		}
+
+		if available := stock[k]; capAtStock && available > 0 && l.Quantity > available {
+			l.Quantity = available
+		}
+
+		if user, ok := existing[k]; ok && user == l {
+			continue
+		}
+		merged = append(merged, l)
+	}
+
+	return merged
+}
@@ -0,0 +1,103 @@
+package cart
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestMergeLines(t *testing.T) {
+	earlier := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	userLines := []types.CartLine{
+		{ProductID: 1, Quantity: 2, PriceAtAdd: types.NewMoney(1000), UpdatedAt: earlier},
+		{ProductID: 2, Quantity: 4, PriceAtAdd: types.NewMoney(500), UpdatedAt: later},
+	}
+	guestLines := []types.CartLine{
+		{ProductID: 1, Quantity: 3, PriceAtAdd: types.NewMoney(1000), UpdatedAt: later},
+		{ProductID: 2, Quantity: 1, PriceAtAdd: types.NewMoney(500), UpdatedAt: earlier},
+		{ProductID: 3, Quantity: 1, PriceAtAdd: types.NewMoney(200), UpdatedAt: earlier},
+	}
+	stock := map[itemKey]int{{1, 0}: 10, {2, 0}: 10, {3, 0}: 10}
+
+	quantities := func(lines []types.CartLine) map[int]int {
+		q := map[int]int{}
+		for _, l := range lines {
+			q[l.ProductID] = l.Quantity
+		}
+		return q
+	}
+
+	t.Run("should sum the quantities of products in both carts", func(t *testing.T) {
+		got := quantities(mergeLines(userLines, guestLines, stock, MergeStrategySum, false))
+		if got[1] != 5 || got[2] != 5 || got[3] != 1 {
+			t.Errorf("unexpected quantities %v", got)
+		}
+	})
+
+	t.Run("should keep the item changed last", func(t *testing.T) {
+		got := quantities(mergeLines(userLines, guestLines, stock, MergeStrategyLatest, false))
+		// Product 2 was changed last in the user's cart, it is left as is.
+		if len(got) != 2 || got[1] != 3 || got[3] != 1 {
+			t.Errorf("unexpected quantities %v", got)
+		}
+	})
+
+	t.Run("should cap merged quantities at the stock", func(t *testing.T) {
+		got := quantities(mergeLines(userLines, guestLines, map[itemKey]int{{1, 0}: 4, {2, 0}: 10, {3, 0}: 10}, MergeStrategySum, true))
+		if got[1] != 4 {
+			t.Errorf("expected product 1 capped at 4, got %d", got[1])
+		}
+	})
+
+	t.Run("should not cap products out of stock", func(t *testing.T) {
+		got := quantities(mergeLines(nil, guestLines, map[itemKey]int{}, MergeStrategySum, true))
+		if got[1] != 3 {
+			t.Errorf("expected product 1 left at 3, got %d", got[1])
+		}
+	})
+}
+
+func TestMergeGuestCart(t *testing.T) {
+	defer func(envs config.Config) { config.Envs = envs }(config.Envs)
+	config.Envs.CartMergeStrategy = MergeStrategySum
+	config.Envs.CartMergeCapAtStock = true
+
+	productStore := &mockProductStore{products: []types.Product{{ID: 1, Quantity: 10}}}
+
+	t.Run("should move the guest's items & delete the guest cart", func(t *testing.T) {
+		cartStore := &mockCartStore{
+			lines:      []types.CartLine{{ProductID: 1, Quantity: 2}},
+			guest:      true,
+			guestLines: []types.CartLine{{ProductID: 1, Quantity: 3}},
+		}
+		merger := NewMerger(cartStore, productStore, &mockVariantStore{}, &mockTransactor{})
+
+		if err := merger.MergeGuestCart(context.Background(), "token", 1); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(cartStore.set) != 1 || cartStore.set[0].Quantity != 5 {
+			t.Errorf("expected product 1 set to 5, got %+v", cartStore.set)
+		}
+		if len(cartStore.deleted) != 1 || cartStore.deleted[0] != 2 {
+			t.Errorf("expected guest cart 2 deleted, got %v", cartStore.deleted)
+		}
+	})
+
+	t.Run("should ignore unknown tokens", func(t *testing.T) {
+		cartStore := &mockCartStore{}
+		merger := NewMerger(cartStore, productStore, &mockVariantStore{}, &mockTransactor{})
+
+		if err := merger.MergeGuestCart(context.Background(), "gone", 1); err != nil {
+			t.Fatal(err)
+		}
+		if len(cartStore.set) != 0 || len(cartStore.deleted) != 0 {
+			t.Error("expected nothing to be merged")
+		}
+	})
+}
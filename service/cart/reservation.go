@@ -0,0 +1,175 @@
+package cart
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/service/warehouse"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// How long a reservation holds stock (`RESERVATION_TTL_MINUTES`).
+func reservationTTL() time.Duration {
+	return time.Duration(config.Envs.ReservationTTLInMinutes) * time.Minute
+}
+
+// Set each product's `Available` to its stock minus the quantities
+// reserved for other carts & orders.
+func applyReservations(products map[int]types.Product, reserved map[int]int) {
+	for id, product := range products {
+		product.Available = max(product.Quantity-reserved[id], 0)
+		products[id] = product
+	}
+}
+
+// Same as `applyReservations()` for variants.
+func applyVariantReservations(variants map[int]types.Variant, reserved map[int]int) {
+	for id, variant := range variants {
+		variant.Available = max(variant.Quantity-reserved[id], 0)
+		variants[id] = variant
+	}
+}
+
+// Reserve the saved cart's items for `RESERVATION_TTL_MINUTES`, replacing
+// earlier reservations of the cart. Fails like checkout would if the items
+// aren't available. Returns the cart's reservations.
+func (h *Handler) reserveCart(ctx context.Context, cartID int) ([]types.StockReservation, error) {
+	err := h.transactor.WithTx(ctx, func(tx *sql.Tx) error {
+		lines, err := h.cartStore.GetCartLinesForUpdate(tx, cartID)
+		if err != nil {
+			return err
+		}
+		items := cartLineItems(lines)
+
+		productIDs, err := getCartItemIDs(items)
+		if err != nil {
+			return err
+		}
+
+		// Same locks as checkout: products first, then their reservations.
+		ps, err := h.productStore.GetProductByIDsForUpdate(tx, productIDs)
+		if err != nil {
+			return err
+		}
+		productMap := make(map[int]types.Product)
+		for _, product := range ps {
+			productMap[product.ID] = product
+		}
+
+		reserved, err := h.reservationStore.GetReservedQuantitiesTx(tx, productIDs, cartID, 0)
+		if err != nil {
+			return err
+		}
+		applyReservations(productMap, reserved)
+
+		vs, err := h.variantStore.GetVariantsByProductIDsTx(tx, productIDs)
+		if err != nil {
+			return err
+		}
+		variants, variantIDs := variantsByID(vs)
+		reservedVariants, err := h.reservationStore.GetReservedVariantQuantitiesTx(tx, variantIDs, cartID, 0)
+		if err != nil {
+			return err
+		}
+		applyVariantReservations(variants, reservedVariants)
+
+		if err := checkIfCartIsInStock(items, productMap, variants); err != nil {
+			return err
+		}
+
+		if err := h.reservationStore.DeleteCartReservationsTx(tx, cartID); err != nil {
+			return err
+		}
+
+		expiresAt := time.Now().Add(reservationTTL())
+		for _, item := range items {
+			err := h.reservationStore.CreateReservationTx(tx, types.StockReservation{
+				ProductID: item.ProductID,
+				VariantID: item.VariantID,
+				CartID:    cartID,
+				Quantity:  item.Quantity,
+				ExpiresAt: expiresAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return h.reservationStore.GetCartReservations(cartID)
+}
+
+// Release the reservations of the saved cart.
+func (h *Handler) releaseCart(ctx context.Context, cartID int) error {
+	return h.transactor.WithTx(ctx, func(tx *sql.Tx) error {
+		return h.reservationStore.DeleteCartReservationsTx(tx, cartID)
+	})
+}
+
+// Pick the warehouses the items ship from (see `warehouse.Allocate()`), as part
+// of `tx`. The products must already be locked & checked by the caller.
+func (h *Handler) allocateItems(tx *sql.Tx, productIDs []int, items []types.CartItem) ([][]types.StockAllocation, error) {
+	warehouses, err := h.warehouseStore.GetWarehouses()
+	if err != nil {
+		return nil, err
+	}
+
+	stock, err := h.warehouseStore.GetProductStockForUpdate(tx, productIDs, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return warehouse.Allocate(items, warehouses, stock, config.Envs.StockAllocationStrategy, config.Envs.StockAllocationAllowSplit)
+}
+
+// Reserve an order's items at the warehouses they were allocated to until it
+// is paid, as part of `tx`. The products must already be locked & checked by the caller.
+func (h *Handler) reserveForOrder(tx *sql.Tx, orderID int, allocations [][]types.StockAllocation) error {
+	// Total quantity per product (variant) & warehouse, the same product may be listed more than once.
+	type key struct{ productID, variantID, warehouseID int }
+	quantities := make(map[key]int)
+	for _, itemAllocations := range allocations {
+		for _, a := range itemAllocations {
+			quantities[key{a.ProductID, a.VariantID, a.WarehouseID}] += a.Quantity
+		}
+	}
+
+	keys := make([]key, 0, len(quantities))
+	for k := range quantities {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].productID != keys[j].productID {
+			return keys[i].productID < keys[j].productID
+		}
+		if keys[i].variantID != keys[j].variantID {
+			return keys[i].variantID < keys[j].variantID
+		}
+		return keys[i].warehouseID < keys[j].warehouseID
+	})
+
+	expiresAt := time.Now().Add(reservationTTL())
+	for _, k := range keys {
+		err := h.reservationStore.CreateReservationTx(tx, types.StockReservation{
+			ProductID:   k.productID,
+			VariantID:   k.variantID,
+			OrderID:     orderID,
+			WarehouseID: k.warehouseID,
+			Quantity:    quantities[k],
+			ExpiresAt:   expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package cart
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestReserveCart(t *testing.T) {
+	productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "pen", Price: types.NewMoney(1000), Quantity: 5}}}
+	cartStore := &mockCartStore{lines: []types.CartLine{{ProductID: 1, Quantity: 2}}}
+
+	t.Run("should replace the cart's reservations", func(t *testing.T) {
+		reservationStore := &mockReservationStore{}
+		handler := NewHandler(cartStore, nil, nil, productStore, &mockVariantStore{}, nil, nil, reservationStore, nil, nil, &mockTransactor{})
+
+		reservations, err := handler.reserveCart(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Contains(reservationStore.released, 1) {
+			t.Error("expected earlier reservations to be released")
+		}
+		if len(reservations) != 1 || reservations[0].Quantity != 2 || !reservations[0].ExpiresAt.After(time.Now()) {
+			t.Errorf("unexpected reservations %+v", reservations)
+		}
+	})
+
+	t.Run("should not reserve stock reserved by others", func(t *testing.T) {
+		reservationStore := &mockReservationStore{reserved: map[int]int{1: 4}}
+		handler := NewHandler(cartStore, nil, nil, productStore, &mockVariantStore{}, nil, nil, reservationStore, nil, nil, &mockTransactor{})
+
+		if _, err := handler.reserveCart(context.Background(), 1); err == nil {
+			t.Fatal("expected out of stock error")
+		}
+		if len(reservationStore.created) != 0 {
+			t.Errorf("expected no reservations, got %+v", reservationStore.created)
+		}
+	})
+}
@@ -1,27 +1,53 @@
 package cart
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
 	"github.com/gitKashish/ecommerce-api-go/types"
 	"github.com/gitKashish/ecommerce-api-go/utils"
 	"github.com/go-playground/validator/v10"
 )
 
 type Handler struct {
-	orderStore   types.OrderStore
-	userStore    types.UserStore
-	productStore types.ProductStore
+	cartStore        types.CartStore
+	orderStore       types.OrderStore
+	userStore        types.UserStore
+	productStore     types.ProductStore
+	variantStore     types.VariantStore
+	addressStore     types.AddressStore
+	couponStore      types.CouponStore
+	reservationStore types.ReservationStore
+	warehouseStore   types.WarehouseStore
+	idempotencyStore types.IdempotencyStore
+	transactor       types.Transactor
 }
 
-func NewHandler(orderStore types.OrderStore, userStore types.UserStore, productStore types.ProductStore) *Handler {
-	return &Handler{orderStore: orderStore, userStore: userStore, productStore: productStore}
+func NewHandler(cartStore types.CartStore, orderStore types.OrderStore, userStore types.UserStore, productStore types.ProductStore, variantStore types.VariantStore, addressStore types.AddressStore, couponStore types.CouponStore, reservationStore types.ReservationStore, warehouseStore types.WarehouseStore, idempotencyStore types.IdempotencyStore, transactor types.Transactor) *Handler {
+	return &Handler{cartStore: cartStore, orderStore: orderStore, userStore: userStore, productStore: productStore, variantStore: variantStore, addressStore: addressStore, couponStore: couponStore, reservationStore: reservationStore, warehouseStore: warehouseStore, idempotencyStore: idempotencyStore, transactor: transactor}
 }
 
 func (h *Handler) RegisterRoutes(router *http.ServeMux) {
-	router.HandleFunc("POST /cart/checkout", auth.WithJWTAuth(h.handleCheckout, h.userStore))
+	// Saved cart of the authenticated user, or of a guest (see `getRequestCart()`).
+	// `Idempotency-Key` is only honoured for authenticated users.
+	router.HandleFunc("GET /cart", auth.WithOptionalJWTAuth(h.handleGetCart, h.userStore))
+	router.HandleFunc("POST /cart/items", auth.WithOptionalJWTAuth(idempotency.WithIdempotencyKey(h.handleAddCartItem, h.idempotencyStore), h.userStore))
+	// Items of products with variants are addressed with `?variantID=`.
+	router.HandleFunc("PATCH /cart/items/{productID}", auth.WithOptionalJWTAuth(idempotency.WithIdempotencyKey(h.handleUpdateCartItem, h.idempotencyStore), h.userStore))
+	router.HandleFunc("DELETE /cart/items/{productID}", auth.WithOptionalJWTAuth(idempotency.WithIdempotencyKey(h.handleRemoveCartItem, h.idempotencyStore), h.userStore))
+	// Holding the cart's stock for `RESERVATION_TTL_MINUTES`, e.g. during a payment step.
+	router.HandleFunc("POST /cart/reservation", auth.WithOptionalJWTAuth(idempotency.WithIdempotencyKey(h.handleReserveCart, h.idempotencyStore), h.userStore))
+	router.HandleFunc("DELETE /cart/reservation", auth.WithOptionalJWTAuth(idempotency.WithIdempotencyKey(h.handleReleaseCart, h.idempotencyStore), h.userStore))
+
+	// Retried checkouts carrying the same `Idempotency-Key` replay the first order instead of creating a new one.
+	router.HandleFunc("POST /cart/checkout", auth.WithJWTAuth(idempotency.WithIdempotencyKey(h.handleCheckout, h.idempotencyStore), h.userStore))
+	// Prices the cart without checking out, safe to call on every cart change.
+	router.HandleFunc("POST /cart/quote", auth.WithOptionalJWTAuth(h.handleQuote, h.userStore))
 }
 
 // Handler Functions for performing checkout operations.
@@ -44,30 +70,360 @@ func (h *Handler) handleCheckout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Getting only productIDs from cart items.
-	productIDs, err := getCartItemIDs(cart.Items)
+	// Picking the shipping & billing addresses copied onto the order.
+	shippingAddress, billingAddress, err := h.resolveAddresses(userId, cart)
+	if err != nil {
+		utils.WriteError(w, checkoutErrorStatus(err), err)
+		return
+	}
+
+	req := checkoutRequest{
+		userID:          userId,
+		items:           cart.Items,
+		couponCodes:     cart.CouponCodes,
+		shippingAddress: shippingAddress,
+		billingAddress:  billingAddress,
+	}
+
+	// No items sent, checking out the saved cart.
+	if len(cart.Items) == 0 {
+		savedCart, err := h.cartStore.GetCartByUserID(userId)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		req.cartID = savedCart.ID
+	}
+
+	// Creating order record in `orders` table.
+	// Stock check, coupons, stock update & order creation run in a single transaction.
+	order, discounts, err := h.createOrder(r.Context(), req)
 	if err != nil {
+		utils.WriteError(w, checkoutErrorStatus(err), err)
+		return
+	}
+
+	// Responding on successful checkout.
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"subtotal":    order.Subtotal,
+		"discounts":   discounts,
+		"discount":    order.Discount,
+		"shipping":    order.Shipping,
+		"tax":         order.Tax,
+		"total_price": order.Total,
+		"order_id":    order.ID,
+	})
+}
+
+// HandlerFunc pricing a cart (line prices, discounts, shipping, tax & total)
+// along with stock warnings. Nothing is reserved, ordered or written.
+func (h *Handler) handleQuote(w http.ResponseWriter, r *http.Request) {
+	var payload types.CartQuotePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	// Getting list of `types.Product` for product details of cart items from DB.
-	ps, err := h.productStore.GetProductByIDs(productIDs)
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload : %v", errors))
+		return
+	}
+
+	userID := auth.GetUseIDFromContext(r.Context())
+
+	// No items sent, pricing the saved cart.
+	items := payload.Items
+	cartID := 0
+	if len(items) == 0 {
+		cart, ok := h.getRequestCart(w, r, false)
+		if !ok {
+			return
+		}
+		cartID = cart.ID
+
+		lines, err := h.cartStore.GetCartLines(cart.ID)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		items = cartLineItems(lines)
+	}
+
+	quote, err := h.quoteCart(userID, cartID, items, payload.CouponCodes)
 	if err != nil {
+		utils.WriteError(w, checkoutErrorStatus(err), err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, quote)
+}
+
+// HandlerFunc to get the saved cart, priced at current prices.
+func (h *Handler) handleGetCart(w http.ResponseWriter, r *http.Request) {
+	cart, ok := h.getRequestCart(w, r, false)
+	if !ok {
+		return
+	}
+
+	h.writeCartView(w, cart, http.StatusOK)
+}
+
+// HandlerFunc to add a product (variant) to the saved cart.
+// A guest's first item creates their cart, its token is in the response.
+func (h *Handler) handleAddCartItem(w http.ResponseWriter, r *http.Request) {
+	var payload types.AddCartItemPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	// Creating order record in `orders` table.
-	orderID, totalPrice, err := h.createOrder(ps, cart.Items, userId)
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload : %v", errors))
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(payload.ProductID)
+	if errors.Is(err, types.ErrProductNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
 	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	price, err := h.variantPrice(product, payload.VariantID)
+	if errors.Is(err, types.ErrVariantNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, types.ErrVariantRequired) {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
 
-	// Responding on successful checkout.
-	utils.WriteJSON(w, http.StatusOK, map[string]any{
-		"total_price": totalPrice,
-		"order_id":    orderID,
-	})
+	cart, ok := h.getRequestCart(w, r, true)
+	if !ok {
+		return
+	}
+
+	// Stock isn't checked here, the cart's quote warns about it.
+	if err := h.cartStore.AddCartItem(cart.ID, product.ID, payload.VariantID, payload.Quantity, price); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeCartView(w, cart, http.StatusOK)
+}
+
+// HandlerFunc to change the quantity of a product (variant) in the saved cart.
+func (h *Handler) handleUpdateCartItem(w http.ResponseWriter, r *http.Request) {
+	productID, variantID, ok := parseCartItemPath(w, r)
+	if !ok {
+		return
+	}
+
+	var payload types.UpdateCartItemPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload : %v", errors))
+		return
+	}
+
+	cart, ok := h.getRequestCart(w, r, false)
+	if !ok {
+		return
+	}
+
+	err := h.cartStore.UpdateCartItem(cart.ID, productID, variantID, payload.Quantity)
+	if errors.Is(err, types.ErrCartItemNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeCartView(w, cart, http.StatusOK)
+}
+
+// HandlerFunc to remove a product (variant) from the saved cart.
+func (h *Handler) handleRemoveCartItem(w http.ResponseWriter, r *http.Request) {
+	productID, variantID, ok := parseCartItemPath(w, r)
+	if !ok {
+		return
+	}
+
+	cart, ok := h.getRequestCart(w, r, false)
+	if !ok {
+		return
+	}
+
+	err := h.cartStore.RemoveCartItem(cart.ID, productID, variantID)
+	if errors.Is(err, types.ErrCartItemNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlerFunc to reserve the saved cart's items, replacing earlier reservations.
+// Reserving again before they expire extends them.
+func (h *Handler) handleReserveCart(w http.ResponseWriter, r *http.Request) {
+	cart, ok := h.getRequestCart(w, r, false)
+	if !ok {
+		return
+	}
+
+	reservations, err := h.reserveCart(r.Context(), cart.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, reservations)
+}
+
+// HandlerFunc to release the saved cart's reservations.
+func (h *Handler) handleReleaseCart(w http.ResponseWriter, r *http.Request) {
+	cart, ok := h.getRequestCart(w, r, false)
+	if !ok {
+		return
+	}
+
+	if err := h.releaseCart(r.Context(), cart.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Util function to parse the cart item a request addresses: the `{productID}`
+// path wildcard & the optional `variantID` query parameter (0 without).
+// Writes the error response itself and returns false if either is invalid.
+func parseCartItemPath(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	productID, err := strconv.Atoi(r.PathValue("productID"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id %q", r.PathValue("productID")))
+		return 0, 0, false
+	}
+
+	variantID := 0
+	if v := r.URL.Query().Get("variantID"); v != "" {
+		variantID, err = strconv.Atoi(v)
+		if err != nil || variantID < 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid variant id %q", v))
+			return 0, 0, false
+		}
+	}
+
+	return productID, variantID, true
+}
+
+// Util function to get the cart a request acts on: the authenticated user's
+// cart, else the guest cart named by the `X-Cart-Token` header (kept for
+// another `GUEST_CART_TTL_DAYS` from then on).
+// With `create` a guest without a (known) token gets a new cart, its token is
+// sent in the `X-Cart-Token` response header & the cart's `token`.
+// Writes the error response itself and returns false if there is no cart.
+func (h *Handler) getRequestCart(w http.ResponseWriter, r *http.Request, create bool) (*types.Cart, bool) {
+	if userID := auth.GetUseIDFromContext(r.Context()); userID != -1 {
+		cart, err := h.cartStore.GetCartByUserID(userID)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return nil, false
+		}
+		return cart, true
+	}
+
+	if token := r.Header.Get(types.CartTokenHeader); token != "" {
+		cart, err := h.cartStore.GetCartByTokenHash(hashCartToken(token))
+		if err == nil {
+			// Guest carts in use don't expire.
+			expiresAt := time.Now().Add(guestCartTTL())
+			if err := h.cartStore.ExtendGuestCart(cart.ID, expiresAt); err != nil {
+				utils.WriteError(w, http.StatusInternalServerError, err)
+				return nil, false
+			}
+			cart.ExpiresAt = &expiresAt
+			return cart, true
+		}
+		if !errors.Is(err, types.ErrCartNotFound) {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return nil, false
+		}
+	}
+
+	if !create {
+		utils.WriteError(w, http.StatusNotFound, types.ErrCartNotFound)
+		return nil, false
+	}
+
+	token, err := newCartToken()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	cart, err := h.cartStore.CreateGuestCart(hashCartToken(token), time.Now().Add(guestCartTTL()))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	cart.Token = token
+	w.Header().Set(types.CartTokenHeader, token)
+
+	return cart, true
+}
+
+// Util function writing a saved cart along with its quote.
+func (h *Handler) writeCartView(w http.ResponseWriter, cart *types.Cart, status int) {
+	view, err := h.viewCart(cart)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, status, view)
+}
+
+// Status of a checkout (or quote) error. Only problems with what was sent are
+// the client's, anything else (e.g. a deadlock) is a server error, so an
+// `Idempotency-Key` is released & the checkout can be retried.
+func checkoutErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrOutOfStock):
+		return http.StatusConflict
+	case errors.Is(err, errAddressNotFound),
+		errors.Is(err, types.ErrProductNotFound),
+		errors.Is(err, types.ErrVariantNotFound),
+		errors.Is(err, types.ErrCouponNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errNoShippingAddress),
+		errors.Is(err, errEmptyCart),
+		errors.Is(err, errInvalidQuantity),
+		errors.Is(err, types.ErrVariantRequired),
+		errors.Is(err, types.ErrInvalidCoupon):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
 }
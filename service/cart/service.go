@@ -1,11 +1,25 @@
 package cart
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/service/coupon"
 	"github.com/gitKashish/ecommerce-api-go/types"
 )
 
+// Errors of checkouts that can't be fulfilled as sent, see `checkoutErrorStatus()`.
+var (
+	errAddressNotFound   = errors.New("address not found")
+	errNoShippingAddress = errors.New("no shipping address given and no default address saved")
+	errEmptyCart         = errors.New("cart is empty")
+	errInvalidQuantity   = errors.New("invalid quantity")
+)
+
 // Return a list of only cart items & provides some validation(mentioned below).
 // Just a utility function `types.CartItem` already has ProductID.
 func getCartItemIDs(items []types.CartItem) ([]int, error) {
@@ -17,7 +31,7 @@ func getCartItemIDs(items []types.CartItem) ([]int, error) {
 		// as they may cause absurd checkout calculations, leading to...
 		// negative quantities & 0 cost order items.
 		if item.Quantity <= 0 {
-			return nil, fmt.Errorf("invalid quantity for the product %d", item.ProductID)
+			return nil, fmt.Errorf("%w for the product %d", errInvalidQuantity, item.ProductID)
 		}
 		productIDs[i] = item.ProductID
 	}
@@ -25,100 +39,631 @@ func getCartItemIDs(items []types.CartItem) ([]int, error) {
 	return productIDs, nil
 }
 
+// What is being checked out & where it ships to.
+type checkoutRequest struct {
+	userID      int
+	items       []types.CartItem
+	couponCodes []string
+	// Address snapshots (see `types.Address.Format()`).
+	shippingAddress string
+	billingAddress  string
+	// Saved cart checked out instead of `items` (0 if items were sent), emptied by the checkout.
+	cartID int
+}
+
 // Create order record in DB.
 // Takes in Handler as receiver beacuse it need acess to a lot of other stores & types.
-func (h *Handler) createOrder(ps []types.Product, items []types.CartItem, userID int) (int, float64, error) {
-	// General Flow:
+func (h *Handler) createOrder(ctx context.Context, req checkoutRequest) (*types.Order, []types.OrderDiscount, error) {
+	// General Flow (all inside one DB transaction):
 	/*
-		1. All the cart items are in stock?
+		0. Load the saved cart's items (if checking out the saved cart).
+		1. Lock the cart's product rows (`SELECT ... FOR UPDATE`) & their reservations.
+		2. All the cart items are available (in stock & not reserved by others)
+		   and can be allocated to warehouses (`STOCK_ALLOCATION_STRATEGY`)?
 		TRUE:
-			1. Calculate the total price.
-			2. Reduce each items quantity in the DB (uses UpdateProduct() method).
-			3. Create the order record in DB.
-			4. Create order items record for each cart item.
-			5. return orderID & total price.
+			1. Calculate the subtotal.
+			2. Redeem coupons (if any) & take their discounts off.
+			3. Add shipping & tax.
+			4. Create the order record in DB.
+			5. Create order items (one per item & warehouse) & discount records.
+			6. Reserve the items at their warehouses for the order, stock is taken once it is paid.
+			7. Empty the saved cart & release its reservations (if checked out).
+			8. Commit & return the order with its discounts.
 		FALSE:
-			1. return orderID: 0 & total_price: 0. Along with error.
+			1. Rollback & return the error.
 	*/
+	couponCodes, err := coupon.NormalizeCodes(req.couponCodes)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Product Map created for quick lookup to entire...
-	// `types.Product` struct using only Product ID of cart Items.
-	productMap := make(map[int]types.Product)
+	userID := req.userID
+	order := types.Order{
+		UserID: userID,
+		Status: types.OrderStatusPending,
+		// Snapshots, later address book edits don't change the order.
+		Address:        req.shippingAddress,
+		BillingAddress: req.billingAddress,
+	}
+	var discounts []types.OrderDiscount
 
-	// Initializing Product Map
-	for _, product := range ps {
-		productMap[product.ID] = product
+	err = h.transactor.WithTx(ctx, func(tx *sql.Tx) error {
+		items := req.items
+		if req.cartID != 0 {
+			// Saved prices are ignored, products are priced below as they are now.
+			lines, err := h.cartStore.GetCartLinesForUpdate(tx, req.cartID)
+			if err != nil {
+				return err
+			}
+			items = cartLineItems(lines)
+		}
+
+		productIDs, err := getCartItemIDs(items)
+		if err != nil {
+			return err
+		}
+
+		// Row locks are held until commit/rollback, so a concurrent checkout...
+		// for the same products waits here and then sees the updated stock.
+		ps, err := h.productStore.GetProductByIDsForUpdate(tx, productIDs)
+		if err != nil {
+			return err
+		}
+
+		// Product Map created for quick lookup to entire...
+		// `types.Product` struct using only Product ID of cart Items.
+		productMap := make(map[int]types.Product)
+
+		// Initializing Product Map
+		for _, product := range ps {
+			productMap[product.ID] = product
+		}
+
+		// The saved cart's own reservations don't hold stock against it.
+		reserved, err := h.reservationStore.GetReservedQuantitiesTx(tx, productIDs, req.cartID, 0)
+		if err != nil {
+			return err
+		}
+		applyReservations(productMap, reserved)
+
+		// Variants are read after their products are locked, variant stock
+		// only moves along with the product's.
+		vs, err := h.variantStore.GetVariantsByProductIDsTx(tx, productIDs)
+		if err != nil {
+			return err
+		}
+		variants, variantIDs := variantsByID(vs)
+		reservedVariants, err := h.reservationStore.GetReservedVariantQuantitiesTx(tx, variantIDs, req.cartID, 0)
+		if err != nil {
+			return err
+		}
+		applyVariantReservations(variants, reservedVariants)
+
+		// Check if a product is in Stock.
+		if err := checkIfCartIsInStock(items, productMap, variants); err != nil {
+			return err
+		}
+
+		allocations, err := h.allocateItems(tx, productIDs, items)
+		if err != nil {
+			return err
+		}
+
+		// Calculate the total price.
+		prices := unitPrices(items, productMap, variants)
+		order.Subtotal = calculateTotalPrice(items, prices)
+
+		discounts, err = h.applyCoupons(tx, couponCodes, userID, items, prices)
+		if err != nil {
+			return err
+		}
+		order.Discount = coupon.Total(discounts)
+
+		order.Shipping, order.Tax, err = calculateCharges(order.Subtotal, order.Discount)
+		if err != nil {
+			return err
+		}
+		order.Total = order.Subtotal.Sub(order.Discount).Add(order.Shipping).Add(order.Tax)
+
+		// Create the order.
+		order.ID, err = h.orderStore.CreateOrderTx(tx, order)
+		if err != nil {
+			return err
+		}
+
+		// First entry of the order's status history.
+		err = h.orderStore.CreateOrderStatusChangeTx(tx, types.OrderStatusChange{
+			OrderID:   order.ID,
+			ToStatus:  types.OrderStatusPending,
+			ChangedBy: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Create the OrderItems. For each cart Item & warehouse it ships from.
+		for i, item := range items {
+			name, image, sku := itemSnapshot(item, productMap[item.ProductID], variants)
+			for _, a := range allocations[i] {
+				err := h.orderStore.CreateOrderItemTx(tx, types.OrderItem{
+					OrderID:      order.ID,
+					ProductID:    item.ProductID,
+					VariantID:    item.VariantID,
+					SKU:          sku,
+					WarehouseID:  a.WarehouseID,
+					Quantity:     a.Quantity,
+					Price:        prices[i],
+					ProductName:  name,
+					ProductImage: image,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// Recording the applied coupons, they count towards usage limits from now on.
+		for i := range discounts {
+			discounts[i].OrderID = order.ID
+			if err := h.orderStore.CreateOrderDiscountTx(tx, discounts[i]); err != nil {
+				return err
+			}
+		}
+
+		// Holding the stock until the order is paid (see `order.Handler.changeStatus()`).
+		if err := h.reserveForOrder(tx, order.ID, allocations); err != nil {
+			return err
+		}
+
+		// The saved cart was ordered, emptying it.
+		if req.cartID != 0 {
+			if err := h.reservationStore.DeleteCartReservationsTx(tx, req.cartID); err != nil {
+				return err
+			}
+			return h.cartStore.ClearCartTx(tx, req.cartID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	// Check if a product is in Stock.
-	if err := checkIfCartIsInStock(items, productMap); err != nil {
-		return 0, 0, err
+
+	return &order, discounts, nil
+}
+
+// A saved cart, quoted at current prices.
+// Products whose price changed since they were added are listed in `PriceChanged`.
+func (h *Handler) viewCart(cart *types.Cart) (*types.CartView, error) {
+	lines, err := h.cartStore.GetCartLines(cart.ID)
+	if err != nil {
+		return nil, err
 	}
-	// Calculate the total price.
-	totalPrice := calculateTotalPrice(items, productMap)
 
-	// Reduce the quantity of product.
-	for _, item := range items {
-		product := productMap[item.ProductID]
-		product.Quantity -= item.Quantity
-
-		h.productStore.UpdateProduct(product) // Updating records in DB.
-	}
-	// Create the order.
-	orderID, err := h.orderStore.CreateOrder(types.Order{
-		UserID:  userID,
-		Total:   totalPrice,
-		Status:  "pending",
-		Address: "some address", // TODO : Get from http.Request.
-		// TODO : Maintain a table for each user to store multiple addresses.
+	quote, err := h.quoteCart(cart.UserID, cart.ID, cartLineItems(lines), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &types.CartView{Cart: *cart, Items: lines, PriceChanged: []int{}, Quote: quote}
+	for i, l := range lines {
+		// Quote lines are in cart order, products that are gone have no price.
+		if q := quote.Lines[i]; q.Name != "" && q.UnitPrice != l.PriceAtAdd {
+			view.PriceChanged = append(view.PriceChanged, l.ProductID)
+		}
+	}
+
+	return view, nil
+}
+
+// Checkout lines of a saved cart.
+func cartLineItems(lines []types.CartLine) []types.CartItem {
+	items := make([]types.CartItem, len(lines))
+	for i, l := range lines {
+		items[i] = types.CartItem{ProductID: l.ProductID, VariantID: l.VariantID, Quantity: l.Quantity}
+	}
+	return items
+}
+
+// Look up & validate the coupons of a checkout, returning their discounts.
+// Coupon rows stay locked until `tx` ends, so two checkouts can't both
+// redeem the last use of a limited coupon.
+func (h *Handler) applyCoupons(tx *sql.Tx, codes []string, userID int, items []types.CartItem, prices []types.Money) ([]types.OrderDiscount, error) {
+	if len(codes) == 0 {
+		return []types.OrderDiscount{}, nil
+	}
+
+	coupons, err := h.couponStore.GetCouponsByCodesForUpdate(tx, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	return redeemCoupons(codes, coupons, items, prices, func(couponID int) (int, int, error) {
+		return h.couponStore.GetCouponUsageTx(tx, couponID, userID)
 	})
+}
+
+// Same as `applyCoupons()` but outside of a transaction & without locking, for quotes.
+func (h *Handler) previewCoupons(codes []string, userID int, items []types.CartItem, prices []types.Money) ([]types.OrderDiscount, error) {
+	if len(codes) == 0 {
+		return []types.OrderDiscount{}, nil
+	}
 
+	coupons, err := h.couponStore.GetCouponsByCodes(codes)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
 
-	// Create the OrderItems. For each cart Item.
-	for _, item := range items {
-		h.orderStore.CreateOrderItem(types.OrderItem{
-			OrderID:   orderID,
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     productMap[item.ProductID].Price,
-		})
+	return redeemCoupons(codes, coupons, items, prices, func(couponID int) (int, int, error) {
+		return h.couponStore.GetCouponUsage(couponID, userID)
+	})
+}
+
+// Check the coupons found for `codes` can be redeemed (`usage` counts past
+// redemptions of a coupon, overall & by the user) and work out their discounts
+// (`prices` are the items' unit prices).
+func redeemCoupons(codes []string, coupons []types.Coupon, items []types.CartItem, prices []types.Money, usage func(couponID int) (int, int, error)) ([]types.OrderDiscount, error) {
+	found := make(map[string]bool, len(coupons))
+	for _, c := range coupons {
+		found[c.Code] = true
+	}
+	for _, code := range codes {
+		if !found[code] {
+			return nil, fmt.Errorf("%w: %q", types.ErrCouponNotFound, code)
+		}
+	}
+
+	now := time.Now()
+	for _, c := range coupons {
+		used, usedByUser, err := usage(c.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := coupon.CheckRedeemable(c, now, used, usedByUser); err != nil {
+			return nil, err
+		}
 	}
 
-	return orderID, totalPrice, nil
+	return coupon.Apply(coupons, items, prices)
 }
 
-// Util function to calculate total_price. Utilizes Product map for quick lookup.
-func calculateTotalPrice(items []types.CartItem, products map[int]types.Product) (total float64) {
-	for _, item := range items {
-		product := products[item.ProductID]
-		total += product.Price * float64(item.Quantity)
+// Pick the shipping & billing addresses for a checkout and return their snapshots.
+// Shipping: the inline address, else the referenced saved address, else the
+// user's default shipping address. Billing: the user's default billing address,
+// else the shipping address.
+func (h *Handler) resolveAddresses(userID int, payload types.CartCheckoutPayload) (string, string, error) {
+	var shipping *types.Address
+
+	switch {
+	case payload.Address != nil:
+		a := payload.Address.ToAddress(userID)
+		shipping = &a
+	case payload.AddressID != 0:
+		a, err := h.addressStore.GetAddressByID(payload.AddressID)
+		if errors.Is(err, types.ErrAddressNotFound) || (err == nil && a.UserID != userID) {
+			return "", "", errAddressNotFound
+		}
+		if err != nil {
+			return "", "", err
+		}
+		shipping = a
+	}
+
+	// Looking up defaults from the address book.
+	addresses, err := h.addressStore.GetAddressesByUserID(userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	var billing *types.Address
+	for i := range addresses {
+		if addresses[i].IsDefaultShipping && shipping == nil {
+			shipping = &addresses[i]
+		}
+		if addresses[i].IsDefaultBilling {
+			billing = &addresses[i]
+		}
+	}
+
+	if shipping == nil {
+		return "", "", errNoShippingAddress
+	}
+	if billing == nil {
+		billing = shipping
+	}
+
+	return shipping.Format(), billing.Format(), nil
+}
+
+// Util function to calculate total_price from the items' unit prices (see `unitPrices()`).
+// Exact, prices are whole cents so the sum needs no rounding.
+func calculateTotalPrice(items []types.CartItem, prices []types.Money) (total types.Money) {
+	total = types.NewMoney(0)
+	for i, item := range items {
+		total = total.Add(prices[i].Mul(item.Quantity))
 	}
 
 	return total
 }
 
+// Unit price of a cart item: its variant's price if it has one, else the product's.
+func unitPrice(item types.CartItem, product types.Product, variants map[int]types.Variant) types.Money {
+	if v, ok := variants[item.VariantID]; ok && v.Price != nil {
+		return *v.Price
+	}
+	return product.Price
+}
+
+// Unit prices of the cart items, same order as `items`.
+func unitPrices(items []types.CartItem, products map[int]types.Product, variants map[int]types.Variant) []types.Money {
+	prices := make([]types.Money, len(items))
+	for i, item := range items {
+		prices[i] = unitPrice(item, products[item.ProductID], variants)
+	}
+	return prices
+}
+
+// Index variants by ID, also returning their IDs.
+func variantsByID(vs []types.Variant) (map[int]types.Variant, []int) {
+	variants := make(map[int]types.Variant, len(vs))
+	ids := make([]int, len(vs))
+	for i, v := range vs {
+		variants[v.ID] = v
+		ids[i] = v.ID
+	}
+	return variants, ids
+}
+
+// Price of a product (variant) added to the saved cart, see `unitPrice()`.
+// Returns the errors of `checkVariant()` if the variant doesn't fit the product.
+func (h *Handler) variantPrice(product *types.Product, variantID int) (types.Money, error) {
+	vs, err := h.variantStore.GetVariantsByProductID(product.ID)
+	if err != nil {
+		return types.Money{}, err
+	}
+
+	item := types.CartItem{ProductID: product.ID, VariantID: variantID}
+	variants, _ := variantsByID(vs)
+	if err := checkVariant(item, variants); err != nil {
+		return types.Money{}, err
+	}
+
+	return unitPrice(item, *product, variants), nil
+}
+
+// Check the variant of a cart item: products with active variants need one
+// of them (`types.ErrVariantRequired`), it must be active & of the product
+// (`types.ErrVariantNotFound`). `variants` holds the product's variants.
+func checkVariant(item types.CartItem, variants map[int]types.Variant) error {
+	if item.VariantID == 0 {
+		for _, v := range variants {
+			if v.ProductID == item.ProductID && v.Active {
+				return fmt.Errorf("%w: product %d", types.ErrVariantRequired, item.ProductID)
+			}
+		}
+		return nil
+	}
+
+	if v, ok := variants[item.VariantID]; !ok || v.ProductID != item.ProductID || !v.Active {
+		return fmt.Errorf("%w: %d of product %d", types.ErrVariantNotFound, item.VariantID, item.ProductID)
+	}
+	return nil
+}
+
+// Name, image & SKU copied onto the order item. Variants add their option
+// values to the name, e.g. "T-Shirt (M / Red)", and replace the image if they have one.
+func itemSnapshot(item types.CartItem, product types.Product, variants map[int]types.Variant) (name, image, sku string) {
+	v, ok := variants[item.VariantID]
+	if !ok {
+		return product.Name, product.Image, ""
+	}
+
+	name, image = product.Name, product.Image
+	if title := v.Title(); title != "" {
+		name = fmt.Sprintf("%s (%s)", product.Name, title)
+	}
+	if v.Image != "" {
+		image = v.Image
+	}
+	return name, image, v.SKU
+}
+
+// Shipping & tax charged on top of the discounted subtotal:
+//   - shipping is `SHIPPING_FEE`, waived from `FREE_SHIPPING_THRESHOLD` (if set) on.
+//   - tax is `TAX_RATE_BPS` basis points of the discounted subtotal (shipping
+//     is not taxed), rounded half away from zero to the cent.
+func calculateCharges(subtotal, discount types.Money) (shipping, tax types.Money, err error) {
+	net := subtotal.Sub(discount)
+
+	shipping = types.NewMoney(0)
+	if !subtotal.IsZero() {
+		shipping, err = types.ParseMoney(config.Envs.ShippingFee)
+		if err != nil {
+			return shipping, tax, fmt.Errorf("invalid SHIPPING_FEE: %w", err)
+		}
+	}
+	if config.Envs.FreeShippingThreshold != "" {
+		threshold, err := types.ParseMoney(config.Envs.FreeShippingThreshold)
+		if err != nil {
+			return shipping, tax, fmt.Errorf("invalid FREE_SHIPPING_THRESHOLD: %w", err)
+		}
+		if net.Amount >= threshold.Amount {
+			shipping = types.NewMoney(0)
+		}
+	}
+
+	tax = net.MulRatio(config.Envs.TaxRateBasisPoints, 10000)
+	return shipping, tax, nil
+}
+
 // Check stock and sanity of cart Items.
-func checkIfCartIsInStock(cartItems []types.CartItem, products map[int]types.Product) error {
+// `variants` holds the variants of the products, with their `Available` stock.
+func checkIfCartIsInStock(cartItems []types.CartItem, products map[int]types.Product, variants map[int]types.Variant) error {
 
 	// Empty cart cannot be processed.
 	if len(cartItems) == 0 {
-		return fmt.Errorf("cart is empty")
+		return errEmptyCart
+	}
+
+	// Total requested quantity per product...
+	// the same product may be listed more than once.
+	// Same for variants.
+	requested := make(map[int]int)
+	requestedVariants := make(map[int]int)
+	for _, item := range cartItems {
+		requested[item.ProductID] += item.Quantity
+		requestedVariants[item.VariantID] += item.Quantity
 	}
 
 	// If Product does not exists.
 	for _, item := range cartItems {
 		product, ok := products[item.ProductID]
 		if !ok {
-			return fmt.Errorf("product %d is not available in the store, please update your cart", item.ProductID)
+			return fmt.Errorf("%w: %d, please update your cart", types.ErrProductNotFound, item.ProductID)
+		}
+
+		if err := checkVariant(item, variants); err != nil {
+			return err
+		}
+		if v, ok := variants[item.VariantID]; ok && v.Available < requestedVariants[item.VariantID] {
+			return fmt.Errorf("%w: product %s (%s)", types.ErrOutOfStock, product.Name, v.SKU)
 		}
 
 		// Not enough stock in inventory.
-		if product.Quantity < item.Quantity {
-			return fmt.Errorf("product %s is not available in the inventory in the quantity requested", product.Name)
+		if product.Available < requested[item.ProductID] {
+			return fmt.Errorf("%w: product %s", types.ErrOutOfStock, product.Name)
 		}
 	}
 
 	return nil
 }
+
+// Price the cart like `createOrder()` would, without locking or writing anything.
+// Problems that would fail the checkout (stock, coupons) are reported as
+// warnings instead of errors, so the client can show them next to the cart.
+// Stock reserved by `cartID` (0 for none) counts as available.
+func (h *Handler) quoteCart(userID, cartID int, items []types.CartItem, couponCodes []string) (*types.CartQuote, error) {
+	productIDs, err := getCartItemIDs(items)
+	if err != nil {
+		return nil, err
+	}
+
+	couponCodes, err = coupon.NormalizeCodes(couponCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	ps, err := h.productStore.GetProductByIDs(productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	productMap := make(map[int]types.Product)
+	for _, product := range ps {
+		productMap[product.ID] = product
+	}
+
+	reserved, err := h.reservationStore.GetReservedQuantities(productIDs, cartID, 0)
+	if err != nil {
+		return nil, err
+	}
+	applyReservations(productMap, reserved)
+
+	vs, err := h.variantStore.GetVariantsByProductIDs(productIDs)
+	if err != nil {
+		return nil, err
+	}
+	variants, variantIDs := variantsByID(vs)
+	reservedVariants, err := h.reservationStore.GetReservedVariantQuantities(variantIDs, cartID, 0)
+	if err != nil {
+		return nil, err
+	}
+	applyVariantReservations(variants, reservedVariants)
+
+	quote := &types.CartQuote{
+		Lines:       make([]types.CartQuoteLine, 0, len(items)),
+		Discounts:   []types.OrderDiscount{},
+		Purchasable: true,
+		Warnings:    []string{},
+	}
+
+	// Same check checkout runs, its error is why checkout would fail.
+	if err := checkIfCartIsInStock(items, productMap, variants); err != nil {
+		quote.Purchasable = false
+		quote.Warnings = append(quote.Warnings, err.Error())
+	}
+
+	// Total requested quantity per product (variant), the same product may be listed more than once.
+	requested := make(map[int]int)
+	requestedVariants := make(map[int]int)
+	for _, item := range items {
+		requested[item.ProductID] += item.Quantity
+		requestedVariants[item.VariantID] += item.Quantity
+	}
+
+	// Unknown products & variants can't be priced, they are left out of the totals.
+	priced := make([]types.CartItem, 0, len(items))
+	prices := make([]types.Money, 0, len(items))
+	for _, item := range items {
+		line := types.CartQuoteLine{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+
+		product, ok := productMap[item.ProductID]
+		if !ok {
+			line.Warning = "product is no longer available"
+			quote.Lines = append(quote.Lines, line)
+			continue
+		}
+		if err := checkVariant(item, variants); err != nil {
+			line.Warning = err.Error()
+			quote.Lines = append(quote.Lines, line)
+			continue
+		}
+
+		price := unitPrice(item, product, variants)
+		available, wanted := product.Available, requested[item.ProductID]
+		if v, ok := variants[item.VariantID]; ok {
+			line.SKU = v.SKU
+			available, wanted = min(v.Available, available), requestedVariants[item.VariantID]
+		}
+
+		line.Name, _, _ = itemSnapshot(item, product, variants)
+		line.UnitPrice = price
+		line.LineTotal = price.Mul(item.Quantity)
+		line.Available = available
+		switch {
+		case available == 0:
+			line.Warning = "out of stock"
+		case available < wanted:
+			line.Warning = fmt.Sprintf("only %d left in stock", available)
+		}
+
+		quote.Lines = append(quote.Lines, line)
+		priced = append(priced, item)
+		prices = append(prices, price)
+	}
+
+	quote.Subtotal = calculateTotalPrice(priced, prices)
+
+	if len(priced) > 0 {
+		discounts, err := h.previewCoupons(couponCodes, userID, priced, prices)
+		if err != nil && !errors.Is(err, types.ErrCouponNotFound) && !errors.Is(err, types.ErrInvalidCoupon) {
+			return nil, err
+		}
+		if err != nil {
+			// Invalid coupons are dropped from the quote, checkout would reject them.
+			quote.Purchasable = false
+			quote.Warnings = append(quote.Warnings, err.Error())
+		} else {
+			quote.Discounts = discounts
+		}
+	}
+	quote.Discount = coupon.Total(quote.Discounts)
+
+	quote.Shipping, quote.Tax, err = calculateCharges(quote.Subtotal, quote.Discount)
+	if err != nil {
+		return nil, err
+	}
+	quote.Total = quote.Subtotal.Sub(quote.Discount).Add(quote.Shipping).Add(quote.Tax)
+
+	return quote, nil
+}
@@ -0,0 +1,679 @@
+package cart
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestCreateOrder(t *testing.T) {
+	t.Run("should fail without side effects if cart is out of stock", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "pen", Price: types.NewMoney(1000), Quantity: 3}}}
+		orderStore := &mockOrderStore{}
+		handler := NewHandler(nil, orderStore, nil, productStore, &mockVariantStore{}, nil, nil, &mockReservationStore{}, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+		// Same product twice, 2 + 2 > 3.
+		items := []types.CartItem{{ProductID: 1, Quantity: 2}, {ProductID: 1, Quantity: 2}}
+		_, _, err := handler.createOrder(context.Background(), checkoutRequest{userID: 1, items: items, shippingAddress: "shipping", billingAddress: "billing"})
+		if status := checkoutErrorStatus(err); err == nil || status != http.StatusConflict {
+			t.Fatalf("expected out of stock error with status code %d, got %v (%d)", http.StatusConflict, err, status)
+		}
+
+		if len(productStore.updated) != 0 || len(orderStore.orders) != 0 {
+			t.Errorf("expected no writes, got %d product updates & %d orders", len(productStore.updated), len(orderStore.orders))
+		}
+	})
+
+	t.Run("should reserve stock and create the order", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "pen", Price: types.NewMoney(1000), Quantity: 5}}}
+		orderStore := &mockOrderStore{}
+		reservationStore := &mockReservationStore{}
+		handler := NewHandler(nil, orderStore, nil, productStore, &mockVariantStore{}, nil, nil, reservationStore, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+		items := []types.CartItem{{ProductID: 1, Quantity: 2}, {ProductID: 1, Quantity: 1}}
+		order, _, err := handler.createOrder(context.Background(), checkoutRequest{userID: 1, items: items, shippingAddress: "shipping", billingAddress: "billing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if order.Total != types.NewMoney(3000) {
+			t.Errorf("expected total 30, got %v", order.Total)
+		}
+		// Stock is only taken once the order is paid.
+		if len(productStore.updated) != 0 {
+			t.Errorf("expected no product updates, got %d", len(productStore.updated))
+		}
+		if r := reservationStore.created; len(r) != 1 || r[0].OrderID != order.ID || r[0].Quantity != 3 {
+			t.Errorf("expected 3 reserved for the order, got %+v", r)
+		}
+		if len(orderStore.items) != 2 {
+			t.Errorf("expected 2 order items, got %d", len(orderStore.items))
+		}
+	})
+
+	t.Run("should not sell stock reserved by others", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "pen", Price: types.NewMoney(1000), Quantity: 5}}}
+		orderStore := &mockOrderStore{}
+		handler := NewHandler(nil, orderStore, nil, productStore, &mockVariantStore{}, nil, nil, &mockReservationStore{reserved: map[int]int{1: 4}}, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+		items := []types.CartItem{{ProductID: 1, Quantity: 2}}
+		if _, _, err := handler.createOrder(context.Background(), checkoutRequest{userID: 1, items: items, shippingAddress: "shipping", billingAddress: "billing"}); err == nil {
+			t.Fatal("expected out of stock error")
+		}
+		if len(orderStore.orders) != 0 {
+			t.Errorf("expected no orders, got %d", len(orderStore.orders))
+		}
+	})
+
+	t.Run("should split items across warehouses and record where they ship from", func(t *testing.T) {
+		defer func(envs config.Config) { config.Envs = envs }(config.Envs)
+		config.Envs.StockAllocationStrategy = "priority"
+		config.Envs.StockAllocationAllowSplit = true
+
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "pen", Price: types.NewMoney(1000), Quantity: 6}}}
+		orderStore := &mockOrderStore{}
+		reservationStore := &mockReservationStore{}
+		warehouseStore := &mockWarehouseStore{
+			warehouses: []types.Warehouse{{ID: 1, Active: true}, {ID: 2, Active: true}},
+			stock: []types.WarehouseStock{
+				{WarehouseID: 1, ProductID: 1, Quantity: 1, Available: 1},
+				{WarehouseID: 2, ProductID: 1, Quantity: 5, Available: 5},
+			},
+		}
+		handler := NewHandler(nil, orderStore, nil, productStore, &mockVariantStore{}, nil, nil, reservationStore, warehouseStore, nil, &mockTransactor{})
+
+		items := []types.CartItem{{ProductID: 1, Quantity: 3}}
+		if _, _, err := handler.createOrder(context.Background(), checkoutRequest{userID: 1, items: items, shippingAddress: "shipping", billingAddress: "billing"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if it := orderStore.items; len(it) != 2 || it[0].WarehouseID != 1 || it[0].Quantity != 1 || it[1].WarehouseID != 2 || it[1].Quantity != 2 {
+			t.Errorf("expected 1 from warehouse 1 & 2 from warehouse 2, got %+v", it)
+		}
+		if r := reservationStore.created; len(r) != 2 || r[0].WarehouseID != 1 || r[1].WarehouseID != 2 {
+			t.Errorf("expected a reservation at each warehouse, got %+v", r)
+		}
+	})
+
+	t.Run("should take coupon discounts off and record them", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "pen", Price: types.NewMoney(1000), Quantity: 5}}}
+		orderStore := &mockOrderStore{}
+		couponStore := &mockCouponStore{coupons: []types.Coupon{
+			{ID: 7, Code: "TENOFF", Type: types.CouponTypePercentage, PercentOff: 10, Active: true},
+		}}
+		handler := NewHandler(nil, orderStore, nil, productStore, &mockVariantStore{}, nil, couponStore, &mockReservationStore{}, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+		items := []types.CartItem{{ProductID: 1, Quantity: 3}}
+		order, discounts, err := handler.createOrder(context.Background(), checkoutRequest{userID: 1, items: items, couponCodes: []string{" tenoff "}, shippingAddress: "shipping", billingAddress: "billing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if order.Subtotal != types.NewMoney(3000) || order.Discount != types.NewMoney(300) || order.Total != types.NewMoney(2700) {
+			t.Errorf("unexpected amounts %s - %s = %s", order.Subtotal, order.Discount, order.Total)
+		}
+		if len(discounts) != 1 || len(orderStore.discounts) != 1 || orderStore.discounts[0].CouponID != 7 {
+			t.Errorf("expected the discount to be recorded, got %+v", orderStore.discounts)
+		}
+	})
+
+	t.Run("should reject coupons over their usage limit", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "pen", Price: types.NewMoney(1000), Quantity: 5}}}
+		orderStore := &mockOrderStore{}
+		once := 1
+		couponStore := &mockCouponStore{
+			coupons:    []types.Coupon{{ID: 7, Code: "ONCE", Type: types.CouponTypeFixed, AmountOff: types.NewMoney(100), PerUserLimit: &once, Active: true}},
+			usedByUser: 1,
+		}
+		handler := NewHandler(nil, orderStore, nil, productStore, &mockVariantStore{}, nil, couponStore, &mockReservationStore{}, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+		items := []types.CartItem{{ProductID: 1, Quantity: 1}}
+		_, _, err := handler.createOrder(context.Background(), checkoutRequest{userID: 1, items: items, couponCodes: []string{"ONCE"}, shippingAddress: "shipping", billingAddress: "billing"})
+		if status := checkoutErrorStatus(err); err == nil || status != http.StatusBadRequest {
+			t.Fatalf("expected usage limit error with status code %d, got %v (%d)", http.StatusBadRequest, err, status)
+		}
+		_, _, err = handler.createOrder(context.Background(), checkoutRequest{userID: 1, items: items, couponCodes: []string{"UNKNOWN"}, shippingAddress: "shipping", billingAddress: "billing"})
+		if status := checkoutErrorStatus(err); err == nil || status != http.StatusNotFound {
+			t.Fatalf("expected unknown coupon error with status code %d, got %v (%d)", http.StatusNotFound, err, status)
+		}
+		if len(orderStore.orders) != 0 {
+			t.Errorf("expected no orders, got %d", len(orderStore.orders))
+		}
+	})
+
+	t.Run("should check out and empty the saved cart", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "pen", Price: types.NewMoney(1200), Quantity: 5}}}
+		orderStore := &mockOrderStore{}
+		cartStore := &mockCartStore{lines: []types.CartLine{{ProductID: 1, Quantity: 2, PriceAtAdd: types.NewMoney(1000)}}}
+		handler := NewHandler(cartStore, orderStore, nil, productStore, &mockVariantStore{}, nil, nil, &mockReservationStore{}, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+		order, _, err := handler.createOrder(context.Background(), checkoutRequest{userID: 1, cartID: 1, shippingAddress: "shipping", billingAddress: "billing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Charged at the current price, not the price at add.
+		if order.Total != types.NewMoney(2400) {
+			t.Errorf("expected total 24.00, got %s", order.Total)
+		}
+		if !cartStore.cleared {
+			t.Error("expected the saved cart to be emptied")
+		}
+	})
+
+	t.Run("should not check out an empty saved cart", func(t *testing.T) {
+		productStore := &mockProductStore{}
+		cartStore := &mockCartStore{}
+		handler := NewHandler(cartStore, &mockOrderStore{}, nil, productStore, &mockVariantStore{}, nil, nil, &mockReservationStore{}, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+		_, _, err := handler.createOrder(context.Background(), checkoutRequest{userID: 1, cartID: 1})
+		if status := checkoutErrorStatus(err); err == nil || status != http.StatusBadRequest {
+			t.Fatalf("expected empty cart error with status code %d, got %v (%d)", http.StatusBadRequest, err, status)
+		}
+		if cartStore.cleared {
+			t.Error("expected the saved cart to be left alone")
+		}
+	})
+
+	t.Run("should return the error if an order item cannot be created", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "pen", Price: types.NewMoney(1000), Quantity: 5}}}
+		orderStore := &mockOrderStore{itemErr: fmt.Errorf("db is down")}
+		handler := NewHandler(nil, orderStore, nil, productStore, &mockVariantStore{}, nil, nil, &mockReservationStore{}, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+		items := []types.CartItem{{ProductID: 1, Quantity: 1}}
+		_, _, err := handler.createOrder(context.Background(), checkoutRequest{userID: 1, items: items, shippingAddress: "shipping", billingAddress: "billing"})
+		if err == nil {
+			t.Fatal("expected error to abort the transaction")
+		}
+		// Not the client's fault, so a retry with the same `Idempotency-Key` runs again.
+		if status := checkoutErrorStatus(err); status != http.StatusInternalServerError {
+			t.Errorf("expected status code %d, got %d", http.StatusInternalServerError, status)
+		}
+	})
+}
+
+func TestCreateOrderWithVariants(t *testing.T) {
+	price := types.NewMoney(1500)
+	variantStore := &mockVariantStore{variants: []types.Variant{
+		{ID: 11, ProductID: 1, SKU: "TEE-M", Price: &price, Options: []types.VariantOption{{Name: "Size", Value: "M"}}, Quantity: 2, Active: true},
+		{ID: 12, ProductID: 1, SKU: "TEE-L", Options: []types.VariantOption{{Name: "Size", Value: "L"}}, Quantity: 5, Active: true},
+	}}
+	newHandler := func(productStore *mockProductStore, orderStore *mockOrderStore, reservationStore *mockReservationStore) *Handler {
+		return NewHandler(nil, orderStore, nil, productStore, variantStore, nil, nil, reservationStore, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+	}
+	request := func(items ...types.CartItem) checkoutRequest {
+		return checkoutRequest{userID: 1, items: items, shippingAddress: "shipping", billingAddress: "billing"}
+	}
+
+	t.Run("should order variants at their own price", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "T-Shirt", Price: types.NewMoney(1000), Quantity: 7}}}
+		orderStore := &mockOrderStore{}
+		reservationStore := &mockReservationStore{}
+
+		order, _, err := newHandler(productStore, orderStore, reservationStore).createOrder(context.Background(), request(
+			types.CartItem{ProductID: 1, VariantID: 11, Quantity: 2},
+			types.CartItem{ProductID: 1, VariantID: 12, Quantity: 1},
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if order.Subtotal != types.NewMoney(4000) {
+			t.Errorf("expected subtotal 40.00, got %s", order.Subtotal)
+		}
+		if item := orderStore.items[0]; item.VariantID != 11 || item.SKU != "TEE-M" || item.ProductName != "T-Shirt (M)" || item.Price != price {
+			t.Errorf("unexpected order item %+v", item)
+		}
+		if r := reservationStore.created; len(r) != 2 || r[0].VariantID != 11 || r[1].VariantID != 12 {
+			t.Errorf("expected a reservation per variant, got %+v", r)
+		}
+	})
+
+	t.Run("should fail if a variant is out of stock", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "T-Shirt", Price: types.NewMoney(1000), Quantity: 7}}}
+		reservationStore := &mockReservationStore{reservedVariants: map[int]int{11: 1}}
+
+		_, _, err := newHandler(productStore, &mockOrderStore{}, reservationStore).createOrder(context.Background(), request(
+			types.CartItem{ProductID: 1, VariantID: 11, Quantity: 2},
+		))
+		if err == nil {
+			t.Fatal("expected out of stock error")
+		}
+	})
+
+	t.Run("should require a variant for products with variants", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{{ID: 1, Name: "T-Shirt", Price: types.NewMoney(1000), Quantity: 7}}}
+
+		_, _, err := newHandler(productStore, &mockOrderStore{}, &mockReservationStore{}).createOrder(context.Background(), request(
+			types.CartItem{ProductID: 1, Quantity: 1},
+		))
+		if !errors.Is(err, types.ErrVariantRequired) {
+			t.Errorf("expected ErrVariantRequired, got %v", err)
+		}
+	})
+}
+
+func TestQuoteCart(t *testing.T) {
+	productStore := &mockProductStore{products: []types.Product{
+		{ID: 1, Name: "pen", Price: types.NewMoney(1000), Quantity: 5},
+		{ID: 2, Name: "ink", Price: types.NewMoney(250), Quantity: 1},
+	}}
+	couponStore := &mockCouponStore{coupons: []types.Coupon{
+		{ID: 7, Code: "FIVE", Type: types.CouponTypeFixed, AmountOff: types.NewMoney(500), Active: true},
+	}}
+	handler := NewHandler(nil, &mockOrderStore{}, nil, productStore, &mockVariantStore{}, nil, couponStore, &mockReservationStore{}, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+	t.Run("should price the cart with discounts", func(t *testing.T) {
+		items := []types.CartItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}}
+		quote, err := handler.quoteCart(1, 0, items, []string{"five"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !quote.Purchasable || len(quote.Warnings) != 0 {
+			t.Errorf("expected a purchasable cart, got warnings %v", quote.Warnings)
+		}
+		if quote.Lines[0].LineTotal != types.NewMoney(2000) || quote.Subtotal != types.NewMoney(2250) {
+			t.Errorf("unexpected prices %+v", quote)
+		}
+		if quote.Discount != types.NewMoney(500) || quote.Total != types.NewMoney(1750) {
+			t.Errorf("expected 5.00 off & 17.50 total, got %s & %s", quote.Discount, quote.Total)
+		}
+	})
+
+	t.Run("should warn about stock instead of failing", func(t *testing.T) {
+		items := []types.CartItem{{ProductID: 2, Quantity: 3}, {ProductID: 9, Quantity: 1}}
+		quote, err := handler.quoteCart(1, 0, items, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if quote.Purchasable || len(quote.Warnings) == 0 {
+			t.Error("expected the cart not to be purchasable")
+		}
+		if quote.Lines[0].Warning == "" || quote.Lines[0].Available != 1 || quote.Lines[1].Warning == "" {
+			t.Errorf("expected line warnings, got %+v", quote.Lines)
+		}
+		if quote.Subtotal != types.NewMoney(750) {
+			t.Errorf("expected unknown products to be left out, got subtotal %s", quote.Subtotal)
+		}
+	})
+
+	t.Run("should drop invalid coupons with a warning", func(t *testing.T) {
+		quote, err := handler.quoteCart(1, 0, []types.CartItem{{ProductID: 1, Quantity: 1}}, []string{"NOPE"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if quote.Purchasable || !quote.Discount.IsZero() || quote.Total != types.NewMoney(1000) {
+			t.Errorf("unexpected quote %+v", quote)
+		}
+	})
+
+	t.Run("should not write anything", func(t *testing.T) {
+		if len(productStore.updated) != 0 {
+			t.Errorf("expected no product updates, got %d", len(productStore.updated))
+		}
+	})
+}
+
+func TestViewCart(t *testing.T) {
+	t.Run("should flag products whose price changed", func(t *testing.T) {
+		productStore := &mockProductStore{products: []types.Product{
+			{ID: 1, Name: "pen", Price: types.NewMoney(1200), Quantity: 5},
+			{ID: 2, Name: "ink", Price: types.NewMoney(250), Quantity: 5},
+		}}
+		cartStore := &mockCartStore{lines: []types.CartLine{
+			{ProductID: 1, Quantity: 1, PriceAtAdd: types.NewMoney(1000)},
+			{ProductID: 2, Quantity: 1, PriceAtAdd: types.NewMoney(250)},
+		}}
+		handler := NewHandler(cartStore, nil, nil, productStore, &mockVariantStore{}, nil, nil, &mockReservationStore{}, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+		view, err := handler.viewCart(&types.Cart{ID: 1, UserID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(view.PriceChanged) != 1 || view.PriceChanged[0] != 1 {
+			t.Errorf("expected product 1 to be flagged, got %v", view.PriceChanged)
+		}
+		if view.Quote.Subtotal != types.NewMoney(1450) {
+			t.Errorf("expected subtotal 14.50, got %s", view.Quote.Subtotal)
+		}
+	})
+}
+
+func TestCalculateCharges(t *testing.T) {
+	defer func(envs config.Config) { config.Envs = envs }(config.Envs)
+	config.Envs.ShippingFee = "4.99"
+	config.Envs.FreeShippingThreshold = "50"
+	config.Envs.TaxRateBasisPoints = 825
+
+	t.Run("should charge shipping & tax the discounted subtotal", func(t *testing.T) {
+		shipping, tax, err := calculateCharges(types.NewMoney(2000), types.NewMoney(500))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 8.25% of 15.00 = 1.2375
+		if shipping.String() != "4.99" || tax.String() != "1.24" {
+			t.Errorf("expected 4.99 & 1.24, got %s & %s", shipping, tax)
+		}
+	})
+
+	t.Run("should waive shipping above the threshold", func(t *testing.T) {
+		shipping, _, err := calculateCharges(types.NewMoney(6000), types.NewMoney(1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !shipping.IsZero() {
+			t.Errorf("expected free shipping, got %s", shipping)
+		}
+	})
+}
+
+func TestResolveAddresses(t *testing.T) {
+	addressStore := &mockAddressStore{addresses: []types.Address{
+		{ID: 1, UserID: 1, FullName: "Home", IsDefaultShipping: true},
+		{ID: 2, UserID: 1, FullName: "Office", IsDefaultBilling: true},
+		{ID: 3, UserID: 2, FullName: "Someone else"},
+	}}
+	handler := NewHandler(nil, nil, nil, nil, nil, addressStore, nil, nil, nil, nil, &mockTransactor{})
+
+	t.Run("should use the default addresses", func(t *testing.T) {
+		shipping, billing, err := handler.resolveAddresses(1, types.CartCheckoutPayload{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(shipping, "Home") || !strings.HasPrefix(billing, "Office") {
+			t.Errorf("unexpected addresses %q & %q", shipping, billing)
+		}
+	})
+
+	t.Run("should prefer the inline address", func(t *testing.T) {
+		payload := types.CartCheckoutPayload{AddressID: 2, Address: &types.AddressPayload{FullName: "Hotel", Country: "fr"}}
+
+		shipping, _, err := handler.resolveAddresses(1, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(shipping, "Hotel") || !strings.Contains(shipping, "FR") {
+			t.Errorf("unexpected shipping address %q", shipping)
+		}
+	})
+
+	t.Run("should reject addresses of other users", func(t *testing.T) {
+		if _, _, err := handler.resolveAddresses(1, types.CartCheckoutPayload{AddressID: 3}); err == nil {
+			t.Error("expected error for an address of another user")
+		}
+	})
+
+	t.Run("should fail without any address", func(t *testing.T) {
+		if _, _, err := handler.resolveAddresses(3, types.CartCheckoutPayload{}); err == nil {
+			t.Error("expected error without shipping address")
+		}
+	})
+}
+
+// Runs `fn` directly, stores below ignore the (nil) tx.
+type mockTransactor struct{}
+
+func (m *mockTransactor) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return fn(nil)
+}
+
+type mockProductStore struct {
+	types.ProductStore
+	products []types.Product
+	updated  []types.Product
+}
+
+func (m *mockProductStore) GetProductByIDs(ids []int) ([]types.Product, error) {
+	return m.GetProductByIDsForUpdate(nil, ids)
+}
+
+func (m *mockProductStore) GetProductByIDsForUpdate(tx *sql.Tx, ids []int) ([]types.Product, error) {
+	return m.products, nil
+}
+
+func (m *mockProductStore) UpdateProductTx(tx *sql.Tx, p types.Product) error {
+	m.updated = append(m.updated, p)
+	return nil
+}
+
+type mockOrderStore struct {
+	types.OrderStore
+	orders    []types.Order
+	items     []types.OrderItem
+	discounts []types.OrderDiscount
+	itemErr   error
+}
+
+func (m *mockOrderStore) CreateOrder(o types.Order) (int, error) {
+	m.orders = append(m.orders, o)
+	return len(m.orders), nil
+}
+
+func (m *mockOrderStore) CreateOrderItem(oi types.OrderItem) error {
+	m.items = append(m.items, oi)
+	return m.itemErr
+}
+
+func (m *mockOrderStore) CreateOrderTx(tx *sql.Tx, o types.Order) (int, error) {
+	return m.CreateOrder(o)
+}
+
+func (m *mockOrderStore) CreateOrderItemTx(tx *sql.Tx, oi types.OrderItem) error {
+	return m.CreateOrderItem(oi)
+}
+
+func (m *mockOrderStore) CreateOrderStatusChangeTx(tx *sql.Tx, change types.OrderStatusChange) error {
+	return nil
+}
+
+func (m *mockOrderStore) CreateOrderDiscountTx(tx *sql.Tx, d types.OrderDiscount) error {
+	m.discounts = append(m.discounts, d)
+	return nil
+}
+
+// The user's cart is cart 1, the guest cart (if any) cart 2.
+type mockCartStore struct {
+	types.CartStore
+	lines      []types.CartLine
+	cleared    bool
+	guest      bool
+	guestLines []types.CartLine
+	set        []types.CartLine
+	deleted    []int
+}
+
+func (m *mockCartStore) GetCartByUserID(userID int) (*types.Cart, error) {
+	return &types.Cart{ID: 1, UserID: userID}, nil
+}
+
+func (m *mockCartStore) GetCartByTokenHash(tokenHash string) (*types.Cart, error) {
+	if !m.guest {
+		return nil, types.ErrCartNotFound
+	}
+	return &types.Cart{ID: 2}, nil
+}
+
+func (m *mockCartStore) GetCartLines(cartID int) ([]types.CartLine, error) {
+	return m.GetCartLinesForUpdate(nil, cartID)
+}
+
+func (m *mockCartStore) GetCartLinesForUpdate(tx *sql.Tx, cartID int) ([]types.CartLine, error) {
+	if cartID == 2 {
+		return m.guestLines, nil
+	}
+	return m.lines, nil
+}
+
+func (m *mockCartStore) SetCartItemTx(tx *sql.Tx, cartID int, line types.CartLine) error {
+	m.set = append(m.set, line)
+	return nil
+}
+
+func (m *mockCartStore) DeleteCartTx(tx *sql.Tx, cartID int) error {
+	m.deleted = append(m.deleted, cartID)
+	return nil
+}
+
+func (m *mockCartStore) ClearCartTx(tx *sql.Tx, cartID int) error {
+	m.cleared = true
+	return nil
+}
+
+type mockVariantStore struct {
+	types.VariantStore
+	variants []types.Variant
+}
+
+func (m *mockVariantStore) GetVariantsByProductID(productID int) ([]types.Variant, error) {
+	return m.GetVariantsByProductIDsTx(nil, []int{productID})
+}
+
+func (m *mockVariantStore) GetVariantsByProductIDs(productIDs []int) ([]types.Variant, error) {
+	return m.GetVariantsByProductIDsTx(nil, productIDs)
+}
+
+func (m *mockVariantStore) GetVariantsByProductIDsTx(tx *sql.Tx, productIDs []int) ([]types.Variant, error) {
+	variants := []types.Variant{}
+	for _, v := range m.variants {
+		if slices.Contains(productIDs, v.ProductID) {
+			variants = append(variants, v)
+		}
+	}
+	return variants, nil
+}
+
+type mockReservationStore struct {
+	types.ReservationStore
+	reserved         map[int]int
+	reservedVariants map[int]int
+	created          []types.StockReservation
+	released         []int
+}
+
+func (m *mockReservationStore) GetReservedQuantities(productIDs []int, cartID, orderID int) (map[int]int, error) {
+	return m.reserved, nil
+}
+
+func (m *mockReservationStore) GetReservedQuantitiesTx(tx *sql.Tx, productIDs []int, cartID, orderID int) (map[int]int, error) {
+	return m.reserved, nil
+}
+
+func (m *mockReservationStore) GetReservedVariantQuantities(variantIDs []int, cartID, orderID int) (map[int]int, error) {
+	return m.reservedVariants, nil
+}
+
+func (m *mockReservationStore) GetReservedVariantQuantitiesTx(tx *sql.Tx, variantIDs []int, cartID, orderID int) (map[int]int, error) {
+	return m.reservedVariants, nil
+}
+
+func (m *mockReservationStore) CreateReservationTx(tx *sql.Tx, r types.StockReservation) error {
+	m.created = append(m.created, r)
+	return nil
+}
+
+func (m *mockReservationStore) DeleteCartReservationsTx(tx *sql.Tx, cartID int) error {
+	m.released = append(m.released, cartID)
+	return nil
+}
+
+func (m *mockReservationStore) GetCartReservations(cartID int) ([]types.StockReservation, error) {
+	reservations := []types.StockReservation{}
+	for _, r := range m.created {
+		if r.CartID == cartID {
+			reservations = append(reservations, r)
+		}
+	}
+	return reservations, nil
+}
+
+// Without `stock` all products are held at warehouse 1.
+type mockWarehouseStore struct {
+	types.WarehouseStore
+	products   *mockProductStore
+	warehouses []types.Warehouse
+	stock      []types.WarehouseStock
+}
+
+func (m *mockWarehouseStore) GetWarehouses() ([]types.Warehouse, error) {
+	if m.warehouses == nil {
+		return []types.Warehouse{{ID: 1, Active: true}}, nil
+	}
+	return m.warehouses, nil
+}
+
+func (m *mockWarehouseStore) GetProductStockForUpdate(tx *sql.Tx, productIDs []int, orderID int) ([]types.WarehouseStock, error) {
+	if m.stock != nil {
+		return m.stock, nil
+	}
+	stock := []types.WarehouseStock{}
+	for _, p := range m.products.products {
+		stock = append(stock, types.WarehouseStock{WarehouseID: 1, ProductID: p.ID, Quantity: p.Quantity, Available: p.Quantity})
+	}
+	return stock, nil
+}
+
+type mockCouponStore struct {
+	types.CouponStore
+	coupons          []types.Coupon
+	used, usedByUser int
+}
+
+func (m *mockCouponStore) GetCouponsByCodes(codes []string) ([]types.Coupon, error) {
+	return m.GetCouponsByCodesForUpdate(nil, codes)
+}
+
+func (m *mockCouponStore) GetCouponsByCodesForUpdate(tx *sql.Tx, codes []string) ([]types.Coupon, error) {
+	coupons := []types.Coupon{}
+	for _, c := range m.coupons {
+		if slices.Contains(codes, c.Code) {
+			coupons = append(coupons, c)
+		}
+	}
+	return coupons, nil
+}
+
+func (m *mockCouponStore) GetCouponUsage(couponID, userID int) (int, int, error) {
+	return m.used, m.usedByUser, nil
+}
+
+func (m *mockCouponStore) GetCouponUsageTx(tx *sql.Tx, couponID, userID int) (int, int, error) {
+	return m.used, m.usedByUser, nil
+}
+
+type mockAddressStore struct {
+	types.AddressStore
+	addresses []types.Address
+}
+
+func (m *mockAddressStore) GetAddressesByUserID(userID int) ([]types.Address, error) {
+	addresses := []types.Address{}
+	for _, a := range m.addresses {
+		if a.UserID == userID {
+			addresses = append(addresses, a)
+		}
+	}
+	return addresses, nil
+}
+
+func (m *mockAddressStore) GetAddressByID(id int) (*types.Address, error) {
+	for _, a := range m.addresses {
+		if a.ID == id {
+			return &a, nil
+		}
+	}
+	return nil, types.ErrAddressNotFound
+}
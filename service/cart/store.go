@@ -0,0 +1,205 @@
+package cart
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Get the user's cart, creating an empty one on first use.
+func (s *Store) GetCartByUserID(userID int) (*types.Cart, error) {
+	// `userId` is unique, a concurrent first use inserts only one cart.
+	if _, err := s.db.Exec("INSERT IGNORE INTO carts (userId) VALUES (?)", userID); err != nil {
+		return nil, err
+	}
+
+	return getCart(s.db, "userId = ?", userID)
+}
+
+// Create an empty guest cart named by the hash of its token, kept until `expiresAt`.
+func (s *Store) CreateGuestCart(tokenHash string, expiresAt time.Time) (*types.Cart, error) {
+	res, err := s.db.Exec("INSERT INTO carts (tokenHash, expiresAt) VALUES (?, ?)", tokenHash, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return getCart(s.db, "id = ?", id)
+}
+
+// Get a guest cart by the hash of its token.
+// Returns `types.ErrCartNotFound` if there is no such cart (e.g. already
+// merged) or it expired, even if it wasn't swept yet.
+func (s *Store) GetCartByTokenHash(tokenHash string) (*types.Cart, error) {
+	return getCart(s.db, "tokenHash = ? AND expiresAt > ?", tokenHash, time.Now())
+}
+
+// Keep a guest cart until `expiresAt`.
+func (s *Store) ExtendGuestCart(cartID int, expiresAt time.Time) error {
+	_, err := s.db.Exec("UPDATE carts SET expiresAt = ? WHERE id = ? AND userId IS NULL", expiresAt, cartID)
+	return err
+}
+
+// Delete expired guest carts, their items & reservations go with them (`ON DELETE CASCADE`).
+func (s *Store) DeleteExpiredGuestCarts() (int, error) {
+	res, err := s.db.Exec("DELETE FROM carts WHERE expiresAt <= ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func getCart(q db.Querier, where string, args ...any) (*types.Cart, error) {
+	cart := new(types.Cart)
+	var userID sql.NullInt64
+	var expiresAt sql.NullTime
+
+	err := q.QueryRow("SELECT id, userId, createdAt, updatedAt, expiresAt FROM carts WHERE "+where, args...).
+		Scan(&cart.ID, &userID, &cart.CreatedAt, &cart.UpdatedAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, types.ErrCartNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	cart.UserID = int(userID.Int64)
+	if expiresAt.Valid {
+		cart.ExpiresAt = &expiresAt.Time
+	}
+
+	return cart, nil
+}
+
+// Get the items of a cart, oldest first.
+func (s *Store) GetCartLines(cartID int) ([]types.CartLine, error) {
+	return getCartLines(s.db, cartID, false)
+}
+
+// Same as `GetCartLines` but locks the items until `tx` ends.
+func (s *Store) GetCartLinesForUpdate(tx *sql.Tx, cartID int) ([]types.CartLine, error) {
+	return getCartLines(tx, cartID, true)
+}
+
+func getCartLines(q db.Querier, cartID int, forUpdate bool) ([]types.CartLine, error) {
+	query := "SELECT productId, variantId, quantity, priceAtAdd, addedAt, updatedAt FROM cart_items WHERE cartId = ? ORDER BY addedAt, productId, variantId"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := q.Query(query, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]types.CartLine, 0)
+	for rows.Next() {
+		l := types.CartLine{}
+		if err := rows.Scan(&l.ProductID, &l.VariantID, &l.Quantity, &l.PriceAtAdd, &l.AddedAt, &l.UpdatedAt); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, rows.Err()
+}
+
+// Add `quantity` of a product (variant) to the cart, on top of what is already in it.
+// The price at add is refreshed to `price`.
+func (s *Store) AddCartItem(cartID, productID, variantID, quantity int, price types.Money) error {
+	_, err := s.db.Exec(`INSERT INTO cart_items (cartId, productId, variantId, quantity, priceAtAdd) VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity), priceAtAdd = VALUES(priceAtAdd)`,
+		cartID, productID, variantID, quantity, price)
+	if err != nil {
+		return err
+	}
+
+	return touchCart(s.db, cartID)
+}
+
+// Set the quantity of a product (variant) already in the cart.
+// Returns `types.ErrCartItemNotFound` if it is not in the cart.
+func (s *Store) UpdateCartItem(cartID, productID, variantID, quantity int) error {
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM cart_items WHERE cartId = ? AND productId = ? AND variantId = ?)",
+		cartID, productID, variantID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return types.ErrCartItemNotFound
+	}
+
+	_, err = s.db.Exec("UPDATE cart_items SET quantity = ? WHERE cartId = ? AND productId = ? AND variantId = ?", quantity, cartID, productID, variantID)
+	if err != nil {
+		return err
+	}
+
+	return touchCart(s.db, cartID)
+}
+
+// Remove a product (variant) from the cart.
+// Returns `types.ErrCartItemNotFound` if it is not in the cart.
+func (s *Store) RemoveCartItem(cartID, productID, variantID int) error {
+	res, err := s.db.Exec("DELETE FROM cart_items WHERE cartId = ? AND productId = ? AND variantId = ?", cartID, productID, variantID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return types.ErrCartItemNotFound
+	}
+
+	return touchCart(s.db, cartID)
+}
+
+// Empty the cart as part of transaction `tx` (after checkout).
+func (s *Store) ClearCartTx(tx *sql.Tx, cartID int) error {
+	if _, err := tx.Exec("DELETE FROM cart_items WHERE cartId = ?", cartID); err != nil {
+		return err
+	}
+
+	return touchCart(tx, cartID)
+}
+
+// Set an item of the cart to `line` (quantity & price at add), as part of `tx`.
+func (s *Store) SetCartItemTx(tx *sql.Tx, cartID int, line types.CartLine) error {
+	_, err := tx.Exec(`INSERT INTO cart_items (cartId, productId, variantId, quantity, priceAtAdd) VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE quantity = VALUES(quantity), priceAtAdd = VALUES(priceAtAdd)`,
+		cartID, line.ProductID, line.VariantID, line.Quantity, line.PriceAtAdd)
+	if err != nil {
+		return err
+	}
+
+	return touchCart(tx, cartID)
+}
+
+// Delete a cart along with its items, as part of `tx`.
+func (s *Store) DeleteCartTx(tx *sql.Tx, cartID int) error {
+	_, err := tx.Exec("DELETE FROM carts WHERE id = ?", cartID)
+	return err
+}
+
+// Item changes don't touch the `carts` row, bumping `updatedAt` explicitly.
+func touchCart(q db.Querier, cartID int) error {
+	_, err := q.Exec("UPDATE carts SET updatedAt = CURRENT_TIMESTAMP WHERE id = ?", cartID)
+	return err
+}
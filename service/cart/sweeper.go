@@ -0,0 +1,33 @@
+package cart
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Delete expired guest carts every `interval` until `ctx` is done.
+// Meant to run in the background for the lifetime of the API server.
+func SweepExpiredGuestCarts(ctx context.Context, store types.CartStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := store.DeleteExpiredGuestCarts()
+			if err != nil {
+				// Expired guest carts can't be used anyway, retrying on the next tick.
+				log.Printf("failed to sweep expired guest carts: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("deleted %d expired guest carts", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package cart
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestSweepExpiredGuestCarts(t *testing.T) {
+	t.Run("should sweep until the context is done", func(t *testing.T) {
+		store := &mockSweptCartStore{}
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan struct{})
+		go func() {
+			SweepExpiredGuestCarts(ctx, store, time.Millisecond)
+			close(done)
+		}()
+
+		deadline := time.After(time.Second)
+		for store.sweeps.Load() < 2 {
+			select {
+			case <-deadline:
+				t.Fatal("expected the sweeper to run")
+			case <-time.After(time.Millisecond):
+			}
+		}
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected the sweeper to stop")
+		}
+	})
+}
+
+// Counts the sweeps.
+type mockSweptCartStore struct {
+	types.CartStore
+	sweeps atomic.Int32
+}
+
+func (m *mockSweptCartStore) DeleteExpiredGuestCarts() (int, error) {
+	m.sweeps.Add(1)
+	return 1, nil
+}
@@ -0,0 +1,201 @@
+package category
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+type Handler struct {
+	store            types.CategoryStore
+	userStore        types.UserStore
+	idempotencyStore types.IdempotencyStore
+}
+
+func NewHandler(store types.CategoryStore, userStore types.UserStore, idempotencyStore types.IdempotencyStore) *Handler {
+	return &Handler{store: store, userStore: userStore, idempotencyStore: idempotencyStore}
+}
+
+// Products of a category are listed by `product.Handler`
+// (`GET /categories/{slug}/products`).
+func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /categories", h.handleGetCategories)
+	router.HandleFunc("GET /categories/{slug}", h.handleGetCategory)
+
+	// Category tree management, `admin` users only.
+	router.HandleFunc("POST /categories", auth.WithRole(idempotency.WithIdempotencyKey(h.handleCreateCategory, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("PUT /categories/{id}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleReplaceCategory, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("DELETE /categories/{id}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleDeleteCategory, h.idempotencyStore), h.userStore, types.RoleAdmin))
+}
+
+// HandlerFunc to get the category tree.
+func (h *Handler) handleGetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.store.GetCategories()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONWithETag(w, r, http.StatusOK, BuildTree(categories))
+}
+
+// HandlerFunc to get a single category by its slug, along with its subcategories.
+func (h *Handler) handleGetCategory(w http.ResponseWriter, r *http.Request) {
+	category, err := h.store.GetCategoryBySlug(r.PathValue("slug"))
+	if errors.Is(err, types.ErrCategoryNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	categories, err := h.store.GetCategories()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	category.Children = Subcategories(categories, category.ID)
+
+	utils.WriteJSONWithETag(w, r, http.StatusOK, category)
+}
+
+// HandlerFunc to create a category (admin).
+func (h *Handler) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
+	payload, ok := parseCategoryPayload(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := h.store.CreateCategory(payload.ToCategory())
+	if !writeStoreError(w, err) {
+		return
+	}
+
+	category, err := h.store.GetCategoryByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, category)
+}
+
+// HandlerFunc to replace all fields of a category (admin).
+// Changing `parentID` moves the category along with its subcategories.
+func (h *Handler) handleReplaceCategory(w http.ResponseWriter, r *http.Request) {
+	existing, ok := h.getCategoryFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	payload, ok := parseCategoryPayload(w, r)
+	if !ok {
+		return
+	}
+
+	category := payload.ToCategory()
+	category.ID = existing.ID
+	category.CreatedAt = existing.CreatedAt
+
+	if !writeStoreError(w, h.store.UpdateCategory(category)) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, category)
+}
+
+// HandlerFunc to delete a category (admin), its products stay.
+// Subcategories must be moved or deleted first.
+func (h *Handler) handleDeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid category id %q", r.PathValue("id")))
+		return
+	}
+
+	err = h.store.DeleteCategory(id)
+	switch {
+	case errors.Is(err, types.ErrCategoryNotFound):
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	case errors.Is(err, types.ErrCategoryInUse):
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Util function to parse & validate a category payload.
+// Writes the error response itself and returns false if it is invalid.
+func parseCategoryPayload(w http.ResponseWriter, r *http.Request) (types.CategoryPayload, bool) {
+	var payload types.CategoryPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return payload, false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return payload, false
+	}
+
+	if payload.ToCategory().Slug == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("slug must contain letters or digits"))
+		return payload, false
+	}
+
+	return payload, true
+}
+
+// Util function mapping store errors of create & update to responses.
+// Returns true if there was no error.
+func writeStoreError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, types.ErrCategoryNotFound), errors.Is(err, types.ErrCategoryCycle):
+		// Path categories are loaded beforehand, this is the parent.
+		utils.WriteError(w, http.StatusBadRequest, err)
+	case errors.Is(err, types.ErrCategorySlugTaken):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+	return false
+}
+
+// Util function to load the category addressed by the `{id}` path wildcard.
+// Writes the error response itself and returns false if it could not be loaded.
+func (h *Handler) getCategoryFromPath(w http.ResponseWriter, r *http.Request) (*types.Category, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid category id %q", r.PathValue("id")))
+		return nil, false
+	}
+
+	category, err := h.store.GetCategoryByID(id)
+	if errors.Is(err, types.ErrCategoryNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return category, true
+}
@@ -0,0 +1,171 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const categoryColumns = "id, parentId, name, slug, description, position, createdAt"
+
+// Get all categories, siblings ordered by position, then name.
+func (s *Store) GetCategories() ([]types.Category, error) {
+	return getCategories(s.db, "SELECT "+categoryColumns+" FROM categories ORDER BY position, name, id")
+}
+
+// Get a single category by its ID.
+// Returns `types.ErrCategoryNotFound` if there is no such category.
+func (s *Store) GetCategoryByID(id int) (*types.Category, error) {
+	return getCategory(s.db, "SELECT "+categoryColumns+" FROM categories WHERE id = ?", id)
+}
+
+// Get a single category by its slug.
+// Returns `types.ErrCategoryNotFound` if there is no such category.
+func (s *Store) GetCategoryBySlug(slug string) (*types.Category, error) {
+	return getCategory(s.db, "SELECT "+categoryColumns+" FROM categories WHERE slug = ?", slug)
+}
+
+func getCategory(q db.Querier, query string, args ...any) (*types.Category, error) {
+	categories, err := getCategories(q, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return nil, types.ErrCategoryNotFound
+	}
+
+	return &categories[0], nil
+}
+
+func getCategories(q db.Querier, query string, args ...any) ([]types.Category, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]types.Category, 0)
+	for rows.Next() {
+		c := types.Category{}
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &parentID, &c.Name, &c.Slug, &c.Description, &c.Position, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			c.ParentID = &id
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+// Create a new category and return its ID.
+// Returns `types.ErrCategorySlugTaken` if another category uses the same slug
+// and `types.ErrCategoryNotFound` if the parent doesn't exist.
+func (s *Store) CreateCategory(c types.Category) (int, error) {
+	res, err := s.db.Exec("INSERT INTO categories (parentId, name, slug, description, position) VALUES (?, ?, ?, ?, ?)",
+		c.ParentID, c.Name, c.Slug, c.Description, c.Position)
+	if err != nil {
+		return 0, mapWriteError(err, c)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Replace all fields of a category, moving it (with its subcategories) if
+// the parent changed. Returns `types.ErrCategoryCycle` if the new parent is
+// the category itself or one of its subcategories.
+func (s *Store) UpdateCategory(c types.Category) error {
+	return db.NewTransactor(s.db).WithTx(context.Background(), func(tx *sql.Tx) error {
+		if c.ParentID != nil {
+			// Locking every category, in ID order, before walking up the tree:
+			// two concurrent moves (A under B & B under A) would each pass the
+			// check on their own & commit a cycle. Nothing is read before the
+			// locks are held, so the walk sees the latest tree.
+			if err := db.LockRows(tx, "SELECT id FROM categories ORDER BY id FOR UPDATE"); err != nil {
+				return err
+			}
+
+			// Walking up from the new parent, the category must not be on the way.
+			var cycle bool
+			err := tx.QueryRow(`WITH RECURSIVE ancestors (id, parentId) AS (
+					SELECT id, parentId FROM categories WHERE id = ?
+					UNION ALL
+					SELECT c.id, c.parentId FROM categories c JOIN ancestors a ON c.id = a.parentId
+				)
+				SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = ?)`, *c.ParentID, c.ID).Scan(&cycle)
+			if err != nil {
+				return err
+			}
+			if cycle {
+				return types.ErrCategoryCycle
+			}
+		}
+
+		res, err := tx.Exec("UPDATE categories SET parentId = ?, name = ?, slug = ?, description = ?, position = ? WHERE id = ?",
+			c.ParentID, c.Name, c.Slug, c.Description, c.Position, c.ID)
+		if err != nil {
+			return mapWriteError(err, c)
+		}
+
+		return db.CheckUpdated(tx, res, "categories", c.ID, types.ErrCategoryNotFound)
+	})
+}
+
+// Delete a category, its products are taken out of it.
+// Returns `types.ErrCategoryInUse` if it has subcategories or coupons apply to it.
+func (s *Store) DeleteCategory(id int) error {
+	res, err := s.db.Exec("DELETE FROM categories WHERE id = ?", id)
+	if err != nil {
+		// ER_ROW_IS_REFERENCED_2 : foreign key constraint fails.
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1451 {
+			return types.ErrCategoryInUse
+		}
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return types.ErrCategoryNotFound
+	}
+
+	return nil
+}
+
+// Duplicate key on the unique `slug` column, or a parent that doesn't exist.
+func mapWriteError(err error, c types.Category) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1062:
+			return types.ErrCategorySlugTaken
+		case 1452:
+			return fmt.Errorf("%w: parent %d", types.ErrCategoryNotFound, *c.ParentID)
+		}
+	}
+	return err
+}
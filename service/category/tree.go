@@ -0,0 +1,36 @@
+package category
+
+import "github.com/gitKashish/ecommerce-api-go/types"
+
+// Nest the categories (as `Store.GetCategories()` returns them) into a tree,
+// returning the top level ones. Siblings keep their order.
+func BuildTree(categories []types.Category) []types.Category {
+	return nest(byParent(categories), 0)
+}
+
+// Same as `BuildTree` but only the subcategories of category `id`.
+func Subcategories(categories []types.Category, id int) []types.Category {
+	return nest(byParent(categories), id)
+}
+
+// Categories by their parent's ID, 0 for top level ones.
+func byParent(categories []types.Category) map[int][]types.Category {
+	children := make(map[int][]types.Category)
+	for _, c := range categories {
+		parentID := 0
+		if c.ParentID != nil {
+			parentID = *c.ParentID
+		}
+		children[parentID] = append(children[parentID], c)
+	}
+	return children
+}
+
+func nest(children map[int][]types.Category, parentID int) []types.Category {
+	nodes := make([]types.Category, 0, len(children[parentID]))
+	for _, c := range children[parentID] {
+		c.Children = nest(children, c.ID)
+		nodes = append(nodes, c)
+	}
+	return nodes
+}
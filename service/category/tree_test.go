@@ -0,0 +1,57 @@
+package category
+
+import (
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestBuildTree(t *testing.T) {
+	parent := func(id int) *int { return &id }
+	// As `Store.GetCategories()` returns them, siblings in display order.
+	categories := []types.Category{
+		{ID: 1, Slug: "clothing"},
+		{ID: 4, Slug: "shirts", ParentID: parent(1)},
+		{ID: 2, Slug: "stationery"},
+		{ID: 5, Slug: "t-shirts", ParentID: parent(4)},
+		{ID: 3, Slug: "pens", ParentID: parent(2)},
+	}
+
+	t.Run("should nest categories under their parent", func(t *testing.T) {
+		tree := BuildTree(categories)
+
+		if len(tree) != 2 || tree[0].Slug != "clothing" || tree[1].Slug != "stationery" {
+			t.Fatalf("unexpected top level categories %+v", tree)
+		}
+		shirts := tree[0].Children
+		if len(shirts) != 1 || shirts[0].ID != 4 || len(shirts[0].Children) != 1 || shirts[0].Children[0].ID != 5 {
+			t.Errorf("unexpected clothing subtree %+v", shirts)
+		}
+	})
+
+	t.Run("should only return the subcategories of a category", func(t *testing.T) {
+		subcategories := Subcategories(categories, 2)
+
+		if len(subcategories) != 1 || subcategories[0].Slug != "pens" || len(subcategories[0].Children) != 0 {
+			t.Errorf("unexpected subcategories %+v", subcategories)
+		}
+	})
+}
+
+func TestCategoryPayload(t *testing.T) {
+	t.Run("should derive the slug from the name", func(t *testing.T) {
+		c := types.CategoryPayload{Name: "Men's T-Shirts & Tops"}.ToCategory()
+
+		if c.Slug != "men-s-t-shirts-tops" {
+			t.Errorf("expected slug men-s-t-shirts-tops, got %q", c.Slug)
+		}
+	})
+
+	t.Run("should normalize a given slug", func(t *testing.T) {
+		c := types.CategoryPayload{Name: "Pens", Slug: " Fountain Pens "}.ToCategory()
+
+		if c.Slug != "fountain-pens" {
+			t.Errorf("expected slug fountain-pens, got %q", c.Slug)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+type Handler struct {
+	store            types.CouponStore
+	userStore        types.UserStore
+	idempotencyStore types.IdempotencyStore
+}
+
+func NewHandler(store types.CouponStore, userStore types.UserStore, idempotencyStore types.IdempotencyStore) *Handler {
+	return &Handler{store: store, userStore: userStore, idempotencyStore: idempotencyStore}
+}
+
+// Coupon management, `admin` users only. Customers redeem codes at checkout.
+func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /coupons", auth.WithRole(h.handleGetCoupons, h.userStore, types.RoleAdmin))
+	router.HandleFunc("POST /coupons", auth.WithRole(idempotency.WithIdempotencyKey(h.handleCreateCoupon, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("GET /coupons/{id}", auth.WithRole(h.handleGetCoupon, h.userStore, types.RoleAdmin))
+	router.HandleFunc("PUT /coupons/{id}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleReplaceCoupon, h.idempotencyStore), h.userStore, types.RoleAdmin))
+}
+
+// HandlerFunc to list all coupons (admin).
+func (h *Handler) handleGetCoupons(w http.ResponseWriter, r *http.Request) {
+	coupons, err := h.store.GetCoupons()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, coupons)
+}
+
+// HandlerFunc to get a single coupon (admin).
+func (h *Handler) handleGetCoupon(w http.ResponseWriter, r *http.Request) {
+	coupon, ok := h.getCouponFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, coupon)
+}
+
+// HandlerFunc to create a coupon (admin).
+func (h *Handler) handleCreateCoupon(w http.ResponseWriter, r *http.Request) {
+	payload, ok := parseCouponPayload(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := h.store.CreateCoupon(payload.ToCoupon())
+	if !writeStoreError(w, err) {
+		return
+	}
+
+	coupon, err := h.store.GetCouponByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, coupon)
+}
+
+// HandlerFunc to replace all fields of a coupon (admin).
+// Set `active` to false to retire a coupon, it stays on past orders.
+func (h *Handler) handleReplaceCoupon(w http.ResponseWriter, r *http.Request) {
+	existing, ok := h.getCouponFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	payload, ok := parseCouponPayload(w, r)
+	if !ok {
+		return
+	}
+
+	coupon := payload.ToCoupon()
+	coupon.ID = existing.ID
+	coupon.CreatedAt = existing.CreatedAt
+
+	if !writeStoreError(w, h.store.UpdateCoupon(coupon)) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, coupon)
+}
+
+// Util function to parse & validate a coupon payload.
+// Writes the error response itself and returns false if it is invalid.
+func parseCouponPayload(w http.ResponseWriter, r *http.Request) (types.CouponPayload, bool) {
+	var payload types.CouponPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return payload, false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return payload, false
+	}
+
+	// Rules spanning several fields.
+	var err error
+	switch {
+	case payload.Type == types.CouponTypePercentage && (payload.PercentOff == 0 || !payload.AmountOff.IsZero()):
+		err = fmt.Errorf("percentage coupons need percentOff (1-100) and no amountOff")
+	case payload.Type == types.CouponTypeFixed && (payload.AmountOff.IsZero() || payload.PercentOff != 0):
+		err = fmt.Errorf("fixed coupons need amountOff (> 0) and no percentOff")
+	case payload.StartsAt != nil && payload.EndsAt != nil && !payload.EndsAt.After(*payload.StartsAt):
+		err = fmt.Errorf("endsAt must be after startsAt")
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return payload, false
+	}
+
+	return payload, true
+}
+
+// Util function mapping store errors of create & update to responses.
+// Returns true if there was no error.
+func writeStoreError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, types.ErrCouponNotFound):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case errors.Is(err, types.ErrCouponCodeTaken):
+		utils.WriteError(w, http.StatusConflict, err)
+	case errors.Is(err, types.ErrProductNotFound), errors.Is(err, types.ErrCategoryNotFound):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+	return false
+}
+
+// Util function to load the coupon addressed by the `{id}` path wildcard.
+// Writes the error response itself and returns false if it could not be loaded.
+func (h *Handler) getCouponFromPath(w http.ResponseWriter, r *http.Request) (*types.Coupon, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid coupon id %q", r.PathValue("id")))
+		return nil, false
+	}
+
+	coupon, err := h.store.GetCouponByID(id)
+	if errors.Is(err, types.ErrCouponNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return coupon, true
+}
@@ -0,0 +1,160 @@
+package coupon
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Normalize coupon codes sent at checkout (codes are case-insensitive).
+// Returns `types.ErrInvalidCoupon` if the same code is sent twice.
+func NormalizeCodes(codes []string) ([]string, error) {
+	normalized := make([]string, 0, len(codes))
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if slices.Contains(normalized, code) {
+			return nil, fmt.Errorf("%w: %q applied more than once", types.ErrInvalidCoupon, code)
+		}
+		normalized = append(normalized, code)
+	}
+	return normalized, nil
+}
+
+// Check that a coupon can be redeemed at `now`, given how many times it was
+// already used (`used` overall, `usedByUser` by the redeeming user).
+func CheckRedeemable(c types.Coupon, now time.Time, used, usedByUser int) error {
+	switch {
+	case !c.Active:
+		return fmt.Errorf("%w: %q is not active", types.ErrInvalidCoupon, c.Code)
+	case c.StartsAt != nil && now.Before(*c.StartsAt):
+		return fmt.Errorf("%w: %q is not valid yet", types.ErrInvalidCoupon, c.Code)
+	case c.EndsAt != nil && !now.Before(*c.EndsAt):
+		return fmt.Errorf("%w: %q has expired", types.ErrInvalidCoupon, c.Code)
+	case c.UsageLimit != nil && used >= *c.UsageLimit:
+		return fmt.Errorf("%w: %q has reached its usage limit", types.ErrInvalidCoupon, c.Code)
+	case c.PerUserLimit != nil && usedByUser >= *c.PerUserLimit:
+		return fmt.Errorf("%w: %q was already used the maximum number of times", types.ErrInvalidCoupon, c.Code)
+	}
+	return nil
+}
+
+// A cart line discounts are taken off of.
+type line struct {
+	productID int
+	remaining types.Money
+}
+
+// Work out the discount of each coupon on the cart, `prices` are the unit
+// prices of the items (same order as `items`).
+//
+// Rules:
+//   - a coupon that isn't stackable can't be combined with other coupons.
+//   - a coupon only applies to its products & the products of its categories
+//     (all products if it has neither), their line totals must add up to at
+//     least its minimum spend.
+//   - percentage coupons apply before fixed ones, each on what earlier
+//     coupons left of its lines, so discounts never exceed the items' price.
+//   - each discount is rounded once, half away from zero (see `types.Money`).
+//
+// Discounts are returned in the order they were applied, without an order ID.
+func Apply(coupons []types.Coupon, items []types.CartItem, prices []types.Money) ([]types.OrderDiscount, error) {
+	if len(coupons) > 1 {
+		for _, c := range coupons {
+			if !c.Stackable {
+				return nil, fmt.Errorf("%w: %q cannot be combined with other coupons", types.ErrInvalidCoupon, c.Code)
+			}
+		}
+	}
+
+	lines := make([]*line, len(items))
+	for i, item := range items {
+		lines[i] = &line{productID: item.ProductID, remaining: prices[i].Mul(item.Quantity)}
+	}
+
+	// Minimum spend is checked on the full price, before any discount.
+	for _, c := range coupons {
+		eligible := eligibleLines(c, lines)
+		if len(eligible) == 0 {
+			return nil, fmt.Errorf("%w: %q does not apply to any item in the cart", types.ErrInvalidCoupon, c.Code)
+		}
+		if sumLines(eligible).Amount < c.MinSpend.Amount {
+			return nil, fmt.Errorf("%w: %q requires a minimum spend of %s", types.ErrInvalidCoupon, c.Code, c.MinSpend)
+		}
+	}
+
+	ordered := slices.Clone(coupons)
+	slices.SortStableFunc(ordered, func(a, b types.Coupon) int {
+		return applyRank(a) - applyRank(b)
+	})
+
+	discounts := make([]types.OrderDiscount, 0, len(ordered))
+	for _, c := range ordered {
+		eligible := eligibleLines(c, lines)
+		base := sumLines(eligible)
+
+		var amount types.Money
+		switch c.Type {
+		case types.CouponTypePercentage:
+			amount = base.MulRatio(int64(c.PercentOff), 100)
+		case types.CouponTypeFixed:
+			amount = c.AmountOff.Min(base)
+		default:
+			return nil, fmt.Errorf("coupon %q has unknown type %q", c.Code, c.Type)
+		}
+
+		take(eligible, amount)
+		discounts = append(discounts, types.OrderDiscount{CouponID: c.ID, Code: c.Code, Amount: amount})
+	}
+
+	return discounts, nil
+}
+
+// Sum of the discounts' amounts.
+func Total(discounts []types.OrderDiscount) types.Money {
+	total := types.NewMoney(0)
+	for _, d := range discounts {
+		total = total.Add(d.Amount)
+	}
+	return total
+}
+
+func applyRank(c types.Coupon) int {
+	if c.Type == types.CouponTypePercentage {
+		return 0
+	}
+	return 1
+}
+
+func eligibleLines(c types.Coupon, lines []*line) []*line {
+	if len(c.ProductIDs) == 0 && len(c.CategoryIDs) == 0 {
+		return lines
+	}
+
+	eligible := make([]*line, 0, len(lines))
+	for _, l := range lines {
+		if slices.Contains(c.ProductIDs, l.productID) || slices.Contains(c.CategoryProductIDs, l.productID) {
+			eligible = append(eligible, l)
+		}
+	}
+	return eligible
+}
+
+func sumLines(lines []*line) types.Money {
+	total := types.NewMoney(0)
+	for _, l := range lines {
+		total = total.Add(l.remaining)
+	}
+	return total
+}
+
+// Take `amount` off the lines, in cart order. It never exceeds their sum.
+func take(lines []*line, amount types.Money) {
+	for _, l := range lines {
+		part := amount.Min(l.remaining)
+		l.remaining = l.remaining.Sub(part)
+		amount = amount.Sub(part)
+	}
+}
@@ -0,0 +1,154 @@
+package coupon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestApply(t *testing.T) {
+	items := []types.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 2}} // 19.99 + 10.00
+	prices := []types.Money{types.NewMoney(1999), types.NewMoney(500)}
+
+	t.Run("should round percentage discounts once", func(t *testing.T) {
+		coupons := []types.Coupon{{ID: 1, Code: "P15", Type: types.CouponTypePercentage, PercentOff: 15}}
+
+		discounts, err := Apply(coupons, items, prices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 15% of 29.99 = 4.4985
+		if discounts[0].Amount.String() != "4.50" {
+			t.Errorf("expected 4.50, got %s", discounts[0].Amount)
+		}
+	})
+
+	t.Run("should only discount products in scope", func(t *testing.T) {
+		coupons := []types.Coupon{{ID: 1, Code: "PENS", Type: types.CouponTypePercentage, PercentOff: 50, ProductIDs: []int{1}}}
+
+		discounts, err := Apply(coupons, items, prices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 50% of 19.99 = 9.995
+		if discounts[0].Amount.String() != "10.00" {
+			t.Errorf("expected 10.00, got %s", discounts[0].Amount)
+		}
+	})
+
+	t.Run("should cap fixed discounts at the items' price", func(t *testing.T) {
+		coupons := []types.Coupon{{ID: 1, Code: "BIG", Type: types.CouponTypeFixed, AmountOff: types.NewMoney(5000), ProductIDs: []int{2}}}
+
+		discounts, err := Apply(coupons, items, prices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if discounts[0].Amount.String() != "10.00" {
+			t.Errorf("expected 10.00, got %s", discounts[0].Amount)
+		}
+	})
+
+	t.Run("should apply stacked percentages before fixed amounts", func(t *testing.T) {
+		coupons := []types.Coupon{
+			{ID: 1, Code: "FIVE", Type: types.CouponTypeFixed, AmountOff: types.NewMoney(500), Stackable: true},
+			{ID: 2, Code: "P10", Type: types.CouponTypePercentage, PercentOff: 10, Stackable: true},
+		}
+
+		discounts, err := Apply(coupons, items, prices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 10% of 29.99 = 3.00, then 5.00 off.
+		if discounts[0].Code != "P10" || discounts[0].Amount.String() != "3.00" || discounts[1].Amount.String() != "5.00" {
+			t.Errorf("unexpected discounts %+v", discounts)
+		}
+		if Total(discounts).String() != "8.00" {
+			t.Errorf("expected 8.00 in total, got %s", Total(discounts))
+		}
+	})
+
+	t.Run("should reject combining non-stackable coupons", func(t *testing.T) {
+		coupons := []types.Coupon{
+			{ID: 1, Code: "A", Type: types.CouponTypeFixed, AmountOff: types.NewMoney(100), Stackable: true},
+			{ID: 2, Code: "B", Type: types.CouponTypeFixed, AmountOff: types.NewMoney(100)},
+		}
+		if _, err := Apply(coupons, items, prices); err == nil {
+			t.Error("expected stacking error")
+		}
+	})
+
+	t.Run("should enforce the minimum spend on the coupon's products", func(t *testing.T) {
+		coupons := []types.Coupon{{ID: 1, Code: "MIN", Type: types.CouponTypeFixed, AmountOff: types.NewMoney(100), MinSpend: types.NewMoney(1500), ProductIDs: []int{2}}}
+		if _, err := Apply(coupons, items, prices); err == nil {
+			t.Error("expected minimum spend error")
+		}
+	})
+
+	t.Run("should only discount products in the coupon's categories", func(t *testing.T) {
+		// Notebooks are in the category (or one below it), pens aren't.
+		coupons := []types.Coupon{{ID: 1, Code: "PAPER", Type: types.CouponTypePercentage, PercentOff: 50, CategoryIDs: []int{7}, CategoryProductIDs: []int{2}}}
+
+		discounts, err := Apply(coupons, items, prices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 50% of 10.00
+		if discounts[0].Amount.String() != "5.00" {
+			t.Errorf("expected 5.00, got %s", discounts[0].Amount)
+		}
+	})
+
+	t.Run("should reject coupons for categories without products in the cart", func(t *testing.T) {
+		coupons := []types.Coupon{{ID: 1, Code: "EMPTY", Type: types.CouponTypeFixed, AmountOff: types.NewMoney(100), CategoryIDs: []int{8}, CategoryProductIDs: []int{}}}
+		if _, err := Apply(coupons, items, prices); err == nil {
+			t.Error("expected scope error")
+		}
+	})
+
+	t.Run("should reject coupons for products not in the cart", func(t *testing.T) {
+		coupons := []types.Coupon{{ID: 1, Code: "OTHER", Type: types.CouponTypeFixed, AmountOff: types.NewMoney(100), ProductIDs: []int{3}}}
+		if _, err := Apply(coupons, items, prices); err == nil {
+			t.Error("expected scope error")
+		}
+	})
+}
+
+func TestCheckRedeemable(t *testing.T) {
+	now := time.Date(2024, 6, 26, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+	one := 1
+
+	cases := []struct {
+		name       string
+		coupon     types.Coupon
+		used, mine int
+		ok         bool
+	}{
+		{"active", types.Coupon{Active: true}, 0, 0, true},
+		{"inactive", types.Coupon{}, 0, 0, false},
+		{"inside window", types.Coupon{Active: true, StartsAt: &before, EndsAt: &after}, 0, 0, true},
+		{"not started", types.Coupon{Active: true, StartsAt: &after}, 0, 0, false},
+		{"expired", types.Coupon{Active: true, EndsAt: &before}, 0, 0, false},
+		{"global limit reached", types.Coupon{Active: true, UsageLimit: &one}, 1, 0, false},
+		{"user limit reached", types.Coupon{Active: true, PerUserLimit: &one}, 1, 1, false},
+		{"user limit not reached", types.Coupon{Active: true, PerUserLimit: &one}, 5, 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run("should handle "+c.name, func(t *testing.T) {
+			err := CheckRedeemable(c.coupon, now, c.used, c.mine)
+			if (err == nil) != c.ok {
+				t.Errorf("expected ok = %v, got %v", c.ok, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeCodes(t *testing.T) {
+	t.Run("should reject the same code twice", func(t *testing.T) {
+		if _, err := NormalizeCodes([]string{"save10", " SAVE10"}); err == nil {
+			t.Error("expected duplicate code error")
+		}
+	})
+}
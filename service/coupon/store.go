@@ -0,0 +1,288 @@
+package coupon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const couponColumns = "id, code, description, type, percentOff, amountOff, minSpend, usageLimit, perUserLimit, startsAt, endsAt, stackable, active, createdAt"
+
+// Get all coupons, newest first.
+func (s *Store) GetCoupons() ([]types.Coupon, error) {
+	return getCoupons(s.db, "SELECT "+couponColumns+" FROM coupons ORDER BY id DESC")
+}
+
+// Get a single coupon by its ID.
+// Returns `types.ErrCouponNotFound` if there is no such coupon.
+func (s *Store) GetCouponByID(id int) (*types.Coupon, error) {
+	coupons, err := getCoupons(s.db, "SELECT "+couponColumns+" FROM coupons WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(coupons) == 0 {
+		return nil, types.ErrCouponNotFound
+	}
+
+	return &coupons[0], nil
+}
+
+// Get the coupons with the given codes, unknown codes are left out.
+func (s *Store) GetCouponsByCodes(codes []string) ([]types.Coupon, error) {
+	return getCouponsByCodes(s.db, codes, false)
+}
+
+// Same as `GetCouponsByCodes` but locks the coupons until `tx` ends.
+func (s *Store) GetCouponsByCodesForUpdate(tx *sql.Tx, codes []string) ([]types.Coupon, error) {
+	return getCouponsByCodes(tx, codes, true)
+}
+
+func getCouponsByCodes(q db.Querier, codes []string, forUpdate bool) ([]types.Coupon, error) {
+	placeholders, args, ok := db.InList(codes)
+	if !ok {
+		return []types.Coupon{}, nil
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM coupons WHERE code IN (%s) ORDER BY id", couponColumns, placeholders)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	return getCoupons(q, query, args...)
+}
+
+func getCoupons(q db.Querier, query string, args ...any) ([]types.Coupon, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	coupons := make([]types.Coupon, 0)
+	for rows.Next() {
+		c, err := scanRowIntoCoupon(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		coupons = append(coupons, *c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Rows are closed first, a transaction can't run another query while they are open.
+	for i := range coupons {
+		coupons[i].ProductIDs, err = getIDs(q, "SELECT productId FROM coupon_products WHERE couponId = ? ORDER BY productId", coupons[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		coupons[i].CategoryIDs, err = getIDs(q, "SELECT categoryId FROM coupon_categories WHERE couponId = ? ORDER BY categoryId", coupons[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(coupons[i].CategoryIDs) == 0 {
+			coupons[i].CategoryProductIDs = []int{}
+			continue
+		}
+		// Products of the coupon's categories & all categories below them.
+		coupons[i].CategoryProductIDs, err = getIDs(q, `WITH RECURSIVE tree (id) AS (
+				SELECT categoryId FROM coupon_categories WHERE couponId = ?
+				UNION
+				SELECT c.id FROM categories c JOIN tree ON c.parentId = tree.id
+			)
+			SELECT DISTINCT pc.productId FROM product_categories pc JOIN tree ON pc.categoryId = tree.id ORDER BY pc.productId`, coupons[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return coupons, nil
+}
+
+// Get a single column of IDs.
+func getIDs(q db.Querier, query string, args ...any) ([]int, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Create a new coupon and return its ID.
+// Returns `types.ErrCouponCodeTaken` if another coupon uses the same code.
+func (s *Store) CreateCoupon(c types.Coupon) (int, error) {
+	var id int
+	err := db.NewTransactor(s.db).WithTx(context.Background(), func(tx *sql.Tx) error {
+		res, err := tx.Exec("INSERT INTO coupons (code, description, type, percentOff, amountOff, minSpend, usageLimit, perUserLimit, startsAt, endsAt, stackable, active) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			c.Code, c.Description, c.Type, c.PercentOff, c.AmountOff, c.MinSpend, c.UsageLimit, c.PerUserLimit, c.StartsAt, c.EndsAt, c.Stackable, c.Active)
+		if err != nil {
+			return mapCodeTaken(err)
+		}
+
+		insertID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		id = int(insertID)
+
+		if err := setCouponProducts(tx, id, c.ProductIDs); err != nil {
+			return err
+		}
+		return setCouponCategories(tx, id, c.CategoryIDs)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Replace all fields of a coupon, including the products & categories it applies to.
+func (s *Store) UpdateCoupon(c types.Coupon) error {
+	return db.NewTransactor(s.db).WithTx(context.Background(), func(tx *sql.Tx) error {
+		res, err := tx.Exec("UPDATE coupons SET code = ?, description = ?, type = ?, percentOff = ?, amountOff = ?, minSpend = ?, usageLimit = ?, perUserLimit = ?, startsAt = ?, endsAt = ?, stackable = ?, active = ? WHERE id = ?",
+			c.Code, c.Description, c.Type, c.PercentOff, c.AmountOff, c.MinSpend, c.UsageLimit, c.PerUserLimit, c.StartsAt, c.EndsAt, c.Stackable, c.Active, c.ID)
+		if err != nil {
+			return mapCodeTaken(err)
+		}
+
+		if err := db.CheckUpdated(tx, res, "coupons", c.ID, types.ErrCouponNotFound); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM coupon_products WHERE couponId = ?", c.ID); err != nil {
+			return err
+		}
+		if err := setCouponProducts(tx, c.ID, c.ProductIDs); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM coupon_categories WHERE couponId = ?", c.ID); err != nil {
+			return err
+		}
+		return setCouponCategories(tx, c.ID, c.CategoryIDs)
+	})
+}
+
+func setCouponProducts(tx *sql.Tx, couponID int, productIDs []int) error {
+	for _, productID := range productIDs {
+		_, err := tx.Exec("INSERT IGNORE INTO coupon_products (couponId, productId) VALUES (?, ?)", couponID, productID)
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 { // Foreign key: no such product.
+			return fmt.Errorf("%w: %d", types.ErrProductNotFound, productID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setCouponCategories(tx *sql.Tx, couponID int, categoryIDs []int) error {
+	for _, categoryID := range categoryIDs {
+		_, err := tx.Exec("INSERT IGNORE INTO coupon_categories (couponId, categoryId) VALUES (?, ?)", couponID, categoryID)
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 { // Foreign key: no such category.
+			return fmt.Errorf("%w: %d", types.ErrCategoryNotFound, categoryID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Duplicate key on the unique `code` column.
+func mapCodeTaken(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return types.ErrCouponCodeTaken
+	}
+	return err
+}
+
+// Count redemptions of a coupon, overall & by the user.
+// Discounts of cancelled orders are given back, so they are not counted.
+func (s *Store) GetCouponUsage(couponID, userID int) (total, byUser int, err error) {
+	return getCouponUsage(s.db, couponID, userID)
+}
+
+// Same as `GetCouponUsage` but as part of transaction `tx`.
+func (s *Store) GetCouponUsageTx(tx *sql.Tx, couponID, userID int) (total, byUser int, err error) {
+	return getCouponUsage(tx, couponID, userID)
+}
+
+func getCouponUsage(q db.Querier, couponID, userID int) (total, byUser int, err error) {
+	err = q.QueryRow(`SELECT COUNT(*), COALESCE(SUM(o.userId = ?), 0)
+		FROM order_discounts d JOIN orders o ON o.id = d.orderId
+		WHERE d.couponId = ? AND o.status <> ?`,
+		userID, couponID, types.OrderStatusCancelled).Scan(&total, &byUser)
+	return total, byUser, err
+}
+
+func scanRowIntoCoupon(rows *sql.Rows) (*types.Coupon, error) {
+	c := new(types.Coupon)
+	var usageLimit, perUserLimit sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+
+	err := rows.Scan(
+		&c.ID,
+		&c.Code,
+		&c.Description,
+		&c.Type,
+		&c.PercentOff,
+		&c.AmountOff,
+		&c.MinSpend,
+		&usageLimit,
+		&perUserLimit,
+		&startsAt,
+		&endsAt,
+		&c.Stackable,
+		&c.Active,
+		&c.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if usageLimit.Valid {
+		n := int(usageLimit.Int64)
+		c.UsageLimit = &n
+	}
+	if perUserLimit.Valid {
+		n := int(perUserLimit.Int64)
+		c.PerUserLimit = &n
+	}
+	if startsAt.Valid {
+		c.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		c.EndsAt = &endsAt.Time
+	}
+
+	return c, nil
+}
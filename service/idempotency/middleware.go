@@ -0,0 +1,160 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+	// Largest body buffered to fingerprint a request, see `WithIdempotencyKeyLimit()`.
+	DefaultMaxBodyBytes = 1 << 20
+)
+
+// Idempotency middleware for mutating endpoints.
+// Must be wrapped by `auth.WithJWTAuth()` (or `auth.WithOptionalJWTAuth()`),
+// keys are scoped per user. Guests have no user to scope keys to, their
+// requests are passed through untouched like requests without the header.
+//
+// The first response (status, headers & body) for a (user, key) pair is stored
+// and replayed on retries.
+// Reusing a key with a different payload, or while the first request is still
+// being processed, is rejected with http.StatusConflict.
+// Requests without the header are passed through untouched.
+func WithIdempotencyKey(handlerFunc http.HandlerFunc, store types.IdempotencyStore) http.HandlerFunc {
+	return WithIdempotencyKeyLimit(handlerFunc, store, DefaultMaxBodyBytes)
+}
+
+// Same as `WithIdempotencyKey()` for endpoints taking larger bodies (imports,
+// uploads). The body is buffered before the handler runs, bodies above
+// `maxBodyBytes` are rejected with http.StatusRequestEntityTooLarge.
+func WithIdempotencyKeyLimit(handlerFunc http.HandlerFunc, store types.IdempotencyStore, maxBodyBytes int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderKey)
+		userID := auth.GetUseIDFromContext(r.Context())
+		if key == "" || userID == -1 {
+			handlerFunc(w, r)
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s must be at most %d characters", HeaderKey, maxKeyLength))
+			return
+		}
+
+		// Reading the body to fingerprint the request, then restoring it...
+		// so the wrapped handler can still parse it.
+		body, err := readBody(w, r, maxBodyBytes)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body must be at most %d bytes", maxBytesErr.Limit))
+			return
+		}
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		hash := requestHash(r, body)
+
+		record, created, err := store.ReserveIdempotencyKey(userID, key, hash)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// Key already used, replay or reject.
+		if !created {
+			switch {
+			case record.RequestHash != hash:
+				utils.WriteError(w, http.StatusConflict, fmt.Errorf("%s has already been used with a different request", HeaderKey))
+			case record.StatusCode == 0:
+				utils.WriteError(w, http.StatusConflict, fmt.Errorf("a request with this %s is still being processed", HeaderKey))
+			default:
+				if record.ResponseHeaders == nil {
+					w.Header().Set("Content-Type", "application/json")
+				}
+				for name, values := range record.ResponseHeaders {
+					w.Header()[name] = values
+				}
+				w.Header().Set(HeaderReplayed, "true")
+				w.WriteHeader(record.StatusCode)
+				w.Write(record.ResponseBody)
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		handlerFunc(rec, r)
+
+		// Server errors are not persisted, the key is released so the client can retry.
+		if rec.status >= http.StatusInternalServerError {
+			if err := store.DeleteIdempotencyKey(userID, key); err != nil {
+				log.Printf("failed to release idempotency key: %v", err)
+			}
+			return
+		}
+
+		if err := store.SaveIdempotencyResponse(userID, key, rec.status, rec.Header().Clone(), rec.body.Bytes()); err != nil {
+			log.Printf("failed to save idempotent response: %v", err)
+		}
+	}
+}
+
+func readBody(w http.ResponseWriter, r *http.Request, maxBytes int64) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+// Fingerprint of method, path & body...
+// the same key sent to another endpoint counts as a different request.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Passes the response through while keeping a copy of status & body, the
+// headers are read from the wrapped writer once the handler is done.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
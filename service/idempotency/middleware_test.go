@@ -0,0 +1,125 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+)
+
+func TestWithIdempotencyKey(t *testing.T) {
+	store := newMockIdempotencyStore()
+	calls := 0
+	handler := WithIdempotencyKey(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", "/orders/"+strconv.Itoa(calls))
+		utils.WriteJSON(w, http.StatusOK, map[string]int{"order_id": calls})
+	}, store)
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/cart/checkout", bytes.NewBufferString(body))
+		req.Header.Set(HeaderKey, key)
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, 1))
+
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	first := send("abc", `{"items":[]}`)
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, first.Code)
+	}
+
+	t.Run("should replay the stored response on retry", func(t *testing.T) {
+		rr := send("abc", `{"items":[]}`)
+
+		if calls != 1 {
+			t.Errorf("expected handler to run once, ran %d times", calls)
+		}
+		if rr.Body.String() != first.Body.String() {
+			t.Errorf("expected replayed body %q, got %q", first.Body.String(), rr.Body.String())
+		}
+		if rr.Header().Get(HeaderReplayed) != "true" {
+			t.Errorf("expected %s header on replay", HeaderReplayed)
+		}
+		if rr.Header().Get("Location") != "/orders/1" || rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("expected the first response's headers, got %v", rr.Header())
+		}
+	})
+
+	t.Run("should reject a reused key with a different payload", func(t *testing.T) {
+		rr := send("abc", `{"items":[{"productID":1,"quantity":1}]}`)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("should run the handler for a new key", func(t *testing.T) {
+		send("def", `{"items":[]}`)
+
+		if calls != 2 {
+			t.Errorf("expected handler to run twice, ran %d times", calls)
+		}
+	})
+
+	t.Run("should reject bodies above the limit", func(t *testing.T) {
+		rr := send("jkl", `{"note":"`+strings.Repeat("a", DefaultMaxBodyBytes)+`"}`)
+
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected status code %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+		}
+		if calls != 2 || store.records["jkl"] != nil {
+			t.Errorf("expected the key to stay unclaimed, handler ran %d times", calls)
+		}
+	})
+
+	t.Run("should pass guest requests through", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/cart/items", bytes.NewBufferString(`{}`))
+			req.Header.Set(HeaderKey, "ghi")
+			handler(httptest.NewRecorder(), req)
+		}
+
+		if calls != 4 || store.records["ghi"] != nil {
+			t.Errorf("expected the handler to run for every guest request, ran %d times", calls)
+		}
+	})
+}
+
+type mockIdempotencyStore struct {
+	records map[string]*types.IdempotencyKey
+}
+
+func newMockIdempotencyStore() *mockIdempotencyStore {
+	return &mockIdempotencyStore{records: make(map[string]*types.IdempotencyKey)}
+}
+
+func (m *mockIdempotencyStore) ReserveIdempotencyKey(userID int, key, requestHash string) (*types.IdempotencyKey, bool, error) {
+	if record, ok := m.records[key]; ok {
+		return record, false, nil
+	}
+	record := &types.IdempotencyKey{UserID: userID, Key: key, RequestHash: requestHash}
+	m.records[key] = record
+	return record, true, nil
+}
+
+func (m *mockIdempotencyStore) SaveIdempotencyResponse(userID int, key string, statusCode int, header http.Header, body []byte) error {
+	m.records[key].StatusCode = statusCode
+	m.records[key].ResponseHeaders = header
+	m.records[key].ResponseBody = body
+	return nil
+}
+
+func (m *mockIdempotencyStore) DeleteIdempotencyKey(userID int, key string) error {
+	delete(m.records, key)
+	return nil
+}
@@ -0,0 +1,96 @@
+package idempotency
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// How long a stored response is replayed for.
+// Older keys are treated as unused and can be claimed again.
+const keyTTL = 24 * time.Hour
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Claim an idempotency key for the user.
+// `INSERT IGNORE` on the (userId, key) unique index makes the claim race-safe:
+// only one of two concurrent requests gets `created = true`.
+func (s *Store) ReserveIdempotencyKey(userID int, key, requestHash string) (*types.IdempotencyKey, bool, error) {
+	// Clearing out an expired record first so the key can be reused.
+	_, err := s.db.Exec("DELETE FROM idempotency_keys WHERE userId = ? AND `key` = ? AND createdAt < ?", userID, key, time.Now().Add(-keyTTL))
+	if err != nil {
+		return nil, false, err
+	}
+
+	res, err := s.db.Exec("INSERT IGNORE INTO idempotency_keys (userId, `key`, requestHash) VALUES (?, ?, ?)", userID, key, requestHash)
+	if err != nil {
+		return nil, false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	record, err := s.getIdempotencyKey(userID, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return record, n == 1, nil
+}
+
+// Store the final response of the request that claimed the key.
+func (s *Store) SaveIdempotencyResponse(userID int, key string, statusCode int, header http.Header, body []byte) error {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("UPDATE idempotency_keys SET statusCode = ?, responseHeaders = ?, responseBody = ? WHERE userId = ? AND `key` = ?", statusCode, headerJSON, body, userID, key)
+	return err
+}
+
+// Release a claimed key (e.g. after a server error) so the client can retry.
+func (s *Store) DeleteIdempotencyKey(userID int, key string) error {
+	_, err := s.db.Exec("DELETE FROM idempotency_keys WHERE userId = ? AND `key` = ?", userID, key)
+	return err
+}
+
+func (s *Store) getIdempotencyKey(userID int, key string) (*types.IdempotencyKey, error) {
+	row := s.db.QueryRow("SELECT id, userId, `key`, requestHash, statusCode, responseHeaders, responseBody, createdAt FROM idempotency_keys WHERE userId = ? AND `key` = ?", userID, key)
+
+	record := new(types.IdempotencyKey)
+	var statusCode sql.NullInt64
+	var headerJSON []byte
+	err := row.Scan(
+		&record.ID,
+		&record.UserID,
+		&record.Key,
+		&record.RequestHash,
+		&statusCode,
+		&headerJSON,
+		&record.ResponseBody,
+		&record.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	record.StatusCode = int(statusCode.Int64)
+	if headerJSON != nil {
+		if err := json.Unmarshal(headerJSON, &record.ResponseHeaders); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
@@ -0,0 +1,297 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+const movementColumns = "id, productId, variantId, warehouseId, type, delta, balance, orderId, actorId, reason, createdAt"
+
+// Get the movements of a product, newest first.
+// `beforeID` is the ID of the last movement of the previous page, 0 for the first page.
+func (s *Store) GetStockMovements(productID, limit, beforeID int) ([]types.StockMovement, error) {
+	query := "SELECT " + movementColumns + " FROM stock_movements WHERE productId = ?"
+	args := []any{productID}
+	if beforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, beforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	return getStockMovements(s.db, query, args...)
+}
+
+func getStockMovements(q db.Querier, query string, args ...any) ([]types.StockMovement, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := make([]types.StockMovement, 0)
+	for rows.Next() {
+		m := types.StockMovement{}
+		var variantID, orderID, actorID sql.NullInt64
+		err := rows.Scan(&m.ID, &m.ProductID, &variantID, &m.WarehouseID, &m.Type, &m.Delta, &m.Balance, &orderID, &actorID, &m.Reason, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		m.VariantID, m.OrderID, m.ActorID = int(variantID.Int64), int(orderID.Int64), int(actorID.Int64)
+		movements = append(movements, m)
+	}
+
+	return movements, rows.Err()
+}
+
+// Append a movement to the ledger & apply its delta to the product's quantity,
+// in a transaction of its own.
+func (s *Store) RecordStockMovement(m types.StockMovement) (*types.StockMovement, error) {
+	var recorded *types.StockMovement
+	err := db.NewTransactor(s.db).WithTx(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		recorded, err = recordStockMovement(tx, m)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recorded, nil
+}
+
+// Same as `RecordStockMovement` but as part of transaction `tx`.
+func (s *Store) RecordStockMovementTx(tx *sql.Tx, m types.StockMovement) (*types.StockMovement, error) {
+	return recordStockMovement(tx, m)
+}
+
+// The quantity, the variant's & the warehouse's stock and the ledger only
+// change together, the product row stays locked by the update until the
+// transaction ends, so balances are in order.
+func recordStockMovement(tx *sql.Tx, m types.StockMovement) (*types.StockMovement, error) {
+	if m.WarehouseID == 0 {
+		m.WarehouseID = types.DefaultWarehouseID
+	}
+
+	// `quantity` is unsigned, casting so negative deltas can be checked instead of failing.
+	res, err := tx.Exec("UPDATE products SET quantity = CAST(quantity AS SIGNED) + ? WHERE id = ? AND CAST(quantity AS SIGNED) + ? >= 0",
+		m.Delta, m.ProductID, m.Delta)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM products WHERE id = ?)", m.ProductID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, types.ErrProductNotFound
+		}
+		return nil, fmt.Errorf("%w: product %d", types.ErrOutOfStock, m.ProductID)
+	}
+
+	if m.VariantID > 0 {
+		if err := applyVariantDelta(tx, m); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyWarehouseDelta(tx, m); err != nil {
+		return nil, err
+	}
+
+	var balance int
+	if err := tx.QueryRow("SELECT quantity FROM products WHERE id = ?", m.ProductID).Scan(&balance); err != nil {
+		return nil, err
+	}
+
+	var variantID, orderID, actorID sql.NullInt64
+	if m.VariantID > 0 {
+		variantID = sql.NullInt64{Int64: int64(m.VariantID), Valid: true}
+	}
+	if m.OrderID > 0 {
+		orderID = sql.NullInt64{Int64: int64(m.OrderID), Valid: true}
+	}
+	if m.ActorID > 0 {
+		actorID = sql.NullInt64{Int64: int64(m.ActorID), Valid: true}
+	}
+
+	res, err = tx.Exec("INSERT INTO stock_movements (productId, variantId, warehouseId, type, delta, balance, orderId, actorId, reason) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		m.ProductID, variantID, m.WarehouseID, m.Type, m.Delta, balance, orderID, actorID, m.Reason)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 && m.OrderID > 0 { // Foreign key: no such order.
+		return nil, fmt.Errorf("%w: %d", types.ErrOrderNotFound, m.OrderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	// Reading it back for DB generated fields (createdAt).
+	movements, err := getStockMovements(tx, "SELECT "+movementColumns+" FROM stock_movements WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(movements) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return &movements[0], nil
+}
+
+// Apply a movement's delta to the variant's quantity, the variant must belong
+// to the movement's product.
+func applyVariantDelta(tx *sql.Tx, m types.StockMovement) error {
+	res, err := tx.Exec("UPDATE product_variants SET quantity = CAST(quantity AS SIGNED) + ? WHERE id = ? AND productId = ? AND CAST(quantity AS SIGNED) + ? >= 0",
+		m.Delta, m.VariantID, m.ProductID, m.Delta)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM product_variants WHERE id = ? AND productId = ?)", m.VariantID, m.ProductID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %d of product %d", types.ErrVariantNotFound, m.VariantID, m.ProductID)
+		}
+		return fmt.Errorf("%w: variant %d", types.ErrOutOfStock, m.VariantID)
+	}
+
+	return nil
+}
+
+// Apply a movement's delta to the product's stock at the movement's warehouse.
+// Warehouses hold stock per product, variants are counted in it.
+func applyWarehouseDelta(tx *sql.Tx, m types.StockMovement) error {
+	// Making sure the row exists, first movement of the product at the warehouse.
+	_, err := tx.Exec("INSERT INTO warehouse_stock (warehouseId, productId, quantity) VALUES (?, ?, 0) ON DUPLICATE KEY UPDATE quantity = quantity",
+		m.WarehouseID, m.ProductID)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 { // Foreign key: no such warehouse.
+		return fmt.Errorf("%w: %d", types.ErrWarehouseNotFound, m.WarehouseID)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec("UPDATE warehouse_stock SET quantity = CAST(quantity AS SIGNED) + ? WHERE warehouseId = ? AND productId = ? AND CAST(quantity AS SIGNED) + ? >= 0",
+		m.Delta, m.WarehouseID, m.ProductID, m.Delta)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%w: product %d at warehouse %d", types.ErrOutOfStock, m.ProductID, m.WarehouseID)
+	}
+
+	return nil
+}
+
+// Products & the sum of their movements, for those where it doesn't match the
+// quantity: per warehouse first, then the products' totals (warehouse 0),
+// then the variants' quantities. `locking` is appended to every part, so
+// reconciling can read the latest committed rows & keep them locked.
+func driftQuery(locking string) string {
+	return `(SELECT s.productId, s.warehouseId, 0, s.quantity, COALESCE(SUM(m.delta), 0) AS ledgerQuantity
+	FROM warehouse_stock s LEFT JOIN stock_movements m ON m.productId = s.productId AND m.warehouseId = s.warehouseId
+	GROUP BY s.productId, s.warehouseId, s.quantity
+	HAVING s.quantity <> ledgerQuantity` + locking + `)
+	UNION ALL
+	(SELECT p.id, 0, 0, p.quantity, COALESCE(SUM(m.delta), 0) AS ledgerQuantity
+	FROM products p LEFT JOIN stock_movements m ON m.productId = p.id
+	GROUP BY p.id, p.quantity
+	HAVING p.quantity <> ledgerQuantity` + locking + `)
+	UNION ALL
+	(SELECT v.productId, 0, v.id, v.quantity, COALESCE(SUM(m.delta), 0) AS ledgerQuantity
+	FROM product_variants v LEFT JOIN stock_movements m ON m.variantId = v.id
+	GROUP BY v.productId, v.id, v.quantity
+	HAVING v.quantity <> ledgerQuantity` + locking + `)
+	ORDER BY 1, 2, 3`
+}
+
+// Get the products whose quantity doesn't match the sum of their movements.
+func (s *Store) GetStockDrift() ([]types.StockDrift, error) {
+	return getStockDrift(s.db, driftQuery(""))
+}
+
+func getStockDrift(q db.Querier, query string) ([]types.StockDrift, error) {
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drift := make([]types.StockDrift, 0)
+	for rows.Next() {
+		d := types.StockDrift{}
+		if err := rows.Scan(&d.ProductID, &d.WarehouseID, &d.VariantID, &d.Quantity, &d.LedgerQuantity); err != nil {
+			return nil, err
+		}
+		drift = append(drift, d)
+	}
+
+	return drift, rows.Err()
+}
+
+// Reset drifted quantities to the sum of their movements, the ledger is the
+// source of truth. Returns the drift that was fixed.
+func (s *Store) ReconcileStock() ([]types.StockDrift, error) {
+	var drift []types.StockDrift
+	err := db.NewTransactor(s.db).WithTx(context.Background(), func(tx *sql.Tx) error {
+		// Every movement updates its product's row first, so locking all the
+		// products, in the same order, holds new movements off until the reset
+		// is committed. Then reading the drift with a locking read, a plain
+		// one would come from a snapshot that can miss committed movements.
+		if err := db.LockRows(tx, "SELECT id FROM products ORDER BY id FOR UPDATE"); err != nil {
+			return err
+		}
+
+		var err error
+		if drift, err = getStockDrift(tx, driftQuery(" FOR SHARE")); err != nil {
+			return err
+		}
+
+		for _, d := range drift {
+			// Negative sums can only come from edited movements, no stock is the closest.
+			query := "UPDATE products SET quantity = ? WHERE id = ?"
+			args := []any{max(d.LedgerQuantity, 0), d.ProductID}
+			switch {
+			case d.WarehouseID > 0:
+				query = "UPDATE warehouse_stock SET quantity = ? WHERE productId = ? AND warehouseId = ?"
+				args = append(args, d.WarehouseID)
+			case d.VariantID > 0:
+				query = "UPDATE product_variants SET quantity = ? WHERE productId = ? AND id = ?"
+				args = append(args, d.VariantID)
+			}
+			if _, err := tx.Exec(query, args...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return drift, nil
+}
@@ -0,0 +1,178 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+// Page size limits for `GET /inventory/products/{id}/movements`.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+type Handler struct {
+	store            types.StockLedgerStore
+	productStore     types.ProductStore
+	warehouseStore   types.WarehouseStore
+	userStore        types.UserStore
+	idempotencyStore types.IdempotencyStore
+}
+
+func NewHandler(store types.StockLedgerStore, productStore types.ProductStore, warehouseStore types.WarehouseStore, userStore types.UserStore, idempotencyStore types.IdempotencyStore) *Handler {
+	return &Handler{store: store, productStore: productStore, warehouseStore: warehouseStore, userStore: userStore, idempotencyStore: idempotencyStore}
+}
+
+// Stock ledger management, `admin` users only.
+func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /inventory/products/{id}/stock", auth.WithRole(h.handleGetStock, h.userStore, types.RoleAdmin))
+	router.HandleFunc("GET /inventory/products/{id}/movements", auth.WithRole(h.handleGetMovements, h.userStore, types.RoleAdmin))
+	router.HandleFunc("POST /inventory/products/{id}/movements", auth.WithRole(idempotency.WithIdempotencyKey(h.handleCreateMovement, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("GET /inventory/drift", auth.WithRole(h.handleGetDrift, h.userStore, types.RoleAdmin))
+	router.HandleFunc("POST /inventory/reconcile", auth.WithRole(idempotency.WithIdempotencyKey(h.handleReconcile, h.idempotencyStore), h.userStore, types.RoleAdmin))
+}
+
+// HandlerFunc to get the stock of a product at each warehouse (admin).
+func (h *Handler) handleGetStock(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	stock, err := h.warehouseStore.GetProductStock([]int{product.ID}, 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, stock)
+}
+
+// HandlerFunc to get the stock movements of a product (admin), newest first.
+func (h *Handler) handleGetMovements(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	limit := DefaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+		limit = min(l, MaxPageSize)
+	}
+
+	// The cursor is the ID of the last movement of the previous page.
+	beforeID := 0
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, types.ErrInvalidCursor)
+			return
+		}
+		beforeID = id
+	}
+
+	// Fetching one extra movement to know if there is a next page.
+	movements, err := h.store.GetStockMovements(product.ID, limit+1, beforeID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	page := types.StockMovementPage{Movements: movements}
+	if len(movements) > limit {
+		page.Movements = movements[:limit]
+		page.NextCursor = strconv.Itoa(page.Movements[limit-1].ID)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// HandlerFunc to post a manual movement (admin): a stock count adjustment or
+// a customer return. The product's quantity & its stock at the warehouse
+// change by the movement's delta, along with the variant's quantity if one is
+// given.
+func (h *Handler) handleCreateMovement(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	var payload types.StockMovementPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	// Returns put stock back, they can't take it off.
+	if payload.Type == types.StockMovementReturn && payload.Delta < 0 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("returns must have a positive delta"))
+		return
+	}
+
+	movement, err := h.store.RecordStockMovement(types.StockMovement{
+		ProductID:   product.ID,
+		VariantID:   payload.VariantID,
+		WarehouseID: payload.WarehouseID,
+		Type:        payload.Type,
+		Delta:       payload.Delta,
+		OrderID:     payload.OrderID,
+		ActorID:     auth.GetUseIDFromContext(r.Context()),
+		Reason:      payload.Reason,
+	})
+	switch {
+	case errors.Is(err, types.ErrProductNotFound), errors.Is(err, types.ErrVariantNotFound),
+		errors.Is(err, types.ErrOrderNotFound), errors.Is(err, types.ErrWarehouseNotFound):
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	case errors.Is(err, types.ErrOutOfStock):
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, movement)
+}
+
+// HandlerFunc to list the products whose quantity doesn't match their ledger (admin).
+func (h *Handler) handleGetDrift(w http.ResponseWriter, r *http.Request) {
+	drift, err := h.store.GetStockDrift()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, drift)
+}
+
+// HandlerFunc to reset drifted quantities to their ledger (admin).
+// Responds with the drift that was fixed.
+func (h *Handler) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	drift, err := h.store.ReconcileStock()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, drift)
+}
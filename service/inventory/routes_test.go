@@ -0,0 +1,131 @@
+package inventory
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestInventoryHandlers(t *testing.T) {
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleCustomer},
+		2: {ID: 2, Role: types.RoleAdmin},
+	}}
+	productStore := &mockProductStore{products: map[int]types.Product{1: {ID: 1, Quantity: 5}}}
+	ledgerStore := &mockLedgerStore{products: productStore}
+	handler := NewHandler(ledgerStore, productStore, nil, userStore, nil)
+
+	router := http.NewServeMux()
+	handler.RegisterRoutes(router)
+
+	send := func(method, path, body string, userID int) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := auth.CreateJWT(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should forbid customers from posting movements", func(t *testing.T) {
+		rr := send(http.MethodPost, "/inventory/products/1/movements", `{"type":"adjustment","delta":1,"reason":"count"}`, 1)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should record an adjustment by the admin", func(t *testing.T) {
+		rr := send(http.MethodPost, "/inventory/products/1/movements", `{"type":"adjustment","delta":-2,"reason":"damaged"}`, 2)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if quantity := productStore.products[1].Quantity; quantity != 3 {
+			t.Errorf("expected quantity 3, got %d", quantity)
+		}
+		if m := ledgerStore.movements; len(m) != 1 || m[0].ActorID != 2 || m[0].Reason != "damaged" {
+			t.Errorf("unexpected movements %+v", m)
+		}
+	})
+
+	t.Run("should not take more stock than there is", func(t *testing.T) {
+		rr := send(http.MethodPost, "/inventory/products/1/movements", `{"type":"adjustment","delta":-4,"reason":"count"}`, 2)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("should reject returns taking stock off", func(t *testing.T) {
+		rr := send(http.MethodPost, "/inventory/products/1/movements", `{"type":"return","delta":-1,"reason":"oops"}`, 2)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should only accept manual movement types", func(t *testing.T) {
+		rr := send(http.MethodPost, "/inventory/products/1/movements", `{"type":"sale","delta":-1,"reason":"sold"}`, 2)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should return not found for unknown products", func(t *testing.T) {
+		if rr := send(http.MethodGet, "/inventory/products/42/movements", "", 2); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+type mockUserStore struct {
+	types.UserStore
+	users map[int]*types.User
+}
+
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	return m.users[id], nil
+}
+
+type mockProductStore struct {
+	types.ProductStore
+	products map[int]types.Product
+}
+
+func (m *mockProductStore) GetProductByID(id int) (*types.Product, error) {
+	p, ok := m.products[id]
+	if !ok {
+		return nil, types.ErrProductNotFound
+	}
+	return &p, nil
+}
+
+// Applies movements to the mocked products' quantities, like the store.
+type mockLedgerStore struct {
+	types.StockLedgerStore
+	products  *mockProductStore
+	movements []types.StockMovement
+}
+
+func (m *mockLedgerStore) RecordStockMovement(movement types.StockMovement) (*types.StockMovement, error) {
+	p := m.products.products[movement.ProductID]
+	if p.Quantity+movement.Delta < 0 {
+		return nil, types.ErrOutOfStock
+	}
+	p.Quantity += movement.Delta
+	m.products.products[p.ID] = p
+
+	movement.Balance = p.Quantity
+	m.movements = append(m.movements, movement)
+	return &movement, nil
+}
@@ -0,0 +1,144 @@
+package inventory
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Get the active reservations of a cart.
+func (s *Store) GetCartReservations(cartID int) ([]types.StockReservation, error) {
+	rows, err := s.db.Query(`SELECT id, productId, variantId, cartId, orderId, warehouseId, quantity, expiresAt, createdAt
+		FROM stock_reservations WHERE cartId = ? AND expiresAt > ? ORDER BY productId, variantId`, cartID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservations := make([]types.StockReservation, 0)
+	for rows.Next() {
+		r := types.StockReservation{}
+		var variantID, cartID, orderID, warehouseID sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.ProductID, &variantID, &cartID, &orderID, &warehouseID, &r.Quantity, &r.ExpiresAt, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.VariantID, r.CartID, r.OrderID, r.WarehouseID = int(variantID.Int64), int(cartID.Int64), int(orderID.Int64), int(warehouseID.Int64)
+		reservations = append(reservations, r)
+	}
+
+	return reservations, rows.Err()
+}
+
+// Get the quantities held by active reservations, by product.
+// Reservations of `cartID` & `orderID` (0 for none) are left out.
+func (s *Store) GetReservedQuantities(productIDs []int, cartID, orderID int) (map[int]int, error) {
+	return getReservedQuantities(s.db, "productId", productIDs, cartID, orderID, false)
+}
+
+// Same as `GetReservedQuantities` but locks the reservations until `tx` ends.
+// Callers hold the products' row locks, new reservations of them wait for `tx`.
+func (s *Store) GetReservedQuantitiesTx(tx *sql.Tx, productIDs []int, cartID, orderID int) (map[int]int, error) {
+	return getReservedQuantities(tx, "productId", productIDs, cartID, orderID, true)
+}
+
+// Get the quantities held by active reservations, by variant.
+// Reservations of `cartID` & `orderID` (0 for none) are left out.
+func (s *Store) GetReservedVariantQuantities(variantIDs []int, cartID, orderID int) (map[int]int, error) {
+	return getReservedQuantities(s.db, "variantId", variantIDs, cartID, orderID, false)
+}
+
+// Same as `GetReservedVariantQuantities` but locks the reservations until `tx` ends.
+func (s *Store) GetReservedVariantQuantitiesTx(tx *sql.Tx, variantIDs []int, cartID, orderID int) (map[int]int, error) {
+	return getReservedQuantities(tx, "variantId", variantIDs, cartID, orderID, true)
+}
+
+// Sums reservations by `column`, either `productId` or `variantId`.
+func getReservedQuantities(q db.Querier, column string, ids []int, cartID, orderID int, forUpdate bool) (map[int]int, error) {
+	reserved := make(map[int]int)
+	placeholders, args, ok := db.InList(ids)
+	if !ok {
+		return reserved, nil
+	}
+	args = append(args, time.Now(), cartID, orderID)
+
+	// `<=>` is NULL-safe, reservations of other carts & orders have NULL in one of both.
+	query := fmt.Sprintf(`SELECT %[1]s, SUM(quantity) FROM stock_reservations
+		WHERE %[1]s IN (%[2]s) AND expiresAt > ?
+		AND NOT (cartId <=> ?) AND NOT (orderId <=> ?)
+		GROUP BY %[1]s`, column, placeholders)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, quantity int
+		if err := rows.Scan(&id, &quantity); err != nil {
+			return nil, err
+		}
+		reserved[id] = quantity
+	}
+
+	return reserved, rows.Err()
+}
+
+// Create a reservation for either a cart or an order, as part of transaction `tx`.
+func (s *Store) CreateReservationTx(tx *sql.Tx, r types.StockReservation) error {
+	var variantID, cartID, orderID, warehouseID sql.NullInt64
+	if r.VariantID > 0 {
+		variantID = sql.NullInt64{Int64: int64(r.VariantID), Valid: true}
+	}
+	if r.CartID > 0 {
+		cartID = sql.NullInt64{Int64: int64(r.CartID), Valid: true}
+	}
+	if r.OrderID > 0 {
+		orderID = sql.NullInt64{Int64: int64(r.OrderID), Valid: true}
+	}
+	if r.WarehouseID > 0 {
+		warehouseID = sql.NullInt64{Int64: int64(r.WarehouseID), Valid: true}
+	}
+
+	_, err := tx.Exec("INSERT INTO stock_reservations (productId, variantId, cartId, orderId, warehouseId, quantity, expiresAt) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		r.ProductID, variantID, cartID, orderID, warehouseID, r.Quantity, r.ExpiresAt)
+	return err
+}
+
+// Release the reservations of a cart, as part of transaction `tx`.
+func (s *Store) DeleteCartReservationsTx(tx *sql.Tx, cartID int) error {
+	_, err := tx.Exec("DELETE FROM stock_reservations WHERE cartId = ?", cartID)
+	return err
+}
+
+// Release the reservations of an order, as part of transaction `tx`.
+func (s *Store) DeleteOrderReservationsTx(tx *sql.Tx, orderID int) error {
+	_, err := tx.Exec("DELETE FROM stock_reservations WHERE orderId = ?", orderID)
+	return err
+}
+
+// Delete expired reservations. They already stopped holding stock when they
+// expired, this only keeps the table small.
+func (s *Store) DeleteExpiredReservations() (int, error) {
+	res, err := s.db.Exec("DELETE FROM stock_reservations WHERE expiresAt <= ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
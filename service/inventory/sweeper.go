@@ -0,0 +1,33 @@
+package inventory
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Delete expired reservations every `interval` until `ctx` is done.
+// Meant to run in the background for the lifetime of the API server.
+func SweepExpiredReservations(ctx context.Context, store types.ReservationStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := store.DeleteExpiredReservations()
+			if err != nil {
+				// Expired reservations don't hold stock anyway, retrying on the next tick.
+				log.Printf("failed to sweep expired reservations: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("released %d expired stock reservations", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package inventory
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestSweepExpiredReservations(t *testing.T) {
+	t.Run("should sweep until the context is done", func(t *testing.T) {
+		store := &mockReservationStore{}
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan struct{})
+		go func() {
+			SweepExpiredReservations(ctx, store, time.Millisecond)
+			close(done)
+		}()
+
+		deadline := time.After(time.Second)
+		for store.sweeps.Load() < 2 {
+			select {
+			case <-deadline:
+				t.Fatal("expected the sweeper to run")
+			case <-time.After(time.Millisecond):
+			}
+		}
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected the sweeper to stop")
+		}
+	})
+}
+
+type mockReservationStore struct {
+	types.ReservationStore
+	sweeps atomic.Int32
+}
+
+func (m *mockReservationStore) DeleteExpiredReservations() (int, error) {
+	m.sweeps.Add(1)
+	return 1, nil
+}
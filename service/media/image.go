@@ -0,0 +1,168 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // Registers the GIF decoder, only the first frame of animations is kept.
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Registers the WebP decoder with `image.Decode()`.
+)
+
+// Largest accepted image in pixels, decoding takes 4 bytes per pixel.
+const MaxImagePixels = 6000 * 6000
+
+// Resized variants of every image, each fits in a `MaxSide` square.
+// `types.ProductImageURLs` has a field for each.
+var ImageSizes = []struct {
+	Name    string
+	MaxSide int
+}{
+	{"medium", 800},
+	{"thumb", 200},
+}
+
+// File extension of each accepted (sniffed) content type.
+var imageTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// A file of a processed image, stored under the image's key.
+type ImageFile struct {
+	Name        string // e.g. "original.jpg", "thumb.webp"
+	ContentType string
+	Data        []byte
+}
+
+// A processed upload: the original as uploaded & its resized variants, in
+// the original's format family (JPEG for JPEGs, PNG otherwise) & as WebP.
+type ProcessedImage struct {
+	ContentType string
+	Width       int
+	Height      int
+	Files       []ImageFile
+}
+
+// Sniff, decode & resize an uploaded image. The content type is taken from
+// the data, not from what the client claims. Returns
+// `types.ErrUnsupportedImage` for anything but JPEG, PNG, GIF & WebP and
+// `types.ErrImageTooLarge` above `MaxImagePixels`.
+func ProcessImage(data []byte) (*ProcessedImage, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return nil, types.ErrUnsupportedImage
+	}
+
+	// Checking the size from the header before decoding, a small file can
+	// claim a huge image.
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", types.ErrUnsupportedImage, err)
+	}
+	if config.Width*config.Height > MaxImagePixels {
+		return nil, types.ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", types.ErrUnsupportedImage, err)
+	}
+
+	processed := &ProcessedImage{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Files:       []ImageFile{{Name: "original." + ext, ContentType: contentType, Data: data}},
+	}
+
+	for _, size := range ImageSizes {
+		resized := resize(img, size.MaxSide)
+
+		var buf bytes.Buffer
+		file := ImageFile{Name: size.Name + "." + variantExt(contentType)}
+		if contentType == "image/jpeg" {
+			file.ContentType = "image/jpeg"
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85})
+		} else {
+			file.ContentType = "image/png"
+			err = png.Encode(&buf, resized)
+		}
+		if err != nil {
+			return nil, err
+		}
+		file.Data = buf.Bytes()
+
+		var webp bytes.Buffer
+		if err := nativewebp.Encode(&webp, resized, nil); err != nil {
+			return nil, err
+		}
+
+		processed.Files = append(processed.Files, file, ImageFile{Name: size.Name + ".webp", ContentType: "image/webp", Data: webp.Bytes()})
+	}
+
+	return processed, nil
+}
+
+// Scale `img` down to fit in a `maxSide` square, keeping its aspect ratio.
+// Smaller images are returned as is.
+func resize(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	if w >= h {
+		w, h = maxSide, max(h*maxSide/w, 1)
+	} else {
+		w, h = max(w*maxSide/h, 1), maxSide
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// Names of the files `ProcessImage()` makes of an image of `contentType`,
+// original first.
+func imageFileNames(contentType string) []string {
+	names := []string{"original." + imageTypes[contentType]}
+	for _, size := range ImageSizes {
+		names = append(names, size.Name+"."+variantExt(contentType), size.Name+".webp")
+	}
+	return names
+}
+
+// Resized JPEGs stay JPEGs, anything else may have transparency & becomes a PNG.
+func variantExt(contentType string) string {
+	if contentType == "image/jpeg" {
+		return "jpg"
+	}
+	return "png"
+}
+
+// Public URLs of the files of `img` (see `ProcessImage()`) in `storage`.
+func ImageURLs(storage types.BlobStorage, img types.ProductImage) types.ProductImageURLs {
+	file := func(name string) string {
+		return storage.URL(img.Key + "/" + name)
+	}
+	ext := variantExt(img.ContentType)
+
+	return types.ProductImageURLs{
+		Original:      file("original." + imageTypes[img.ContentType]),
+		Medium:        file("medium." + ext),
+		MediumWebP:    file("medium.webp"),
+		Thumbnail:     file("thumb." + ext),
+		ThumbnailWebP: file("thumb.webp"),
+	}
+}
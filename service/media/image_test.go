@@ -0,0 +1,119 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"golang.org/x/image/webp"
+)
+
+// A `w` x `h` PNG (or JPEG) filled with a single color.
+func testImage(t *testing.T, w, h int, asJPEG bool) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 50, B: 50, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if asJPEG {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessImage(t *testing.T) {
+	t.Run("should keep the original & resize it to every size", func(t *testing.T) {
+		processed, err := ProcessImage(testImage(t, 1600, 400, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if processed.ContentType != "image/png" || processed.Width != 1600 || processed.Height != 400 {
+			t.Errorf("unexpected image %s %dx%d", processed.ContentType, processed.Width, processed.Height)
+		}
+
+		files := map[string]ImageFile{}
+		for _, f := range processed.Files {
+			files[f.Name] = f
+		}
+		for _, name := range imageFileNames("image/png") {
+			if _, ok := files[name]; !ok {
+				t.Errorf("expected file %s, got %d files", name, len(files))
+			}
+		}
+
+		thumb, err := png.DecodeConfig(bytes.NewReader(files["thumb.png"].Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if thumb.Width != 200 || thumb.Height != 50 {
+			t.Errorf("expected a 200x50 thumbnail, got %dx%d", thumb.Width, thumb.Height)
+		}
+
+		medium, err := webp.DecodeConfig(bytes.NewReader(files["medium.webp"].Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if medium.Width != 800 || medium.Height != 200 {
+			t.Errorf("expected a 800x200 WebP, got %dx%d", medium.Width, medium.Height)
+		}
+	})
+
+	t.Run("should not upscale small images & keep JPEGs as JPEGs", func(t *testing.T) {
+		processed, err := ProcessImage(testImage(t, 100, 150, true))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, f := range processed.Files {
+			if f.Name != "medium.jpg" {
+				continue
+			}
+			config, err := jpeg.DecodeConfig(bytes.NewReader(f.Data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Width != 100 || config.Height != 150 {
+				t.Errorf("expected the original size, got %dx%d", config.Width, config.Height)
+			}
+			return
+		}
+		t.Error("expected a medium.jpg file")
+	})
+
+	t.Run("should sniff the type from the data", func(t *testing.T) {
+		_, err := ProcessImage([]byte("<html><body>not an image</body></html>"))
+
+		if !errors.Is(err, types.ErrUnsupportedImage) {
+			t.Errorf("expected ErrUnsupportedImage, got %v", err)
+		}
+	})
+
+	t.Run("should refuse images above the pixel limit before decoding", func(t *testing.T) {
+		// A valid PNG header claiming 10000x10000 pixels, without any pixel data.
+		data := testImage(t, 1, 1, false)
+		header := bytes.Clone(data[:33])
+		copy(header[16:24], []byte{0, 0, 0x27, 0x10, 0, 0, 0x27, 0x10})
+		binary.BigEndian.PutUint32(header[29:33], crc32.ChecksumIEEE(header[12:29]))
+
+		if _, err := ProcessImage(header); !errors.Is(err, types.ErrImageTooLarge) {
+			t.Errorf("expected ErrImageTooLarge, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,379 @@
+package media
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+// Room for the `alt` field & multipart headers on top of `MaxImageUploadBytes`.
+const uploadOverheadBytes = 1 << 20
+
+type Handler struct {
+	store            types.ProductImageStore
+	productStore     types.ProductStore
+	storage          types.BlobStorage
+	userStore        types.UserStore
+	idempotencyStore types.IdempotencyStore
+	transactor       types.Transactor
+}
+
+func NewHandler(store types.ProductImageStore, productStore types.ProductStore, storage types.BlobStorage, userStore types.UserStore, idempotencyStore types.IdempotencyStore, transactor types.Transactor) *Handler {
+	return &Handler{store: store, productStore: productStore, storage: storage, userStore: userStore, idempotencyStore: idempotencyStore, transactor: transactor}
+}
+
+func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /products/{id}/images", h.handleGetImages)
+
+	// Image management, `admin` users only.
+	router.HandleFunc("POST /products/{id}/images", auth.WithRole(idempotency.WithIdempotencyKeyLimit(h.handleUploadImage, h.idempotencyStore, config.Envs.MaxImageUploadBytes+uploadOverheadBytes), h.userStore, types.RoleAdmin))
+	router.HandleFunc("PUT /products/{id}/images/order", auth.WithRole(idempotency.WithIdempotencyKey(h.handleReorderImages, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("PATCH /products/{id}/images/{imageID}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleUpdateImage, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("DELETE /products/{id}/images/{imageID}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleDeleteImage, h.idempotencyStore), h.userStore, types.RoleAdmin))
+}
+
+// HandlerFunc to get the images of a product, primary image first.
+func (h *Handler) handleGetImages(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	images, err := h.getImages(product.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONWithETag(w, r, http.StatusOK, images)
+}
+
+// HandlerFunc to upload an image (multipart `image` field, optional `alt`),
+// added after the product's other images.
+func (h *Handler) handleUploadImage(w http.ResponseWriter, r *http.Request) {
+	// General Flow:
+	// 1. Read the upload, refusing anything above `MaxImageUploadBytes`.
+	// 2. Sniff, decode & resize it (see `ProcessImage()`).
+	// 3. Store the original & its variants, then record the image in DB.
+	// 4. Point the product's `image` at the primary image & respond with the
+	//    created image & http.StatusCreated.
+
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	data, alt, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	processed, err := ProcessImage(data)
+	if errors.Is(err, types.ErrUnsupportedImage) {
+		utils.WriteError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	if errors.Is(err, types.ErrImageTooLarge) {
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// A new key per upload, stored files never change (see `LocalStorage.Handler()`).
+	key, err := newImageKey(product.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	names := imageFileNames(processed.ContentType)
+	for i, file := range processed.Files {
+		if err := h.storage.Put(r.Context(), key+"/"+file.Name, file.ContentType, bytes.NewReader(file.Data)); err != nil {
+			h.deleteFiles(r, key, names[:i])
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	img := types.ProductImage{
+		ProductID:   product.ID,
+		Alt:         alt,
+		ContentType: processed.ContentType,
+		Width:       processed.Width,
+		Height:      processed.Height,
+		Key:         key,
+	}
+	id, err := h.store.CreateProductImage(img)
+	if err != nil {
+		h.deleteFiles(r, key, names)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	created, err := h.store.GetProductImage(product.ID, id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	created.URLs = ImageURLs(h.storage, *created)
+
+	if _, err := h.syncPrimaryImage(product); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// HandlerFunc to reorder the images of a product, the first one becomes the primary image.
+func (h *Handler) handleReorderImages(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	var payload types.ReorderProductImagesPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	images, err := h.store.GetProductImages(product.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !isPermutation(images, payload.ImageIDs) {
+		utils.WriteError(w, http.StatusBadRequest, types.ErrInvalidImageList)
+		return
+	}
+
+	err = h.transactor.WithTx(r.Context(), func(tx *sql.Tx) error {
+		return h.store.ReorderProductImagesTx(tx, product.ID, payload.ImageIDs)
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	images, err = h.syncPrimaryImage(product)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, images)
+}
+
+// HandlerFunc to change the alt text of an image.
+func (h *Handler) handleUpdateImage(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+	img, ok := h.getImageFromPath(w, r, product.ID)
+	if !ok {
+		return
+	}
+
+	var payload types.UpdateProductImagePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	img.Alt = payload.Alt
+	if err := h.store.UpdateProductImage(*img); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	img.URLs = ImageURLs(h.storage, *img)
+
+	utils.WriteJSON(w, http.StatusOK, img)
+}
+
+// HandlerFunc to delete an image along with its files.
+func (h *Handler) handleDeleteImage(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+	img, ok := h.getImageFromPath(w, r, product.ID)
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteProductImage(product.ID, img.ID)
+	if errors.Is(err, types.ErrImageNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The image is gone once its row is, leftover files only take space.
+	h.deleteFiles(r, img.Key, imageFileNames(img.ContentType))
+
+	if _, err := h.syncPrimaryImage(product); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Util function to read the `image` file & `alt` field of a multipart upload.
+// Writes the error response itself and returns false if it could not be read.
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
+	limit := config.Envs.MaxImageUploadBytes
+	r.Body = http.MaxBytesReader(w, r.Body, limit+uploadOverheadBytes)
+
+	file, header, err := r.FormFile("image")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, types.ErrImageTooLarge)
+		return nil, "", false
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing image file: %v", err))
+		return nil, "", false
+	}
+	defer file.Close()
+
+	if header.Size > limit {
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, types.ErrImageTooLarge)
+		return nil, "", false
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, "", false
+	}
+
+	alt := r.FormValue("alt")
+	if len(alt) > 255 {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("alt must not be longer than 255 characters"))
+		return nil, "", false
+	}
+
+	return data, alt, true
+}
+
+// Random storage key of a new image of product `productID`.
+func newImageKey(productID int) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("products/%d/%s", productID, hex.EncodeToString(b)), nil
+}
+
+// Whether `ids` lists every one of `images` exactly once.
+func isPermutation(images []types.ProductImage, ids []int) bool {
+	if len(ids) != len(images) {
+		return false
+	}
+
+	listed := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = true
+	}
+	for _, img := range images {
+		if !listed[img.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// Delete stored files, logging failures, the caller already has an outcome to report.
+func (h *Handler) deleteFiles(r *http.Request, key string, names []string) {
+	for _, name := range names {
+		if err := h.storage.Delete(r.Context(), key+"/"+name); err != nil {
+			log.Printf("failed to delete %s/%s: %v", key, name, err)
+		}
+	}
+}
+
+// Get the product's images with their URLs, in display order.
+func (h *Handler) getImages(productID int) ([]types.ProductImage, error) {
+	images, err := h.store.GetProductImages(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range images {
+		images[i].URLs = ImageURLs(h.storage, images[i])
+	}
+	return images, nil
+}
+
+// Point the product's `image` at the medium size of its primary image after
+// images were added, reordered or deleted. Left as is once the last image is
+// deleted. Returns the product's images.
+func (h *Handler) syncPrimaryImage(product *types.Product) ([]types.ProductImage, error) {
+	images, err := h.getImages(product.ID)
+	if err != nil || len(images) == 0 {
+		return images, err
+	}
+
+	if url := images[0].URLs.Medium; url != product.Image {
+		if err := h.store.SetPrimaryImage(product.ID, url); err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// Util function to load the image addressed by the `{imageID}` path
+// wildcard, it must belong to product `productID`.
+// Writes the error response itself and returns false if it could not be loaded.
+func (h *Handler) getImageFromPath(w http.ResponseWriter, r *http.Request, productID int) (*types.ProductImage, bool) {
+	id, err := strconv.Atoi(r.PathValue("imageID"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid image id %q", r.PathValue("imageID")))
+		return nil, false
+	}
+
+	img, err := h.store.GetProductImage(productID, id)
+	if errors.Is(err, types.ErrImageNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return img, true
+}
@@ -0,0 +1,266 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestImageHandlers(t *testing.T) {
+	defer func(envs config.Config) { config.Envs = envs }(config.Envs)
+	config.Envs.MaxImageUploadBytes = 1 << 20
+
+	dir := t.TempDir()
+	storage := NewLocalStorage(dir, "http://localhost:8080/media")
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleCustomer},
+		2: {ID: 2, Role: types.RoleAdmin},
+	}}
+	productStore := &mockProductStore{products: map[int]types.Product{1: {ID: 1, Name: "pen"}}}
+	store := &mockImageStore{primary: map[int]string{}}
+	handler := NewHandler(store, productStore, storage, userStore, nil, &mockTransactor{})
+
+	router := http.NewServeMux()
+	handler.RegisterRoutes(router)
+
+	send := func(method, path, contentType string, body []byte, userID int) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		token, err := auth.CreateJWT(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	upload := func(data []byte, userID int) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		// The claimed type is ignored, the data is sniffed.
+		file, err := form.CreateFormFile("image", "photo.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write(data)
+		form.WriteField("alt", "A pen")
+		form.Close()
+
+		return send(http.MethodPost, "/products/1/images", form.FormDataContentType(), body.Bytes(), userID)
+	}
+
+	t.Run("should forbid customers from uploading images", func(t *testing.T) {
+		if rr := upload(testImage(t, 10, 10, false), 1); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should store the image & its variants", func(t *testing.T) {
+		rr := upload(testImage(t, 400, 300, false), 2)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+
+		var img types.ProductImage
+		if err := json.NewDecoder(rr.Body).Decode(&img); err != nil {
+			t.Fatal(err)
+		}
+		if img.Alt != "A pen" || img.ContentType != "image/png" || img.Width != 400 {
+			t.Errorf("unexpected image %+v", img)
+		}
+		if want := "http://localhost:8080/media/" + store.images[0].Key + "/thumb.webp"; img.URLs.ThumbnailWebP != want {
+			t.Errorf("expected thumbnail url %q, got %q", want, img.URLs.ThumbnailWebP)
+		}
+		for _, name := range imageFileNames("image/png") {
+			if _, err := os.Stat(filepath.Join(dir, store.images[0].Key, name)); err != nil {
+				t.Errorf("expected file %s to be stored: %v", name, err)
+			}
+		}
+		if store.primary[1] != img.URLs.Medium {
+			t.Errorf("expected the product image to point at the upload, got %q", store.primary[1])
+		}
+	})
+
+	t.Run("should refuse files that aren't images", func(t *testing.T) {
+		if rr := upload([]byte("%PDF-1.4 not an image"), 2); rr.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("expected status code %d, got %d", http.StatusUnsupportedMediaType, rr.Code)
+		}
+	})
+
+	t.Run("should refuse uploads above the size limit", func(t *testing.T) {
+		if rr := upload(make([]byte, 2<<20), 2); rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected status code %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+		}
+	})
+
+	t.Run("should reorder images & make the first one primary", func(t *testing.T) {
+		if rr := upload(testImage(t, 10, 10, true), 2); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if rr := send(http.MethodPut, "/products/1/images/order", "", []byte(`{"imageIDs":[2]}`), 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for a partial list, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		rr := send(http.MethodPut, "/products/1/images/order", "", []byte(`{"imageIDs":[2,1]}`), 2)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if store.images[0].ID != 2 || store.primary[1] != ImageURLs(storage, store.images[0]).Medium {
+			t.Errorf("expected image 2 to be primary, got %+v & %q", store.images, store.primary[1])
+		}
+	})
+
+	t.Run("should delete the image & its files", func(t *testing.T) {
+		key := store.images[0].Key
+
+		if rr := send(http.MethodDelete, "/products/1/images/2", "", nil, 2); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		if _, err := os.Stat(filepath.Join(dir, key, "original.jpg")); !os.IsNotExist(err) {
+			t.Errorf("expected the files to be deleted, got %v", err)
+		}
+		if len(store.images) != 1 || store.primary[1] != ImageURLs(storage, store.images[0]).Medium {
+			t.Errorf("expected image 1 to be primary again, got %q", store.primary[1])
+		}
+	})
+}
+
+func TestLocalStorage(t *testing.T) {
+	storage := NewLocalStorage(t.TempDir(), "http://localhost:8080/media/")
+
+	t.Run("should refuse keys outside the storage", func(t *testing.T) {
+		for _, key := range []string{"../secret", "/etc/passwd", "a/../../b", ""} {
+			if err := storage.Put(context.Background(), key, "text/plain", bytes.NewReader(nil)); err == nil {
+				t.Errorf("expected key %q to be refused", key)
+			}
+		}
+	})
+
+	t.Run("should serve stored files but not directories", func(t *testing.T) {
+		if err := storage.Put(context.Background(), "a/b.txt", "text/plain", bytes.NewBufferString("hello")); err != nil {
+			t.Fatal(err)
+		}
+		if url := storage.URL("a/b c.txt"); url != "http://localhost:8080/media/a/b%20c.txt" {
+			t.Errorf("unexpected url %q", url)
+		}
+
+		for path, want := range map[string]int{"/b.txt": http.StatusNotFound, "/a/b.txt": http.StatusOK, "/a/": http.StatusNotFound} {
+			rr := httptest.NewRecorder()
+			storage.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+			if rr.Code != want {
+				t.Errorf("expected status code %d for %s, got %d", want, path, rr.Code)
+			}
+		}
+	})
+}
+
+type mockUserStore struct {
+	users map[int]*types.User
+}
+
+func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
+	return nil, nil
+}
+
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	return m.users[id], nil
+}
+
+func (m *mockUserStore) CreateUser(user types.User) error {
+	return nil
+}
+
+type mockProductStore struct {
+	types.ProductStore
+	products map[int]types.Product
+}
+
+func (m *mockProductStore) GetProductByID(id int) (*types.Product, error) {
+	p, ok := m.products[id]
+	if !ok {
+		return nil, types.ErrProductNotFound
+	}
+	return &p, nil
+}
+
+// Keeps the images in display order.
+type mockImageStore struct {
+	images  []types.ProductImage
+	nextID  int
+	primary map[int]string
+}
+
+func (m *mockImageStore) GetProductImages(productID int) ([]types.ProductImage, error) {
+	return append([]types.ProductImage{}, m.images...), nil
+}
+
+func (m *mockImageStore) GetProductImage(productID, id int) (*types.ProductImage, error) {
+	for _, img := range m.images {
+		if img.ID == id && img.ProductID == productID {
+			return &img, nil
+		}
+	}
+	return nil, types.ErrImageNotFound
+}
+
+func (m *mockImageStore) CreateProductImage(img types.ProductImage) (int, error) {
+	m.nextID++
+	img.ID = m.nextID
+	m.images = append(m.images, img)
+	return img.ID, nil
+}
+
+func (m *mockImageStore) UpdateProductImage(img types.ProductImage) error {
+	return nil
+}
+
+func (m *mockImageStore) DeleteProductImage(productID, id int) error {
+	for i, img := range m.images {
+		if img.ID == id {
+			m.images = append(m.images[:i], m.images[i+1:]...)
+			return nil
+		}
+	}
+	return types.ErrImageNotFound
+}
+
+func (m *mockImageStore) ReorderProductImagesTx(tx *sql.Tx, productID int, ids []int) error {
+	reordered := make([]types.ProductImage, 0, len(ids))
+	for _, id := range ids {
+		img, _ := m.GetProductImage(productID, id)
+		reordered = append(reordered, *img)
+	}
+	m.images = reordered
+	return nil
+}
+
+func (m *mockImageStore) SetPrimaryImage(productID int, url string) error {
+	m.primary[productID] = url
+	return nil
+}
+
+type mockTransactor struct{}
+
+func (m *mockTransactor) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return fn(nil)
+}
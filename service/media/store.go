@@ -0,0 +1,112 @@
+package media
+
+import (
+	"database/sql"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const imageColumns = "id, productId, position, alt, contentType, width, height, `key`, createdAt"
+
+// Get the images of a product, in display order.
+func (s *Store) GetProductImages(productID int) ([]types.ProductImage, error) {
+	rows, err := s.db.Query("SELECT "+imageColumns+" FROM product_images WHERE productId = ? ORDER BY position, id", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := make([]types.ProductImage, 0)
+	for rows.Next() {
+		img, err := scanRowIntoImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, *img)
+	}
+
+	return images, rows.Err()
+}
+
+// Get an image of a product.
+// Returns `types.ErrImageNotFound` if the product has no such image.
+func (s *Store) GetProductImage(productID, id int) (*types.ProductImage, error) {
+	img, err := scanRowIntoImage(s.db.QueryRow("SELECT "+imageColumns+" FROM product_images WHERE id = ? AND productId = ?", id, productID))
+	if err == sql.ErrNoRows {
+		return nil, types.ErrImageNotFound
+	}
+	return img, err
+}
+
+// Add an image after the product's other images and return its ID.
+func (s *Store) CreateProductImage(img types.ProductImage) (int, error) {
+	res, err := s.db.Exec(`INSERT INTO product_images (productId, position, alt, contentType, width, height, `+"`key`"+`)
+		SELECT ?, COALESCE(MAX(position) + 1, 0), ?, ?, ?, ?, ? FROM product_images WHERE productId = ?`,
+		img.ProductID, img.Alt, img.ContentType, img.Width, img.Height, img.Key, img.ProductID)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// Update the alt text of an image.
+func (s *Store) UpdateProductImage(img types.ProductImage) error {
+	_, err := s.db.Exec("UPDATE product_images SET alt = ? WHERE id = ? AND productId = ?", img.Alt, img.ID, img.ProductID)
+	return err
+}
+
+// Delete an image of a product, its files are left to the caller.
+// Returns `types.ErrImageNotFound` if the product has no such image.
+func (s *Store) DeleteProductImage(productID, id int) error {
+	res, err := s.db.Exec("DELETE FROM product_images WHERE id = ? AND productId = ?", id, productID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return types.ErrImageNotFound
+	}
+	return nil
+}
+
+// Set the positions of the product's images to their index in `ids`, as part of `tx`.
+func (s *Store) ReorderProductImagesTx(tx *sql.Tx, productID int, ids []int) error {
+	for position, id := range ids {
+		if _, err := tx.Exec("UPDATE product_images SET position = ? WHERE id = ? AND productId = ?", position, id, productID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Point `products.image` at the product's primary image.
+func (s *Store) SetPrimaryImage(productID int, url string) error {
+	_, err := s.db.Exec("UPDATE products SET image = ? WHERE id = ?", url, productID)
+	return err
+}
+
+// Either `*sql.Row` or `*sql.Rows`.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRowIntoImage(row scanner) (*types.ProductImage, error) {
+	img := new(types.ProductImage)
+	err := row.Scan(&img.ID, &img.ProductID, &img.Position, &img.Alt, &img.ContentType, &img.Width, &img.Height, &img.Key, &img.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
@@ -0,0 +1,232 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+// Page size limits for `GET /orders`.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+type Handler struct {
+	store            types.OrderStore
+	userStore        types.UserStore
+	productStore     types.ProductStore
+	variantStore     types.VariantStore
+	reservationStore types.ReservationStore
+	ledgerStore      types.StockLedgerStore
+	warehouseStore   types.WarehouseStore
+	idempotencyStore types.IdempotencyStore
+	transactor       types.Transactor
+}
+
+func NewHandler(store types.OrderStore, userStore types.UserStore, productStore types.ProductStore, variantStore types.VariantStore, reservationStore types.ReservationStore, ledgerStore types.StockLedgerStore, warehouseStore types.WarehouseStore, idempotencyStore types.IdempotencyStore, transactor types.Transactor) *Handler {
+	return &Handler{store: store, userStore: userStore, productStore: productStore, variantStore: variantStore, reservationStore: reservationStore, ledgerStore: ledgerStore, warehouseStore: warehouseStore, idempotencyStore: idempotencyStore, transactor: transactor}
+}
+
+func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /orders", auth.WithJWTAuth(h.handleGetOrders, h.userStore))
+	router.HandleFunc("GET /orders/{id}", auth.WithJWTAuth(h.handleGetOrder, h.userStore))
+	router.HandleFunc("POST /orders/{id}/cancel", auth.WithJWTAuth(idempotency.WithIdempotencyKey(h.handleCancelOrder, h.idempotencyStore), h.userStore))
+
+	// Moving orders through their lifecycle, `admin` users only.
+	router.HandleFunc("PATCH /orders/{id}/status", auth.WithRole(idempotency.WithIdempotencyKey(h.handleUpdateOrderStatus, h.idempotencyStore), h.userStore, types.RoleAdmin))
+}
+
+// HandlerFunc to get the authenticated user's orders (list), newest first.
+func (h *Handler) handleGetOrders(w http.ResponseWriter, r *http.Request) {
+	// General Flow:
+	// 1. Parse pagination query params (`limit`, `cursor`).
+	// 2. Get a page of the user's orders from DB.
+	// 3. Write a JSON response.
+
+	userID := auth.GetUseIDFromContext(r.Context())
+
+	limit := DefaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+		limit = min(l, MaxPageSize)
+	}
+
+	// The cursor is the ID of the last order of the previous page.
+	beforeID := 0
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, types.ErrInvalidCursor)
+			return
+		}
+		beforeID = id
+	}
+
+	// Fetching one extra order to know if there is a next page.
+	orders, err := h.store.GetOrdersByUserID(userID, limit+1, beforeID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	page := types.OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		page.NextCursor = strconv.Itoa(page.Orders[limit-1].ID)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// HandlerFunc to get a single order of the authenticated user with its items.
+func (h *Handler) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	order, ok := h.getOwnOrderFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := h.store.GetOrderItemsByOrderID(order.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	discounts, err := h.store.GetOrderDiscounts(order.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	history, err := h.store.GetOrderStatusHistory(order.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, types.OrderDetail{Order: *order, Items: items, Discounts: discounts, History: history})
+}
+
+// HandlerFunc for the authenticated user to cancel one of their orders.
+// Only orders that haven't shipped yet can be cancelled, their items go back in stock.
+func (h *Handler) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUseIDFromContext(r.Context())
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id %q", r.PathValue("id")))
+		return
+	}
+
+	// Body is optional, it only carries a reason.
+	var payload types.CancelOrderPayload
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	order, err := h.changeStatus(r.Context(), id, types.OrderStatusCancelled, userID, payload.Reason, func(o *types.Order) error {
+		// Orders of other users are reported as not found.
+		if o.UserID != userID {
+			return types.ErrOrderNotFound
+		}
+		if !CanTransition(o.Status, types.OrderStatusCancelled) {
+			return fmt.Errorf("%w: order is %s", types.ErrOrderNotCancellable, o.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		writeStatusChangeError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, order)
+}
+
+// HandlerFunc to move an order to another status (admin).
+func (h *Handler) handleUpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id %q", r.PathValue("id")))
+		return
+	}
+
+	var payload types.UpdateOrderStatusPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	if !IsValidStatus(payload.Status) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown order status %q", payload.Status))
+		return
+	}
+
+	order, err := h.changeStatus(r.Context(), id, payload.Status, auth.GetUseIDFromContext(r.Context()), payload.Reason, nil)
+	if err != nil {
+		writeStatusChangeError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, order)
+}
+
+// Util function mapping `changeStatus()` errors to responses.
+func writeStatusChangeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrOrderNotFound):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case errors.Is(err, types.ErrIllegalTransition), errors.Is(err, types.ErrOrderNotCancellable), errors.Is(err, types.ErrOutOfStock):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// Util function to load the order addressed by the `{id}` path wildcard.
+// Orders of other users are reported as not found, not forbidden, so order IDs
+// can't be probed. Writes the error response itself and returns false on failure.
+func (h *Handler) getOwnOrderFromPath(w http.ResponseWriter, r *http.Request) (*types.Order, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id %q", r.PathValue("id")))
+		return nil, false
+	}
+
+	order, err := h.store.GetOrderByID(id)
+	if errors.Is(err, types.ErrOrderNotFound) || (err == nil && order.UserID != auth.GetUseIDFromContext(r.Context())) {
+		utils.WriteError(w, http.StatusNotFound, types.ErrOrderNotFound)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return order, true
+}
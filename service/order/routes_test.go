@@ -0,0 +1,420 @@
+package order
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestOrderHandlers(t *testing.T) {
+	orderStore := &mockOrderStore{
+		orders: map[int]types.Order{
+			1: {ID: 1, UserID: 1, Status: "pending"},
+			2: {ID: 2, UserID: 2, Status: "pending"},
+			3: {ID: 3, UserID: 1, Status: "pending"},
+			4: {ID: 4, UserID: 1, Status: "pending"},
+		},
+		items: map[int][]types.OrderItem{
+			1: {{ID: 1, OrderID: 1, ProductID: 1, Quantity: 2, Price: types.NewMoney(1000), ProductName: "pen"}},
+		},
+	}
+	handler := NewHandler(orderStore, &mockUserStore{}, nil, nil, nil, nil, nil, nil, &mockTransactor{})
+
+	router := http.NewServeMux()
+	handler.RegisterRoutes(router)
+
+	get := func(path string, userID int) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := auth.CreateJWT(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should return the order with its items to its owner", func(t *testing.T) {
+		rr := get("/orders/1", 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var detail types.OrderDetail
+		json.NewDecoder(rr.Body).Decode(&detail)
+		if detail.ID != 1 || len(detail.Items) != 1 || detail.Items[0].ProductName != "pen" {
+			t.Errorf("unexpected order detail: %+v", detail)
+		}
+	})
+
+	t.Run("should hide orders of other users", func(t *testing.T) {
+		if rr := get("/orders/2", 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should paginate the user's orders newest first", func(t *testing.T) {
+		var page types.OrderPage
+		json.NewDecoder(get("/orders?limit=2", 1).Body).Decode(&page)
+
+		if len(page.Orders) != 2 || page.Orders[0].ID != 4 || page.Orders[1].ID != 3 || page.NextCursor != "3" {
+			t.Fatalf("unexpected first page: %+v", page)
+		}
+
+		page = types.OrderPage{}
+		json.NewDecoder(get("/orders?limit=2&cursor=3", 1).Body).Decode(&page)
+
+		if len(page.Orders) != 1 || page.Orders[0].ID != 1 || page.NextCursor != "" {
+			t.Errorf("unexpected last page: %+v", page)
+		}
+	})
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	orderStore := &mockOrderStore{
+		orders: map[int]types.Order{
+			1: {ID: 1, UserID: 1, Status: types.OrderStatusPaid, StockCommitted: true},
+			2: {ID: 2, UserID: 1, Status: types.OrderStatusShipped, StockCommitted: true},
+			3: {ID: 3, UserID: 2, Status: types.OrderStatusPending},
+			4: {ID: 4, UserID: 2, Status: types.OrderStatusPending},
+			5: {ID: 5, UserID: 2, Status: types.OrderStatusPending},
+			6: {ID: 6, UserID: 2, Status: types.OrderStatusPending},
+		},
+		items: map[int][]types.OrderItem{
+			1: {{ProductID: 1, Quantity: 2}, {ProductID: 1, Quantity: 1}},
+			4: {{ProductID: 2, Quantity: 2}},
+			5: {{ProductID: 3, Quantity: 2}},
+			6: {{ProductID: 2, Quantity: 1}},
+		},
+	}
+	productStore := &mockProductStore{products: map[int]types.Product{
+		1: {ID: 1, Quantity: 5},
+		2: {ID: 2, Quantity: 3},
+		3: {ID: 3, Name: "ink", Quantity: 2},
+	}}
+	// Another cart holds one of product 3.
+	reservationStore := &mockReservationStore{reserved: map[int]int{3: 1}}
+	ledgerStore := &mockLedgerStore{products: productStore}
+	handler := NewHandler(orderStore, &mockUserStore{admins: map[int]bool{9: true}}, productStore, &mockVariantStore{}, reservationStore, ledgerStore, &mockWarehouseStore{products: productStore}, nil, &mockTransactor{})
+
+	router := http.NewServeMux()
+	handler.RegisterRoutes(router)
+
+	send := func(method, path, body string, userID int) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := auth.CreateJWT(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should cancel an unshipped order and restock its items", func(t *testing.T) {
+		rr := send(http.MethodPost, "/orders/1/cancel", `{"reason":"changed my mind"}`, 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if status := orderStore.orders[1].Status; status != types.OrderStatusCancelled {
+			t.Errorf("expected order to be cancelled, got %s", status)
+		}
+		if quantity := productStore.products[1].Quantity; quantity != 8 {
+			t.Errorf("expected quantity 8 after restock, got %d", quantity)
+		}
+		if m := ledgerStore.movements; len(m) != 1 || m[0].Type != types.StockMovementRestock || m[0].OrderID != 1 || m[0].ActorID != 1 {
+			t.Errorf("expected a restock movement of order 1 by user 1, got %+v", m)
+		}
+		if len(orderStore.history) != 1 || orderStore.history[0].Reason != "changed my mind" {
+			t.Errorf("unexpected status history: %+v", orderStore.history)
+		}
+	})
+
+	t.Run("should not cancel shipped orders", func(t *testing.T) {
+		if rr := send(http.MethodPost, "/orders/2/cancel", "", 1); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("should not cancel orders of other users", func(t *testing.T) {
+		if rr := send(http.MethodPost, "/orders/3/cancel", "", 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should let admins move orders forward", func(t *testing.T) {
+		if rr := send(http.MethodPatch, "/orders/2/status", `{"status":"delivered"}`, 9); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should reject illegal transitions", func(t *testing.T) {
+		if rr := send(http.MethodPatch, "/orders/3/status", `{"status":"shipped"}`, 9); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if status := orderStore.orders[3].Status; status != types.OrderStatusPending {
+			t.Errorf("expected status to stay pending, got %s", status)
+		}
+	})
+
+	t.Run("should take reserved stock off once an order is paid", func(t *testing.T) {
+		if rr := send(http.MethodPatch, "/orders/4/status", `{"status":"paid"}`, 9); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if quantity := productStore.products[2].Quantity; quantity != 1 {
+			t.Errorf("expected quantity 1 after payment, got %d", quantity)
+		}
+		if !orderStore.orders[4].StockCommitted || !slices.Contains(reservationStore.released, 4) {
+			t.Error("expected the reservation to become a stock decrement")
+		}
+		if m := ledgerStore.movements[len(ledgerStore.movements)-1]; m.Type != types.StockMovementSale || m.Delta != -2 || m.ActorID != 9 || m.WarehouseID != types.DefaultWarehouseID {
+			t.Errorf("expected a sale movement of 2 at the default warehouse by admin 9, got %+v", m)
+		}
+	})
+
+	t.Run("should not pay orders whose stock was sold meanwhile", func(t *testing.T) {
+		if rr := send(http.MethodPatch, "/orders/5/status", `{"status":"paid"}`, 9); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if status := orderStore.orders[5].Status; status != types.OrderStatusPending {
+			t.Errorf("expected status to stay pending, got %s", status)
+		}
+	})
+
+	t.Run("should release the reservations of a cancelled unpaid order", func(t *testing.T) {
+		if rr := send(http.MethodPost, "/orders/6/cancel", "", 2); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if !slices.Contains(reservationStore.released, 6) {
+			t.Error("expected the order's reservations to be released")
+		}
+		if quantity := productStore.products[2].Quantity; quantity != 1 {
+			t.Errorf("expected stock to be left alone, got %d", quantity)
+		}
+	})
+
+	t.Run("should forbid customers from changing status", func(t *testing.T) {
+		if rr := send(http.MethodPatch, "/orders/3/status", `{"status":"paid"}`, 2); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+}
+
+func TestCanTransition(t *testing.T) {
+	allowed := [][2]string{
+		{types.OrderStatusPending, types.OrderStatusPaid},
+		{types.OrderStatusPaid, types.OrderStatusFulfilled},
+		{types.OrderStatusFulfilled, types.OrderStatusShipped},
+		{types.OrderStatusShipped, types.OrderStatusDelivered},
+		{types.OrderStatusPending, types.OrderStatusCancelled},
+		{types.OrderStatusDelivered, types.OrderStatusRefunded},
+	}
+	for _, tr := range allowed {
+		if !CanTransition(tr[0], tr[1]) {
+			t.Errorf("expected %s -> %s to be allowed", tr[0], tr[1])
+		}
+	}
+
+	rejected := [][2]string{
+		{types.OrderStatusPending, types.OrderStatusShipped},
+		{types.OrderStatusShipped, types.OrderStatusCancelled},
+		{types.OrderStatusCancelled, types.OrderStatusPending},
+		{types.OrderStatusRefunded, types.OrderStatusPaid},
+		{types.OrderStatusPending, types.OrderStatusRefunded},
+	}
+	for _, tr := range rejected {
+		if CanTransition(tr[0], tr[1]) {
+			t.Errorf("expected %s -> %s to be rejected", tr[0], tr[1])
+		}
+	}
+}
+
+// Runs `fn` directly, stores below ignore the (nil) tx.
+type mockTransactor struct{}
+
+func (m *mockTransactor) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return fn(nil)
+}
+
+type mockUserStore struct {
+	admins map[int]bool
+}
+
+func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
+	return nil, nil
+}
+
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	if m.admins[id] {
+		return &types.User{ID: id, Role: types.RoleAdmin}, nil
+	}
+	return &types.User{ID: id, Role: types.RoleCustomer}, nil
+}
+
+func (m *mockUserStore) CreateUser(user types.User) error {
+	return nil
+}
+
+type mockOrderStore struct {
+	types.OrderStore
+	orders  map[int]types.Order
+	items   map[int][]types.OrderItem
+	history []types.OrderStatusChange
+}
+
+func (m *mockOrderStore) GetOrdersByUserID(userID, limit, beforeID int) ([]types.Order, error) {
+	orders := []types.Order{}
+	for _, o := range m.orders {
+		if o.UserID == userID && (beforeID == 0 || o.ID < beforeID) {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID > orders[j].ID })
+
+	return orders[:min(limit, len(orders))], nil
+}
+
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, types.ErrOrderNotFound
+	}
+	return &o, nil
+}
+
+func (m *mockOrderStore) GetOrderItemsByOrderID(orderID int) ([]types.OrderItem, error) {
+	return m.items[orderID], nil
+}
+
+func (m *mockOrderStore) GetOrderDiscounts(orderID int) ([]types.OrderDiscount, error) {
+	return []types.OrderDiscount{}, nil
+}
+
+func (m *mockOrderStore) GetOrderStatusHistory(orderID int) ([]types.OrderStatusChange, error) {
+	return m.history, nil
+}
+
+func (m *mockOrderStore) GetOrderByIDForUpdate(tx *sql.Tx, id int) (*types.Order, error) {
+	return m.GetOrderByID(id)
+}
+
+func (m *mockOrderStore) GetOrderItemsByOrderIDTx(tx *sql.Tx, orderID int) ([]types.OrderItem, error) {
+	return m.items[orderID], nil
+}
+
+func (m *mockOrderStore) UpdateOrderStatusTx(tx *sql.Tx, id int, status string) error {
+	o := m.orders[id]
+	o.Status = status
+	m.orders[id] = o
+	return nil
+}
+
+func (m *mockOrderStore) SetOrderStockCommittedTx(tx *sql.Tx, id int) error {
+	o := m.orders[id]
+	o.StockCommitted = true
+	m.orders[id] = o
+	return nil
+}
+
+func (m *mockOrderStore) CreateOrderStatusChangeTx(tx *sql.Tx, change types.OrderStatusChange) error {
+	m.history = append(m.history, change)
+	return nil
+}
+
+type mockProductStore struct {
+	types.ProductStore
+	products map[int]types.Product
+}
+
+func (m *mockProductStore) GetProductByIDsForUpdate(tx *sql.Tx, ids []int) ([]types.Product, error) {
+	products := []types.Product{}
+	for _, id := range ids {
+		if p, ok := m.products[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+type mockVariantStore struct {
+	types.VariantStore
+}
+
+func (m *mockVariantStore) GetVariantsByProductIDsTx(tx *sql.Tx, productIDs []int) ([]types.Variant, error) {
+	return []types.Variant{}, nil
+}
+
+// All products are held at the default warehouse.
+type mockWarehouseStore struct {
+	types.WarehouseStore
+	products *mockProductStore
+}
+
+func (m *mockWarehouseStore) GetProductStockForUpdate(tx *sql.Tx, productIDs []int, orderID int) ([]types.WarehouseStock, error) {
+	stock := []types.WarehouseStock{}
+	for _, id := range productIDs {
+		p := m.products.products[id]
+		stock = append(stock, types.WarehouseStock{WarehouseID: types.DefaultWarehouseID, ProductID: id, Quantity: p.Quantity, Available: p.Quantity})
+	}
+	return stock, nil
+}
+
+// Applies movements to the mocked products' quantities.
+type mockLedgerStore struct {
+	types.StockLedgerStore
+	products  *mockProductStore
+	movements []types.StockMovement
+}
+
+func (m *mockLedgerStore) RecordStockMovementTx(tx *sql.Tx, movement types.StockMovement) (*types.StockMovement, error) {
+	p := m.products.products[movement.ProductID]
+	p.Quantity += movement.Delta
+	m.products.products[p.ID] = p
+
+	movement.Balance = p.Quantity
+	m.movements = append(m.movements, movement)
+	return &movement, nil
+}
+
+type mockReservationStore struct {
+	types.ReservationStore
+	reserved map[int]int
+	released []int
+}
+
+func (m *mockReservationStore) GetReservedQuantitiesTx(tx *sql.Tx, productIDs []int, cartID, orderID int) (map[int]int, error) {
+	return m.reserved, nil
+}
+
+func (m *mockReservationStore) GetReservedVariantQuantitiesTx(tx *sql.Tx, variantIDs []int, cartID, orderID int) (map[int]int, error) {
+	return map[int]int{}, nil
+}
+
+func (m *mockReservationStore) DeleteOrderReservationsTx(tx *sql.Tx, orderID int) error {
+	m.released = append(m.released, orderID)
+	return nil
+}
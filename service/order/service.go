@@ -0,0 +1,285 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Order lifecycle: the statuses an order may move to from each status.
+//
+//	pending -> paid -> fulfilled -> shipped -> delivered
+//
+// Orders can be cancelled until they are shipped and refunded once paid.
+// `cancelled` & `refunded` are final.
+var transitions = map[string][]string{
+	types.OrderStatusPending:   {types.OrderStatusPaid, types.OrderStatusCancelled},
+	types.OrderStatusPaid:      {types.OrderStatusFulfilled, types.OrderStatusCancelled, types.OrderStatusRefunded},
+	types.OrderStatusFulfilled: {types.OrderStatusShipped, types.OrderStatusCancelled, types.OrderStatusRefunded},
+	types.OrderStatusShipped:   {types.OrderStatusDelivered, types.OrderStatusRefunded},
+	types.OrderStatusDelivered: {types.OrderStatusRefunded},
+	types.OrderStatusCancelled: {},
+	types.OrderStatusRefunded:  {},
+}
+
+// Report whether an order may move from status `from` to status `to`.
+func CanTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Report whether `status` is a known order status.
+func IsValidStatus(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// Move an order to status `to`, recording the change in its history.
+// Runs in a single transaction with the order row locked, illegal transitions
+// return `types.ErrIllegalTransition`. As part of the same transaction:
+//   - paying takes the reserved quantities off stock (see `commitStock()`).
+//   - cancelling releases the reservations of an unpaid order, or puts the
+//     ordered quantities back in stock once they were taken.
+//
+// `check` (optional) runs on the locked order before anything changes,
+// e.g. to verify ownership.
+func (h *Handler) changeStatus(ctx context.Context, orderID int, to string, actorID int, reason string, check func(*types.Order) error) (*types.Order, error) {
+	var order *types.Order
+
+	err := h.transactor.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		order, err = h.store.GetOrderByIDForUpdate(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		if check != nil {
+			if err := check(order); err != nil {
+				return err
+			}
+		}
+
+		if !CanTransition(order.Status, to) {
+			return fmt.Errorf("%w: %s -> %s", types.ErrIllegalTransition, order.Status, to)
+		}
+
+		switch {
+		case to == types.OrderStatusPaid && !order.StockCommitted:
+			if err := h.commitStock(tx, order.ID, actorID); err != nil {
+				return err
+			}
+			order.StockCommitted = true
+		case to == types.OrderStatusCancelled && order.StockCommitted:
+			if err := h.restock(tx, order.ID, actorID); err != nil {
+				return err
+			}
+		case to == types.OrderStatusCancelled:
+			if err := h.reservationStore.DeleteOrderReservationsTx(tx, order.ID); err != nil {
+				return err
+			}
+		}
+
+		if err := h.store.UpdateOrderStatusTx(tx, order.ID, to); err != nil {
+			return err
+		}
+
+		err = h.store.CreateOrderStatusChangeTx(tx, types.OrderStatusChange{
+			OrderID:    order.ID,
+			FromStatus: order.Status,
+			ToStatus:   to,
+			ChangedBy:  actorID,
+			Reason:     reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		order.Status = to
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// Take the quantities of an order's items off stock at their warehouses
+// (`sale` movements) & release its reservations, inside `tx`. If the
+// reservations expired before the order was paid the stock may have been
+// sold meanwhile, `types.ErrOutOfStock` is returned then.
+func (h *Handler) commitStock(tx *sql.Tx, orderID, actorID int) error {
+	productIDs, allocations, products, err := h.lockOrderProducts(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	// The order's own reservations don't hold stock against it.
+	reserved, err := h.reservationStore.GetReservedQuantitiesTx(tx, productIDs, 0, orderID)
+	if err != nil {
+		return err
+	}
+
+	quantities := make(map[int]int)
+	variantQuantities := make(map[int]int)
+	for _, a := range allocations {
+		quantities[a.ProductID] += a.Quantity
+		if a.VariantID > 0 {
+			variantQuantities[a.VariantID] += a.Quantity
+		}
+	}
+	for _, product := range products {
+		if product.Quantity-reserved[product.ID] < quantities[product.ID] {
+			return fmt.Errorf("%w: product %s", types.ErrOutOfStock, product.Name)
+		}
+	}
+
+	// Same for the ordered variants.
+	variants, err := h.variantStore.GetVariantsByProductIDsTx(tx, productIDs)
+	if err != nil {
+		return err
+	}
+	variantIDs := make([]int, 0, len(variantQuantities))
+	for id := range variantQuantities {
+		variantIDs = append(variantIDs, id)
+	}
+	reservedVariants, err := h.reservationStore.GetReservedVariantQuantitiesTx(tx, variantIDs, 0, orderID)
+	if err != nil {
+		return err
+	}
+	for _, v := range variants {
+		if v.Quantity-reservedVariants[v.ID] < variantQuantities[v.ID] {
+			return fmt.Errorf("%w: variant %s", types.ErrOutOfStock, v.SKU)
+		}
+	}
+
+	// Same at each warehouse, the stock there may be reserved for other orders.
+	// Warehouses hold stock per product, the variants of a product share it.
+	stock, err := h.warehouseStore.GetProductStockForUpdate(tx, productIDs, orderID)
+	if err != nil {
+		return err
+	}
+	available := make(map[stockKey]int, len(stock))
+	for _, s := range stock {
+		available[stockKey{s.ProductID, 0, s.WarehouseID}] = s.Available
+	}
+
+	for _, a := range allocations {
+		k := stockKey{a.ProductID, 0, a.WarehouseID}
+		if available[k] < a.Quantity {
+			return fmt.Errorf("%w: product %d at warehouse %d", types.ErrOutOfStock, a.ProductID, a.WarehouseID)
+		}
+		available[k] -= a.Quantity
+
+		_, err := h.ledgerStore.RecordStockMovementTx(tx, types.StockMovement{
+			ProductID:   a.ProductID,
+			VariantID:   a.VariantID,
+			WarehouseID: a.WarehouseID,
+			Type:        types.StockMovementSale,
+			Delta:       -a.Quantity,
+			OrderID:     orderID,
+			ActorID:     actorID,
+			Reason:      "order paid",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := h.reservationStore.DeleteOrderReservationsTx(tx, orderID); err != nil {
+		return err
+	}
+
+	return h.store.SetOrderStockCommittedTx(tx, orderID)
+}
+
+// Put the quantities of an order's items back in stock at their warehouses
+// (`restock` movements), inside `tx`.
+func (h *Handler) restock(tx *sql.Tx, orderID, actorID int) error {
+	_, allocations, _, err := h.lockOrderProducts(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range allocations {
+		_, err := h.ledgerStore.RecordStockMovementTx(tx, types.StockMovement{
+			ProductID:   a.ProductID,
+			VariantID:   a.VariantID,
+			WarehouseID: a.WarehouseID,
+			Type:        types.StockMovementRestock,
+			Delta:       a.Quantity,
+			OrderID:     orderID,
+			ActorID:     actorID,
+			Reason:      "order cancelled",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A product's (variant's, 0 for none) stock at a warehouse.
+type stockKey struct{ productID, variantID, warehouseID int }
+
+// Lock the products of an order's items, returning their IDs (sorted), the
+// ordered quantity of each product (variant) by warehouse & the products.
+// Products that are gone are left out.
+func (h *Handler) lockOrderProducts(tx *sql.Tx, orderID int) ([]int, []types.StockAllocation, []types.Product, error) {
+	items, err := h.store.GetOrderItemsByOrderIDTx(tx, orderID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Total quantity per product (variant) & warehouse, items of orders from
+	// before warehouses existed ship from the default one.
+	quantities := make(map[stockKey]int)
+	for _, item := range items {
+		k := stockKey{item.ProductID, item.VariantID, item.WarehouseID}
+		if k.warehouseID == 0 {
+			k.warehouseID = types.DefaultWarehouseID
+		}
+		quantities[k] += item.Quantity
+	}
+
+	productIDs := make([]int, 0, len(quantities))
+	allocations := make([]types.StockAllocation, 0, len(quantities))
+	for k, quantity := range quantities {
+		if !slices.Contains(productIDs, k.productID) {
+			productIDs = append(productIDs, k.productID)
+		}
+		allocations = append(allocations, types.StockAllocation{ProductID: k.productID, VariantID: k.variantID, WarehouseID: k.warehouseID, Quantity: quantity})
+	}
+	sort.Ints(productIDs)
+	sort.Slice(allocations, func(i, j int) bool {
+		if allocations[i].ProductID != allocations[j].ProductID {
+			return allocations[i].ProductID < allocations[j].ProductID
+		}
+		if allocations[i].VariantID != allocations[j].VariantID {
+			return allocations[i].VariantID < allocations[j].VariantID
+		}
+		return allocations[i].WarehouseID < allocations[j].WarehouseID
+	})
+
+	// Same lock order as checkout (by id), so the two can't deadlock.
+	products, err := h.productStore.GetProductByIDsForUpdate(tx, productIDs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Products that are gone have no stock to move.
+	allocations = slices.DeleteFunc(allocations, func(a types.StockAllocation) bool {
+		return !slices.ContainsFunc(products, func(p types.Product) bool { return p.ID == a.ProductID })
+	})
+
+	return productIDs, allocations, products, nil
+}
@@ -3,6 +3,7 @@ package order
 import (
 	"database/sql"
 
+	"github.com/gitKashish/ecommerce-api-go/db"
 	"github.com/gitKashish/ecommerce-api-go/types"
 )
 
@@ -17,7 +18,17 @@ func NewStore(db *sql.DB) *Store {
 // create a new order record in `orders` table in DB...
 // and return the order ID.
 func (s *Store) CreateOrder(order types.Order) (int, error) {
-	res, err := s.db.Exec("INSERT INTO orders (userId, total, status, address) VALUES (?, ?, ?, ?)", order.UserID, order.Total, order.Status, order.Address)
+	return createOrder(s.db, order)
+}
+
+// Same as `CreateOrder` but as part of transaction `tx`.
+func (s *Store) CreateOrderTx(tx *sql.Tx, order types.Order) (int, error) {
+	return createOrder(tx, order)
+}
+
+func createOrder(q db.Querier, order types.Order) (int, error) {
+	res, err := q.Exec("INSERT INTO orders (userId, subtotal, discount, shipping, tax, total, status, stockCommitted, address, billingAddress) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		order.UserID, order.Subtotal, order.Discount, order.Shipping, order.Tax, order.Total, order.Status, order.StockCommitted, order.Address, order.BillingAddress)
 	if err != nil {
 		return 0, err
 	}
@@ -33,6 +44,238 @@ func (s *Store) CreateOrder(order types.Order) (int, error) {
 
 // Create a new order item record in `order_items` table in DB.
 func (s *Store) CreateOrderItem(orderItem types.OrderItem) error {
-	_, err := s.db.Exec("INSERT INTO order_items (orderId, productId, quantity, price) VALUES (?, ?, ?, ?)", orderItem.OrderID, orderItem.ProductID, orderItem.Quantity, orderItem.Price)
+	return createOrderItem(s.db, orderItem)
+}
+
+// Same as `CreateOrderItem` but as part of transaction `tx`.
+func (s *Store) CreateOrderItemTx(tx *sql.Tx, orderItem types.OrderItem) error {
+	return createOrderItem(tx, orderItem)
+}
+
+func createOrderItem(q db.Querier, orderItem types.OrderItem) error {
+	var variantID, warehouseID sql.NullInt64
+	if orderItem.VariantID > 0 {
+		variantID = sql.NullInt64{Int64: int64(orderItem.VariantID), Valid: true}
+	}
+	if orderItem.WarehouseID > 0 {
+		warehouseID = sql.NullInt64{Int64: int64(orderItem.WarehouseID), Valid: true}
+	}
+
+	_, err := q.Exec("INSERT INTO order_items (orderId, productId, variantId, sku, warehouseId, quantity, price, productName, productImage) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		orderItem.OrderID, orderItem.ProductID, variantID, orderItem.SKU, warehouseID, orderItem.Quantity, orderItem.Price, orderItem.ProductName, orderItem.ProductImage)
 	return err
 }
+
+// Record a coupon applied to an order, as part of transaction `tx`.
+func (s *Store) CreateOrderDiscountTx(tx *sql.Tx, d types.OrderDiscount) error {
+	_, err := tx.Exec("INSERT INTO order_discounts (orderId, couponId, code, amount) VALUES (?, ?, ?, ?)",
+		d.OrderID, d.CouponID, d.Code, d.Amount)
+	return err
+}
+
+// Get the coupons applied to an order, in the order they were applied.
+func (s *Store) GetOrderDiscounts(orderID int) ([]types.OrderDiscount, error) {
+	rows, err := s.db.Query("SELECT id, orderId, couponId, code, amount, createdAt FROM order_discounts WHERE orderId = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	discounts := make([]types.OrderDiscount, 0)
+	for rows.Next() {
+		d := types.OrderDiscount{}
+		if err := rows.Scan(&d.ID, &d.OrderID, &d.CouponID, &d.Code, &d.Amount, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, d)
+	}
+
+	return discounts, rows.Err()
+}
+
+// Get a page of the user's orders, newest first.
+// Keyset paginated on `id`: pass the last ID of the previous page as `beforeID`.
+func (s *Store) GetOrdersByUserID(userID, limit, beforeID int) ([]types.Order, error) {
+	query := "SELECT id, userId, subtotal, discount, shipping, tax, total, status, stockCommitted, address, billingAddress, createdAt FROM orders WHERE userId = ?"
+	args := []any{userID}
+	if beforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, beforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]types.Order, 0)
+	for rows.Next() {
+		o, err := scanRowIntoOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *o)
+	}
+
+	return orders, rows.Err()
+}
+
+// Get a single order by its ID.
+// Returns `types.ErrOrderNotFound` if there is no such order.
+func (s *Store) GetOrderByID(id int) (*types.Order, error) {
+	return getOrderByID(s.db, id, false)
+}
+
+// Same as `GetOrderByID` but runs inside `tx` and locks the order row...
+// so concurrent status changes of the same order are serialized.
+func (s *Store) GetOrderByIDForUpdate(tx *sql.Tx, id int) (*types.Order, error) {
+	return getOrderByID(tx, id, true)
+}
+
+func getOrderByID(q db.Querier, id int, forUpdate bool) (*types.Order, error) {
+	query := "SELECT id, userId, subtotal, discount, shipping, tax, total, status, stockCommitted, address, billingAddress, createdAt FROM orders WHERE id = ?"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := q.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, types.ErrOrderNotFound
+	}
+
+	return scanRowIntoOrder(rows)
+}
+
+// Get the line items of an order.
+func (s *Store) GetOrderItemsByOrderID(orderID int) ([]types.OrderItem, error) {
+	return getOrderItemsByOrderID(s.db, orderID)
+}
+
+// Same as `GetOrderItemsByOrderID` but as part of transaction `tx`.
+func (s *Store) GetOrderItemsByOrderIDTx(tx *sql.Tx, orderID int) ([]types.OrderItem, error) {
+	return getOrderItemsByOrderID(tx, orderID)
+}
+
+func getOrderItemsByOrderID(q db.Querier, orderID int) ([]types.OrderItem, error) {
+	rows, err := q.Query("SELECT id, orderId, productId, variantId, sku, warehouseId, quantity, price, productName, productImage FROM order_items WHERE orderId = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]types.OrderItem, 0)
+	for rows.Next() {
+		item := types.OrderItem{}
+		var variantID, warehouseID sql.NullInt64
+		err := rows.Scan(
+			&item.ID,
+			&item.OrderID,
+			&item.ProductID,
+			&variantID,
+			&item.SKU,
+			&warehouseID,
+			&item.Quantity,
+			&item.Price,
+			&item.ProductName,
+			&item.ProductImage,
+		)
+		if err != nil {
+			return nil, err
+		}
+		item.VariantID, item.WarehouseID = int(variantID.Int64), int(warehouseID.Int64)
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// Set the status of an order as part of transaction `tx`.
+// Callers are expected to have checked the transition (see `CanTransition()`).
+func (s *Store) UpdateOrderStatusTx(tx *sql.Tx, id int, status string) error {
+	_, err := tx.Exec("UPDATE orders SET status = ? WHERE id = ?", status, id)
+	return err
+}
+
+// Mark the ordered quantities of an order as taken off stock, as part of transaction `tx`.
+func (s *Store) SetOrderStockCommittedTx(tx *sql.Tx, id int) error {
+	_, err := tx.Exec("UPDATE orders SET stockCommitted = TRUE WHERE id = ?", id)
+	return err
+}
+
+// Record a status change in `order_status_history` as part of transaction `tx`.
+func (s *Store) CreateOrderStatusChangeTx(tx *sql.Tx, change types.OrderStatusChange) error {
+	// `changedBy` is NULL for changes not made by a user.
+	var changedBy sql.NullInt64
+	if change.ChangedBy > 0 {
+		changedBy = sql.NullInt64{Int64: int64(change.ChangedBy), Valid: true}
+	}
+
+	_, err := tx.Exec("INSERT INTO order_status_history (orderId, fromStatus, toStatus, changedBy, reason) VALUES (?, ?, ?, ?, ?)",
+		change.OrderID, change.FromStatus, change.ToStatus, changedBy, change.Reason)
+	return err
+}
+
+// Get the status changes of an order, oldest first.
+func (s *Store) GetOrderStatusHistory(orderID int) ([]types.OrderStatusChange, error) {
+	rows, err := s.db.Query("SELECT id, orderId, fromStatus, toStatus, changedBy, reason, createdAt FROM order_status_history WHERE orderId = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]types.OrderStatusChange, 0)
+	for rows.Next() {
+		change := types.OrderStatusChange{}
+		var changedBy sql.NullInt64
+		err := rows.Scan(
+			&change.ID,
+			&change.OrderID,
+			&change.FromStatus,
+			&change.ToStatus,
+			&changedBy,
+			&change.Reason,
+			&change.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		change.ChangedBy = int(changedBy.Int64)
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
+
+func scanRowIntoOrder(rows *sql.Rows) (*types.Order, error) {
+	order := new(types.Order)
+	err := rows.Scan(
+		&order.ID,
+		&order.UserID,
+		&order.Subtotal,
+		&order.Discount,
+		&order.Shipping,
+		&order.Tax,
+		&order.Total,
+		&order.Status,
+		&order.StockCommitted,
+		&order.Address,
+		&order.BillingAddress,
+		&order.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
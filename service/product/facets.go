@@ -0,0 +1,209 @@
+package product
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Upper bounds (in minor units) of the price facet's buckets, the last
+// bucket has no upper bound.
+var priceBucketBounds = []int64{1000, 2500, 5000, 10000, 25000, 50000}
+
+// Get the IDs of the products matching the filters of `opts`, by ID.
+// Paging & sorting options are ignored.
+func (s *Store) GetProductIDs(opts types.ProductQueryOptions) ([]int, error) {
+	if err := s.checkCategoryFilter(opts); err != nil {
+		return nil, err
+	}
+
+	where, args := productFilters(opts)
+	rows, err := s.db.Query("SELECT id FROM products"+whereClause(where)+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Count the products matching the filters of `opts` by category, price bucket,
+// availability, tag & variant option value. Paging & sorting options are ignored.
+func (s *Store) GetProductFacets(opts types.ProductQueryOptions) (*types.ProductFacets, error) {
+	if err := s.checkCategoryFilter(opts); err != nil {
+		return nil, err
+	}
+
+	// Every facet counts over the same set of products.
+	where, args := productFilters(opts)
+	matching := "SELECT id FROM products" + whereClause(where)
+
+	facets := &types.ProductFacets{}
+	var err error
+	if facets.Categories, err = s.getCategoryFacet(matching, args); err != nil {
+		return nil, err
+	}
+	if facets.Prices, err = s.getPriceFacet(matching, args); err != nil {
+		return nil, err
+	}
+	if facets.Availability, err = s.getAvailabilityFacet(matching, args); err != nil {
+		return nil, err
+	}
+	if facets.Tags, err = s.getTagFacet(matching, args); err != nil {
+		return nil, err
+	}
+	if facets.Attributes, err = s.getAttributeFacet(matching, args); err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+// Products are counted in their categories & every category above them.
+func (s *Store) getCategoryFacet(matching string, args []any) ([]types.CategoryFacet, error) {
+	rows, err := s.db.Query(`WITH RECURSIVE ancestry (categoryId, ancestorId) AS (
+			SELECT id, id FROM categories
+			UNION ALL
+			SELECT a.categoryId, c.parentId FROM ancestry a JOIN categories c ON c.id = a.ancestorId
+			WHERE c.parentId IS NOT NULL
+		)
+		SELECT c.id, c.parentId, c.slug, c.name, COUNT(DISTINCT pc.productId) AS products
+		FROM product_categories pc
+		JOIN ancestry a ON a.categoryId = pc.categoryId
+		JOIN categories c ON c.id = a.ancestorId
+		WHERE pc.productId IN (`+matching+`)
+		GROUP BY c.id, c.parentId, c.slug, c.name
+		ORDER BY products DESC, c.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]types.CategoryFacet, 0)
+	for rows.Next() {
+		c := types.CategoryFacet{}
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &parentID, &c.Slug, &c.Name, &c.Products); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			c.ParentID = &id
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+func (s *Store) getPriceFacet(matching string, args []any) ([]types.PriceBucket, error) {
+	// Bucket index by price, "CASE WHEN price < ? THEN 0 WHEN price < ? THEN 1 ... ELSE n END".
+	var bucket strings.Builder
+	bucketArgs := make([]any, 0, len(priceBucketBounds)+len(args))
+	bucket.WriteString("CASE")
+	for i, bound := range priceBucketBounds {
+		fmt.Fprintf(&bucket, " WHEN price < ? THEN %d", i)
+		bucketArgs = append(bucketArgs, types.NewMoney(bound))
+	}
+	fmt.Fprintf(&bucket, " ELSE %d END", len(priceBucketBounds))
+
+	rows, err := s.db.Query(fmt.Sprintf("SELECT %s AS bucket, COUNT(*) FROM products WHERE id IN (%s) GROUP BY bucket ORDER BY bucket", bucket.String(), matching),
+		append(bucketArgs, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make([]types.PriceBucket, 0)
+	for rows.Next() {
+		var i, products int
+		if err := rows.Scan(&i, &products); err != nil {
+			return nil, err
+		}
+
+		b := types.PriceBucket{Min: types.NewMoney(0), Products: products}
+		if i > 0 {
+			b.Min = types.NewMoney(priceBucketBounds[i-1])
+		}
+		if i < len(priceBucketBounds) {
+			max := types.NewMoney(priceBucketBounds[i])
+			b.Max = &max
+		}
+		buckets = append(buckets, b)
+	}
+
+	return buckets, rows.Err()
+}
+
+// In stock like the `inStock` filter: some quantity available, reservations aside.
+func (s *Store) getAvailabilityFacet(matching string, args []any) (types.AvailabilityFacet, error) {
+	var a types.AvailabilityFacet
+	query := "SELECT COALESCE(SUM(quantity > reserved), 0), COALESCE(SUM(quantity <= reserved), 0) FROM (SELECT quantity, " + reservedQuantity + " AS reserved FROM products WHERE id IN (" + matching + ")) p"
+	err := s.db.QueryRow(query, append([]any{time.Now()}, args...)...).
+		Scan(&a.InStock, &a.OutOfStock)
+	return a, err
+}
+
+func (s *Store) getTagFacet(matching string, args []any) ([]types.TagCount, error) {
+	rows, err := s.db.Query("SELECT tag, COUNT(*) AS products FROM product_tags WHERE productId IN ("+matching+") GROUP BY tag ORDER BY products DESC, tag", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]types.TagCount, 0)
+	for rows.Next() {
+		t := types.TagCount{}
+		if err := rows.Scan(&t.Tag, &t.Products); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// Options are grouped by name across products, inactive variants don't count.
+func (s *Store) getAttributeFacet(matching string, args []any) ([]types.AttributeFacet, error) {
+	rows, err := s.db.Query(`SELECT o.name, vov.value, COUNT(DISTINCT v.productId) AS products
+		FROM product_variants v
+		JOIN variant_option_values vov ON vov.variantId = v.id
+		JOIN product_options o ON o.id = vov.optionId
+		WHERE v.active AND v.productId IN (`+matching+`)
+		GROUP BY o.name, vov.value
+		ORDER BY o.name, products DESC, vov.value`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attributes := make([]types.AttributeFacet, 0)
+	for rows.Next() {
+		var name string
+		v := types.FacetValue{}
+		if err := rows.Scan(&name, &v.Value, &v.Products); err != nil {
+			return nil, err
+		}
+
+		// Rows come sorted by name, values of an option are consecutive.
+		if n := len(attributes); n == 0 || attributes[n-1].Name != name {
+			attributes = append(attributes, types.AttributeFacet{Name: name})
+		}
+		last := &attributes[len(attributes)-1]
+		last.Values = append(last.Values, v)
+	}
+
+	return attributes, rows.Err()
+}
@@ -0,0 +1,350 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+// Options of `Handler.ImportProducts()`.
+type ImportOptions struct {
+	// Run every row in a transaction that is rolled back: the report lists
+	// the same errors a real import would, nothing is saved.
+	DryRun bool
+	// User behind the stock movements, 0 for imports not made by a user.
+	ActorID int
+}
+
+// Returned from dry run transactions to roll them back.
+var errDryRun = errors.New("dry run")
+
+// Create or update (by SKU) a product for each record, each row in its own
+// transaction. Rows that fail are listed in the report & skipped, the error
+// is only returned for failures that stop the import (e.g. the DB being
+// down), along with the report of the rows before it.
+func (h *Handler) ImportProducts(ctx context.Context, records RecordReader, opts ImportOptions) (*types.ImportReport, error) {
+	report := &types.ImportReport{DryRun: opts.DryRun, Errors: []types.ImportError{}}
+	// Row of each SKU, a SKU is imported once per file.
+	seen := map[string]int{}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		record, row, err := records.Next()
+		if err == io.EOF {
+			return report, nil
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			report.Rows++
+			addImportError(report, types.ImportError{Row: rowErr.Row, Field: rowErr.Field, Error: rowErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Rows++
+
+		if err := utils.Validate.Struct(record); err != nil {
+			errors := err.(validator.ValidationErrors)
+			addImportError(report, types.ImportError{
+				Row:   row,
+				SKU:   record.SKU,
+				Field: recordFieldName(errors[0].StructField()),
+				Error: fmt.Sprintf("failed on the '%s' rule", errors[0].Tag()),
+			})
+			continue
+		}
+
+		if previous, ok := seen[record.SKU]; ok {
+			addImportError(report, types.ImportError{Row: row, SKU: record.SKU, Field: "sku", Error: fmt.Sprintf("already imported from row %d", previous)})
+			continue
+		}
+		seen[record.SKU] = row
+
+		created, importErr, err := h.importRecord(ctx, *record, opts)
+		if err != nil {
+			return report, err
+		}
+		switch {
+		case importErr != nil:
+			importErr.Row, importErr.SKU = row, record.SKU
+			addImportError(report, *importErr)
+		case created:
+			report.Created++
+		default:
+			report.Updated++
+		}
+	}
+}
+
+// Create or update the product of `record`. Returns whether it was (or
+// would be, on a dry run) created, or the reason it can't be imported.
+// The error is only set for failures not caused by the record.
+func (h *Handler) importRecord(ctx context.Context, record types.ProductRecord, opts ImportOptions) (bool, *types.ImportError, error) {
+	movement := types.StockMovement{
+		Type:    types.StockMovementImport,
+		ActorID: opts.ActorID,
+		Reason:  "stock set by product import",
+	}
+
+	product, err := h.store.GetProductBySKU(record.SKU)
+	created := errors.Is(err, types.ErrProductNotFound)
+	if err != nil && !created {
+		return false, nil, err
+	}
+
+	if created {
+		product = &types.Product{SKU: record.SKU, CategoryIDs: []int{}, Tags: []string{}}
+		required := []struct {
+			field   string
+			missing bool
+		}{
+			{"name", record.Name == nil},
+			{"description", record.Description == nil},
+			{"image", record.Image == nil},
+			{"price", record.Price == nil},
+		}
+		for _, r := range required {
+			if r.missing {
+				return false, &types.ImportError{Field: r.field, Error: "required for new products"}, nil
+			}
+		}
+	}
+
+	if record.Name != nil {
+		product.Name = *record.Name
+	}
+	if record.Description != nil {
+		product.Description = *record.Description
+	}
+	if record.Image != nil {
+		product.Image = *record.Image
+	}
+	if record.Price != nil {
+		product.Price = *record.Price
+	}
+	if record.CategoryIDs != nil {
+		product.CategoryIDs = *record.CategoryIDs
+	}
+	if record.Tags != nil {
+		product.Tags = normalizeTags(*record.Tags)
+	}
+
+	err = h.transactor.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if created {
+			quantity := 0
+			if record.Quantity != nil {
+				quantity = *record.Quantity
+			}
+			product.ID, err = h.createProductTx(tx, *product, quantity, movement)
+		} else {
+			err = h.saveProductTx(tx, product, record.Quantity, movement)
+		}
+		if err == nil && opts.DryRun {
+			return errDryRun
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, errDryRun):
+		return created, nil, nil
+	case errors.Is(err, types.ErrCategoryNotFound):
+		return false, &types.ImportError{Field: "categoryIDs", Error: err.Error()}, nil
+	case errors.Is(err, types.ErrProductSKUTaken):
+		return false, &types.ImportError{Field: "sku", Error: err.Error()}, nil
+	case errors.Is(err, types.ErrOutOfStock):
+		return false, &types.ImportError{Field: "quantity", Error: err.Error()}, nil
+	case err != nil:
+		return false, nil, err
+	}
+
+	if created {
+		if product, err = h.store.GetProductByID(product.ID); err != nil {
+			return false, nil, err
+		}
+	}
+	h.index.IndexProduct(*product)
+	return created, nil, nil
+}
+
+func addImportError(report *types.ImportReport, importErr types.ImportError) {
+	report.Failed++
+	if len(report.Errors) < types.MaxImportErrors {
+		report.Errors = append(report.Errors, importErr)
+	}
+}
+
+// Column name of a `types.ProductRecord` field, e.g. "categoryIDs" for "CategoryIDs".
+func recordFieldName(structField string) string {
+	field, ok := reflect.TypeOf(types.ProductRecord{}).FieldByName(structField)
+	if !ok {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
+
+// Write every product to `records`, oldest first, a page at a time so the
+// catalog is never held in memory.
+func (h *Handler) ExportProducts(ctx context.Context, records RecordWriter) error {
+	opts := types.ProductQueryOptions{Sort: types.ProductSortOldest, Limit: MaxPageSize}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page, err := h.store.GetProductsWithOptions(opts)
+		if err != nil {
+			return err
+		}
+		for _, p := range page.Products {
+			if err := records.Write(productRecord(p)); err != nil {
+				return err
+			}
+		}
+		if err := records.Flush(); err != nil {
+			return err
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// Content types of the file formats, also used to tell the format of uploads.
+var productFileContentTypes = map[string]string{
+	types.ProductFileCSV:   "text/csv",
+	types.ProductFileJSONL: "application/x-ndjson",
+}
+
+// HandlerFunc to import products from a CSV or JSONL request body (admin).
+func (h *Handler) handleImportProducts(w http.ResponseWriter, r *http.Request) {
+	// General Flow:
+	// 1. Parse the format, column mapping & dry run options.
+	// 2. Read the header (CSV) & import the rows one by one.
+	// 3. Respond with the report, rows that failed are listed in it.
+
+	query := r.URL.Query()
+	format, err := productFileFormat(query.Get("format"), r.Header.Get("Content-Type"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	mapping, err := ParseColumnMapping(query.Get("columns"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	dryRun := false
+	if raw := query.Get("dryRun"); raw != "" {
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid dryRun %q", raw))
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, config.Envs.MaxImportBytes)
+	records, err := NewRecordReader(format, body, mapping)
+	if err == nil {
+		var report *types.ImportReport
+		report, err = h.ImportProducts(r.Context(), records, ImportOptions{DryRun: dryRun, ActorID: auth.GetUseIDFromContext(r.Context())})
+		if err == nil {
+			utils.WriteJSON(w, http.StatusOK, report)
+			return
+		}
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("import file larger than %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, types.ErrInvalidColumns):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// HandlerFunc to export every product as CSV or JSONL (admin).
+func (h *Handler) handleExportProducts(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = types.ProductFileCSV
+	}
+
+	out := &countingWriter{w: w}
+	records, err := NewRecordWriter(format, out)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", productFileContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+	err = h.ExportProducts(r.Context(), records)
+	if err != nil && out.n == 0 {
+		w.Header().Del("Content-Type")
+		w.Header().Del("Content-Disposition")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// The status went out with the first page, all that's left is cutting the file short.
+	if err != nil {
+		log.Printf("product export failed: %v", err)
+	}
+}
+
+// Counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// The format given in the query, or else the one of the content type.
+func productFileFormat(format, contentType string) (string, error) {
+	if format != "" {
+		if _, ok := productFileContentTypes[format]; !ok {
+			return "", types.ErrUnknownFileFormat
+		}
+		return format, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for format, known := range productFileContentTypes {
+		if mediaType == known {
+			return format, nil
+		}
+	}
+	if mediaType == "application/jsonl" {
+		return types.ProductFileJSONL, nil
+	}
+	return "", types.ErrUnknownFileFormat
+}
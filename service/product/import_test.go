@@ -0,0 +1,214 @@
+package product
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestImportExportHandlers(t *testing.T) {
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleCustomer},
+		2: {ID: 2, Role: types.RoleAdmin},
+	}}
+	productStore := &mockProductStore{products: map[int]types.Product{
+		1: {ID: 1, SKU: "PEN-1", Name: "pen", Description: "blue pen", Image: "pen.png", Price: types.NewMoney(150), Quantity: 10, CategoryIDs: []int{}, Tags: []string{}},
+	}}
+	ledgerStore := &mockLedgerStore{products: productStore}
+	index := &mockProductIndex{indexed: map[int]types.Product{}}
+	handler := NewHandler(productStore, userStore, ledgerStore, nil, index, &rollbackTransactor{products: productStore})
+
+	router := http.NewServeMux()
+	handler.RegisterRoutes(router)
+
+	send := func(method, path, contentType, body string, userID int) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+
+		token, err := auth.CreateJWT(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	importFile := func(query, contentType, body string) types.ImportReport {
+		rr := send(http.MethodPost, "/products/import"+query, contentType, body, 2)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var report types.ImportReport
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	file := "sku,title,description,image,price,quantity,categoryIDs\n" +
+		"PEN-1,,,,2.00,4,\n" + // Update of the price & stock only.
+		"INK-1,ink,black ink,ink.png,3.00,5,3\n" +
+		"INK-2,ink,,ink.png,3.00,5,\n" + // New without a description.
+		"INK-3,ink,red ink,ink.png,3.00,5,42\n" + // Unknown category.
+		"INK-1,ink,black ink,ink.png,4.00,,\n" + // Same SKU twice.
+		"BAD SKU,ink,red ink,ink.png,0,,\n"
+
+	t.Run("should forbid customers from importing", func(t *testing.T) {
+		if rr := send(http.MethodPost, "/products/import", "text/csv", file, 1); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should reject unknown formats & columns", func(t *testing.T) {
+		if rr := send(http.MethodPost, "/products/import", "text/plain", file, 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for an unknown format, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := send(http.MethodPost, "/products/import", "text/csv", file, 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for an unmapped column, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should only report what a dry run would do", func(t *testing.T) {
+		report := importFile("?columns=title=name&dryRun=true", "text/csv", file)
+
+		if !report.DryRun || report.Rows != 6 || report.Created != 1 || report.Updated != 1 || report.Failed != 4 {
+			t.Errorf("unexpected report %+v", report)
+		}
+		if len(productStore.products) != 1 || productStore.products[1].Quantity != 10 || len(index.indexed) != 0 {
+			t.Errorf("expected nothing to be saved, got %+v", productStore.products)
+		}
+	})
+
+	t.Run("should upsert by sku & report the failed rows", func(t *testing.T) {
+		report := importFile("?format=csv&columns=title=name", "", file)
+
+		if report.DryRun || report.Rows != 6 || report.Created != 1 || report.Updated != 1 || report.Failed != 4 {
+			t.Fatalf("unexpected report %+v", report)
+		}
+		want := []types.ImportError{
+			{Row: 4, SKU: "INK-2", Field: "description"},
+			{Row: 5, SKU: "INK-3", Field: "categoryIDs"},
+			{Row: 6, SKU: "INK-1", Field: "sku"},
+			{Row: 7, SKU: "BAD SKU", Field: "sku"},
+		}
+		for i, e := range report.Errors {
+			if e.Row != want[i].Row || e.SKU != want[i].SKU || e.Field != want[i].Field || e.Error == "" {
+				t.Errorf("expected error %+v, got %+v", want[i], e)
+			}
+		}
+
+		if pen := productStore.products[1]; pen.Price.Amount != 200 || pen.Quantity != 4 || pen.Name != "pen" {
+			t.Errorf("expected the pen's price & stock to be updated, got %+v", pen)
+		}
+		if ink := productStore.products[2]; ink.SKU != "INK-1" || ink.Quantity != 5 || len(ink.CategoryIDs) != 1 {
+			t.Errorf("expected the ink to be created, got %+v", ink)
+		}
+		for _, m := range ledgerStore.movements {
+			if m.Type != types.StockMovementImport || m.ActorID != 2 {
+				t.Errorf("expected import movements by the admin, got %+v", m)
+			}
+		}
+		if len(index.indexed) != 2 {
+			t.Errorf("expected both products to be indexed, got %+v", index.indexed)
+		}
+	})
+
+	t.Run("should import jsonl", func(t *testing.T) {
+		report := importFile("", "application/x-ndjson", `{"sku":"INK-1","tags":["Blue"]}`)
+
+		if report.Updated != 1 || report.Failed != 0 {
+			t.Fatalf("unexpected report %+v", report)
+		}
+		if ink := productStore.products[2]; len(ink.Tags) != 1 || ink.Tags[0] != "blue" || ink.Quantity != 5 {
+			t.Errorf("expected only the tags to change, got %+v", ink)
+		}
+	})
+
+	t.Run("should export every product", func(t *testing.T) {
+		rr := send(http.MethodGet, "/products/export?format=csv", "", "", 2)
+
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/csv" {
+			t.Fatalf("expected a csv file, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+		}
+		want := "sku,name,description,image,price,quantity,categoryIDs,tags\n" +
+			"PEN-1,pen,blue pen,pen.png,2.00,4,,\n" +
+			"INK-1,ink,black ink,ink.png,3.00,5,3,blue\n"
+		if rr.Body.String() != want {
+			t.Errorf("expected\n%s\ngot\n%s", want, rr.Body.String())
+		}
+
+		if rr := send(http.MethodGet, "/products/export?format=xml", "", "", 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should re-import its own export unchanged", func(t *testing.T) {
+		// Created without a SKU, like the products from before SKUs.
+		if _, err := productStore.RegisterProduct(types.Product{Name: "ink", Description: "red ink", Image: "ink.png", Price: types.NewMoney(300), CategoryIDs: []int{}, Tags: []string{}}); err != nil {
+			t.Fatal(err)
+		}
+		before := maps.Clone(productStore.products)
+
+		for _, format := range []string{types.ProductFileCSV, types.ProductFileJSONL} {
+			rr := send(http.MethodGet, "/products/export?format="+format, "", "", 2)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			report := importFile("?format="+format, "", rr.Body.String())
+			if report.Rows != len(before) || report.Updated != len(before) || report.Failed != 0 {
+				t.Errorf("expected every %s row to update a product, got %+v", format, report)
+			}
+			for id, want := range before {
+				if got := productStore.products[id]; !reflect.DeepEqual(got, want) {
+					t.Errorf("expected %s re-import to leave product %d unchanged, got %+v", format, id, got)
+				}
+			}
+		}
+	})
+}
+
+// Restores the mocked products if `fn` fails, like a rolled back transaction.
+type rollbackTransactor struct {
+	products *mockProductStore
+}
+
+func (m *rollbackTransactor) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	saved := maps.Clone(m.products.products)
+	err := fn(nil)
+	if err != nil {
+		m.products.products = saved
+	}
+	return err
+}
+
+func TestProductFileFormat(t *testing.T) {
+	for _, c := range []struct{ format, contentType, want string }{
+		{"", "text/csv; charset=utf-8", types.ProductFileCSV},
+		{"", "application/jsonl", types.ProductFileJSONL},
+		{"jsonl", "text/csv", types.ProductFileJSONL},
+	} {
+		if got, err := productFileFormat(c.format, c.contentType); err != nil || got != c.want {
+			t.Errorf("expected %s for %q & %q, got %q (%v)", c.want, c.format, c.contentType, got, err)
+		}
+	}
+
+	if _, err := productFileFormat("xml", ""); err != types.ErrUnknownFileFormat {
+		t.Errorf("expected ErrUnknownFileFormat, got %v", err)
+	}
+}
@@ -0,0 +1,393 @@
+package product
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Fields of `types.ProductRecord`, the columns of CSV files & keys of JSONL
+// lines (matched case-insensitively), in CSV export order.
+var recordFields = []string{"sku", "name", "description", "image", "price", "quantity", "categoryIDs", "tags"}
+
+// Separates the values of list columns (`categoryIDs` & `tags`) in CSV files.
+const csvListSeparator = "|"
+
+// Column mapping value of columns that are not imported.
+const skipColumn = "-"
+
+// Reads the records of a bulk product import.
+type RecordReader interface {
+	// The next record & its row (line in the file), `io.EOF` after the last
+	// one. A `*RowError` only concerns that row, reading can go on after it.
+	Next() (*types.ProductRecord, int, error)
+}
+
+// A row that could not be read into a record.
+type RowError struct {
+	Row   int
+	Field string // Empty if the error isn't about a single field.
+	Err   error
+}
+
+func (e *RowError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d: %s: %v", e.Row, e.Field, e.Err)
+}
+
+// Parse a column mapping, e.g. "title=name,cost=price,notes=-". Each source
+// column (or JSONL key) is mapped to a field of `types.ProductRecord`, or to
+// "-" to leave it out. Columns named like a field don't need mapping.
+func ParseColumnMapping(s string) (map[string]string, error) {
+	mapping := map[string]string{}
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		column, field, ok := strings.Cut(pair, "=")
+		column, field = strings.TrimSpace(column), strings.TrimSpace(field)
+		if !ok || column == "" || field == "" {
+			return nil, fmt.Errorf("%w: invalid mapping %q, expected column=field", types.ErrInvalidColumns, pair)
+		}
+
+		if field != skipColumn {
+			known, ok := recordField(field)
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown field %q, expected one of %s", types.ErrInvalidColumns, field, strings.Join(recordFields, ", "))
+			}
+			field = known
+		}
+		mapping[column] = field
+	}
+	return mapping, nil
+}
+
+// The field `column` is imported into, "-" if it is left out.
+func resolveColumn(column string, mapping map[string]string) (string, bool) {
+	column = strings.TrimSpace(column)
+	if field, ok := mapping[column]; ok {
+		return field, true
+	}
+	return recordField(column)
+}
+
+func recordField(name string) (string, bool) {
+	for _, field := range recordFields {
+		if strings.EqualFold(field, name) {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// Reader of `format` (`types.ProductFileCSV` or `types.ProductFileJSONL`)
+// records, with column names mapped by `mapping` (see `ParseColumnMapping()`).
+// CSV headers are read right away, returns `types.ErrInvalidColumns` if a
+// column is unknown or the `sku` column is missing.
+func NewRecordReader(format string, r io.Reader, mapping map[string]string) (RecordReader, error) {
+	switch format {
+	case types.ProductFileCSV:
+		return newCSVRecordReader(r, mapping)
+	case types.ProductFileJSONL:
+		return &jsonlRecordReader{r: bufio.NewReader(r), mapping: mapping}, nil
+	default:
+		return nil, types.ErrUnknownFileFormat
+	}
+}
+
+// CSV with a header row. Empty cells leave the field unchanged, list
+// values are separated by `csvListSeparator`.
+type csvRecordReader struct {
+	r *csv.Reader
+	// Field of each column, "-" for columns left out.
+	fields []string
+}
+
+func newCSVRecordReader(r io.Reader, mapping map[string]string) (*csvRecordReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: missing header row", types.ErrInvalidColumns)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Spreadsheet apps like to start UTF-8 files with a byte order mark.
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	fields := make([]string, len(header))
+	seen := map[string]bool{}
+	for i, column := range header {
+		field, ok := resolveColumn(column, mapping)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown column %q, map it to a field or to %q", types.ErrInvalidColumns, column, skipColumn)
+		}
+		if field != skipColumn && seen[field] {
+			return nil, fmt.Errorf("%w: more than one column for %s", types.ErrInvalidColumns, field)
+		}
+		seen[field] = true
+		fields[i] = field
+	}
+	if !seen["sku"] {
+		return nil, fmt.Errorf("%w: missing sku column", types.ErrInvalidColumns)
+	}
+
+	return &csvRecordReader{r: cr, fields: fields}, nil
+}
+
+func (c *csvRecordReader) Next() (*types.ProductRecord, int, error) {
+	row, err := c.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, parseErr.StartLine, &RowError{Row: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	record := new(types.ProductRecord)
+	for i, value := range row {
+		value = strings.TrimSpace(value)
+		if c.fields[i] == skipColumn || value == "" {
+			continue
+		}
+		if err := setCSVField(record, c.fields[i], value); err != nil {
+			return nil, line, &RowError{Row: line, Field: c.fields[i], Err: err}
+		}
+	}
+	return record, line, nil
+}
+
+func setCSVField(record *types.ProductRecord, field, value string) error {
+	switch field {
+	case "sku":
+		record.SKU = value
+	case "name":
+		record.Name = &value
+	case "description":
+		record.Description = &value
+	case "image":
+		record.Image = &value
+	case "price":
+		price, err := types.ParseMoney(value)
+		if err != nil {
+			return err
+		}
+		record.Price = &price
+	case "quantity":
+		quantity, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid quantity %q", value)
+		}
+		record.Quantity = &quantity
+	case "categoryIDs":
+		ids := []int{}
+		for _, s := range strings.Split(value, csvListSeparator) {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("invalid category id %q", s)
+			}
+			ids = append(ids, id)
+		}
+		record.CategoryIDs = &ids
+	case "tags":
+		tags := []string{}
+		for _, tag := range strings.Split(value, csvListSeparator) {
+			tags = append(tags, strings.TrimSpace(tag))
+		}
+		record.Tags = &tags
+	}
+	return nil
+}
+
+// A JSON object per line, blank lines are skipped. Keys left out or `null`
+// leave the field unchanged, `"tags": []` removes the product's tags.
+type jsonlRecordReader struct {
+	r       *bufio.Reader
+	mapping map[string]string
+	line    int
+}
+
+func (j *jsonlRecordReader) Next() (*types.ProductRecord, int, error) {
+	for {
+		data, err := j.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		if err == io.EOF && len(data) == 0 {
+			return nil, 0, io.EOF
+		}
+		j.line++
+
+		if data = bytes.TrimSpace(data); len(data) == 0 {
+			continue
+		}
+		record, rowErr := j.parse(data)
+		if rowErr != nil {
+			return nil, j.line, rowErr
+		}
+		return record, j.line, nil
+	}
+}
+
+func (j *jsonlRecordReader) parse(data []byte) (*types.ProductRecord, *RowError) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, &RowError{Row: j.line, Err: err}
+	}
+
+	values := map[string]json.RawMessage{}
+	for key, value := range object {
+		field, ok := resolveColumn(key, j.mapping)
+		if !ok {
+			return nil, &RowError{Row: j.line, Field: key, Err: errors.New("unknown field")}
+		}
+		if field == skipColumn {
+			continue
+		}
+		if _, ok := values[field]; ok {
+			return nil, &RowError{Row: j.line, Field: field, Err: errors.New("given more than once")}
+		}
+		values[field] = value
+	}
+
+	// In field order, so the same line always reports the same error.
+	record := new(types.ProductRecord)
+	for _, field := range recordFields {
+		value, ok := values[field]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(value, recordFieldTarget(record, field)); err != nil {
+			return nil, &RowError{Row: j.line, Field: field, Err: err}
+		}
+	}
+	return record, nil
+}
+
+// Pointer to the `field` of `record`, for decoding JSON into it.
+func recordFieldTarget(record *types.ProductRecord, field string) any {
+	switch field {
+	case "sku":
+		return &record.SKU
+	case "name":
+		return &record.Name
+	case "description":
+		return &record.Description
+	case "image":
+		return &record.Image
+	case "price":
+		return &record.Price
+	case "quantity":
+		return &record.Quantity
+	case "categoryIDs":
+		return &record.CategoryIDs
+	default:
+		return &record.Tags
+	}
+}
+
+// Writes the records of a bulk product export, buffered.
+type RecordWriter interface {
+	Write(types.ProductRecord) error
+	// Write the buffered records to the underlying writer.
+	Flush() error
+}
+
+// Writer of `format` records, in the format `NewRecordReader()` reads.
+func NewRecordWriter(format string, w io.Writer) (RecordWriter, error) {
+	switch format {
+	case types.ProductFileCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(recordFields); err != nil {
+			return nil, err
+		}
+		return &csvRecordWriter{w: cw}, nil
+	case types.ProductFileJSONL:
+		bw := bufio.NewWriter(w)
+		encoder := json.NewEncoder(bw)
+		encoder.SetEscapeHTML(false)
+		return &jsonlRecordWriter{w: bw, encoder: encoder}, nil
+	default:
+		return nil, types.ErrUnknownFileFormat
+	}
+}
+
+type csvRecordWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvRecordWriter) Write(record types.ProductRecord) error {
+	row := make([]string, len(recordFields))
+	row[0] = record.SKU
+	if record.Name != nil {
+		row[1] = *record.Name
+	}
+	if record.Description != nil {
+		row[2] = *record.Description
+	}
+	if record.Image != nil {
+		row[3] = *record.Image
+	}
+	if record.Price != nil {
+		row[4] = record.Price.String()
+	}
+	if record.Quantity != nil {
+		row[5] = strconv.Itoa(*record.Quantity)
+	}
+	if record.CategoryIDs != nil {
+		ids := make([]string, len(*record.CategoryIDs))
+		for i, id := range *record.CategoryIDs {
+			ids[i] = strconv.Itoa(id)
+		}
+		row[6] = strings.Join(ids, csvListSeparator)
+	}
+	if record.Tags != nil {
+		row[7] = strings.Join(*record.Tags, csvListSeparator)
+	}
+	return c.w.Write(row)
+}
+
+func (c *csvRecordWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlRecordWriter struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (j *jsonlRecordWriter) Write(record types.ProductRecord) error {
+	return j.encoder.Encode(record)
+}
+
+func (j *jsonlRecordWriter) Flush() error {
+	return j.w.Flush()
+}
+
+// All fields of `p` as a record.
+func productRecord(p types.Product) types.ProductRecord {
+	return types.ProductRecord{
+		SKU:         p.SKU,
+		Name:        &p.Name,
+		Description: &p.Description,
+		Image:       &p.Image,
+		Price:       &p.Price,
+		Quantity:    &p.Quantity,
+		CategoryIDs: &p.CategoryIDs,
+		Tags:        &p.Tags,
+	}
+}
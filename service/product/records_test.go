@@ -0,0 +1,170 @@
+package product
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Every record & row error of `r`, until the end of the file.
+func readAll(t *testing.T, r RecordReader) ([]types.ProductRecord, []*RowError) {
+	var records []types.ProductRecord
+	var rowErrs []*RowError
+	for {
+		record, _, err := r.Next()
+		if err == io.EOF {
+			return records, rowErrs
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rowErrs = append(rowErrs, rowErr)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, *record)
+	}
+}
+
+func TestRecordReader(t *testing.T) {
+	mapping, err := ParseColumnMapping("title=name, cost = price,notes=-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should map csv columns & leave empty cells unchanged", func(t *testing.T) {
+		file := "\ufeffSKU,title,cost,Quantity,tags,notes\n" +
+			"PEN-1,Blue pen,1.50,10,office| ink,fragile\n" +
+			"PEN-2,,,,,\n"
+
+		r, err := NewRecordReader(types.ProductFileCSV, strings.NewReader(file), mapping)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, rowErrs := readAll(t, r)
+
+		if len(records) != 2 || len(rowErrs) != 0 {
+			t.Fatalf("expected 2 records, got %+v & %v", records, rowErrs)
+		}
+		pen := records[0]
+		if pen.SKU != "PEN-1" || *pen.Name != "Blue pen" || pen.Price.Amount != 150 || *pen.Quantity != 10 || len(*pen.Tags) != 2 || (*pen.Tags)[1] != "ink" || pen.Description != nil {
+			t.Errorf("unexpected record %+v", pen)
+		}
+		if blank := records[1]; blank.Name != nil || blank.Price != nil || blank.Quantity != nil || blank.Tags != nil {
+			t.Errorf("expected empty cells to be left out, got %+v", blank)
+		}
+	})
+
+	t.Run("should report bad rows & read on", func(t *testing.T) {
+		file := "sku,price,categoryIDs\n" +
+			"A,abc,\n" +
+			"B,1,2|x\n" +
+			"C,1\n" +
+			"D,2,3|4\n"
+
+		r, err := NewRecordReader(types.ProductFileCSV, strings.NewReader(file), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, rowErrs := readAll(t, r)
+
+		if len(records) != 1 || records[0].SKU != "D" || len(*records[0].CategoryIDs) != 2 {
+			t.Errorf("expected only row D to be read, got %+v", records)
+		}
+		if len(rowErrs) != 3 || rowErrs[0].Row != 2 || rowErrs[0].Field != "price" || rowErrs[1].Field != "categoryIDs" || rowErrs[2].Row != 4 {
+			t.Errorf("unexpected row errors %v", rowErrs)
+		}
+	})
+
+	t.Run("should reject unknown & missing columns", func(t *testing.T) {
+		for _, header := range []string{"sku,colour", "name,price", "sku,name,title", ""} {
+			_, err := NewRecordReader(types.ProductFileCSV, strings.NewReader(header), mapping)
+
+			if !errors.Is(err, types.ErrInvalidColumns) {
+				t.Errorf("expected ErrInvalidColumns for %q, got %v", header, err)
+			}
+		}
+	})
+
+	t.Run("should map jsonl keys & report bad lines", func(t *testing.T) {
+		file := `{"sku":"PEN-1","title":"Blue pen","cost":"1.5","tags":[],"notes":"x"}` + "\n" +
+			"\n" +
+			`{"sku":"PEN-2","quantity":"ten"}` + "\n" +
+			`{"sku":"PEN-3","colour":"red"}` + "\n" +
+			`not json` + "\n" +
+			`{"sku":"PEN-4","price":{"amount":"2.00","currency":"USD"},"name":null}`
+
+		r, err := NewRecordReader(types.ProductFileJSONL, strings.NewReader(file), mapping)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, rowErrs := readAll(t, r)
+
+		if len(records) != 2 || *records[0].Name != "Blue pen" || records[0].Price.Amount != 150 || len(*records[0].Tags) != 0 {
+			t.Fatalf("unexpected records %+v", records)
+		}
+		if last := records[1]; last.SKU != "PEN-4" || last.Price.Amount != 200 || last.Name != nil {
+			t.Errorf("unexpected record %+v", last)
+		}
+		if len(rowErrs) != 3 || rowErrs[0].Row != 3 || rowErrs[0].Field != "quantity" || rowErrs[1].Field != "colour" || rowErrs[2].Row != 5 {
+			t.Errorf("unexpected row errors %v", rowErrs)
+		}
+	})
+
+	t.Run("should reject mappings to unknown fields", func(t *testing.T) {
+		for _, raw := range []string{"title=label", "title", "=name"} {
+			if _, err := ParseColumnMapping(raw); !errors.Is(err, types.ErrInvalidColumns) {
+				t.Errorf("expected ErrInvalidColumns for %q, got %v", raw, err)
+			}
+		}
+	})
+}
+
+func TestRecordWriter(t *testing.T) {
+	product := types.Product{
+		SKU:         "PEN-1",
+		Name:        "Pen, blue",
+		Description: `A "blue" pen`,
+		Image:       "pen.png",
+		Price:       types.NewMoney(150),
+		Quantity:    10,
+		CategoryIDs: []int{3, 4},
+		Tags:        []string{"ink", "office"},
+	}
+
+	for _, format := range []string{types.ProductFileCSV, types.ProductFileJSONL} {
+		t.Run("should read back "+format+" exports", func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewRecordWriter(format, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Write(productRecord(product)); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewRecordReader(format, &buf, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			records, rowErrs := readAll(t, r)
+			if len(records) != 1 || len(rowErrs) != 0 {
+				t.Fatalf("expected a single record, got %+v & %v", records, rowErrs)
+			}
+
+			got := records[0]
+			if got.SKU != product.SKU || *got.Name != product.Name || *got.Description != product.Description || *got.Price != product.Price ||
+				*got.Quantity != 10 || len(*got.CategoryIDs) != 2 || (*got.Tags)[1] != "office" {
+				t.Errorf("unexpected record %+v", got)
+			}
+		})
+	}
+}
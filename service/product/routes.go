@@ -1,37 +1,494 @@
 package product
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gitKashish/ecommerce-api-go/config"
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
 	"github.com/gitKashish/ecommerce-api-go/types"
 	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/go-playground/validator/v10"
 )
 
 type Handler struct {
-	store types.ProductStore
+	store            types.ProductStore
+	userStore        types.UserStore
+	ledgerStore      types.StockLedgerStore
+	idempotencyStore types.IdempotencyStore
+	// Search index, updated after every successful product write.
+	index      types.ProductIndex
+	transactor types.Transactor
 }
 
-func NewHandler(store types.ProductStore) *Handler {
-	return &Handler{store: store}
+func NewHandler(store types.ProductStore, userStore types.UserStore, ledgerStore types.StockLedgerStore, idempotencyStore types.IdempotencyStore, index types.ProductIndex, transactor types.Transactor) *Handler {
+	return &Handler{store: store, userStore: userStore, ledgerStore: ledgerStore, idempotencyStore: idempotencyStore, index: index, transactor: transactor}
 }
 
 func (h *Handler) RegisterRoutes(router *http.ServeMux) {
 	router.HandleFunc("GET /products", h.handleGetProducts)
+	router.HandleFunc("GET /products/{id}", h.handleGetProduct)
+	router.HandleFunc("GET /categories/{slug}/products", h.handleGetProducts)
+	router.HandleFunc("GET /tags", h.handleGetTags)
+
+	// Product management, `admin` users only.
+	router.HandleFunc("POST /products", auth.WithRole(idempotency.WithIdempotencyKey(h.handleCreateProduct, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("PUT /products/{id}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleReplaceProduct, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("PATCH /products/{id}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleUpdateProduct, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("DELETE /products/{id}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleDeleteProduct, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("POST /products/import", auth.WithRole(idempotency.WithIdempotencyKeyLimit(h.handleImportProducts, h.idempotencyStore, config.Envs.MaxImportBytes), h.userStore, types.RoleAdmin))
+	router.HandleFunc("GET /products/export", auth.WithRole(h.handleExportProducts, h.userStore, types.RoleAdmin))
 }
 
-// HandlerFunc to get products (list)
+// HandlerFunc to get products (list), also serving the products of the
+// `{slug}` category (including its subcategories).
 func (h *Handler) handleGetProducts(w http.ResponseWriter, r *http.Request) {
 	// General Flow:
-	// 1. Get list of products from DB.
-	// 2. Write a JSON response.
+	// 1. Parse filtering, sorting & pagination query params.
+	// 2. Get a page of products from DB, along with facet counts if asked for.
+	// 3. Write a JSON response.
+
+	opts, err := ParseProductQueryOptions(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if slug := r.PathValue("slug"); slug != "" {
+		opts.Category = slug
+	}
+
+	facets, err := ParseFacetsParam(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Getting a page of products from DB.
+	page, err := h.store.GetProductsWithOptions(opts)
+	if errors.Is(err, types.ErrInvalidCursor) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, types.ErrCategoryNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Facets count all matching products, not just the page.
+	if facets {
+		page.Facets, err = h.store.GetProductFacets(opts)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	// Writing a JSON response, cacheable via `ETag` / `If-None-Match`.
+	utils.WriteJSONWithETag(w, r, http.StatusOK, page)
+}
+
+// HandlerFunc to get a single product (detail)
+func (h *Handler) handleGetProduct(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.store)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSONWithETag(w, r, http.StatusOK, product)
+}
+
+// HandlerFunc to get all tags in use, with their product count.
+func (h *Handler) handleGetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.store.GetTags()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONWithETag(w, r, http.StatusOK, tags)
+}
+
+// Build `types.ProductQueryOptions` from the query params of `GET /products`:
+//
+//	limit, cursor, sort, minPrice, maxPrice, inStock (bool), createdAfter (RFC 3339), name,
+//	category (slug), tag, attr (`name:value` of a variant option, repeatable)
+//
+// Shared with the search endpoints, so they filter the same way.
+func ParseProductQueryOptions(query url.Values) (types.ProductQueryOptions, error) {
+	opts := types.ProductQueryOptions{
+		Cursor:       query.Get("cursor"),
+		Sort:         query.Get("sort"),
+		NameContains: query.Get("name"),
+		Category:     query.Get("category"),
+		Tag:          strings.ToLower(strings.TrimSpace(query.Get("tag"))),
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return opts, fmt.Errorf("invalid limit %q", v)
+		}
+		opts.Limit = limit
+	}
+
+	switch opts.Sort {
+	case "", types.ProductSortNewest, types.ProductSortOldest,
+		types.ProductSortPriceAsc, types.ProductSortPriceDesc,
+		types.ProductSortNameAsc, types.ProductSortNameDesc:
+	default:
+		return opts, fmt.Errorf("invalid sort %q", opts.Sort)
+	}
+
+	for name, dst := range map[string]**types.Money{"minPrice": &opts.MinPrice, "maxPrice": &opts.MaxPrice} {
+		if v := query.Get(name); v != "" {
+			price, err := types.ParseMoney(v)
+			if err != nil || price.Amount < 0 {
+				return opts, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*dst = &price
+		}
+	}
+	if opts.MinPrice != nil && opts.MaxPrice != nil && opts.MinPrice.Amount > opts.MaxPrice.Amount {
+		return opts, fmt.Errorf("minPrice must not be greater than maxPrice")
+	}
+
+	if v := query.Get("inStock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid inStock %q", v)
+		}
+		opts.InStockOnly = inStock
+	}
+
+	if v := query.Get("createdAfter"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid createdAfter %q, expected RFC 3339 timestamp", v)
+		}
+		opts.CreatedAfter = &t
+	}
+
+	for _, v := range query["attr"] {
+		name, value, ok := strings.Cut(v, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			return opts, fmt.Errorf("invalid attr %q, expected name:value", v)
+		}
+		if opts.Attributes == nil {
+			opts.Attributes = make(map[string]string)
+		}
+		opts.Attributes[name] = value
+	}
+
+	return opts, nil
+}
+
+// Whether the `facets` (bool) query param asks for facet counts.
+func ParseFacetsParam(query url.Values) (bool, error) {
+	v := query.Get("facets")
+	if v == "" {
+		return false, nil
+	}
+
+	facets, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid facets %q", v)
+	}
+	return facets, nil
+}
+
+// HandlerFunc to create a new product (admin).
+func (h *Handler) handleCreateProduct(w http.ResponseWriter, r *http.Request) {
+	// General Flow:
+	// 1. Parse & validate payload.
+	// 2. Insert product record in DB with its categories & tags, along with an
+	//    `adjustment` movement for its initial stock.
+	// 3. Respond with the created product & http.StatusCreated.
+
+	var payload types.RegisterProductPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	product := types.Product{
+		SKU:         payload.SKU,
+		Name:        payload.Name,
+		Description: payload.Description,
+		Image:       payload.Image,
+		Price:       payload.Price,
+		CategoryIDs: payload.CategoryIDs,
+		Tags:        normalizeTags(payload.Tags),
+	}
+
+	var id int
+	err := h.transactor.WithTx(r.Context(), func(tx *sql.Tx) error {
+		var err error
+		id, err = h.createProductTx(tx, product, payload.Quantity, types.StockMovement{
+			Type:    types.StockMovementAdjustment,
+			ActorID: auth.GetUseIDFromContext(r.Context()),
+			Reason:  "initial stock",
+		})
+		return err
+	})
+	if errors.Is(err, types.ErrCategoryNotFound) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, types.ErrProductSKUTaken) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Reading it back to respond with DB generated fields (createdAt).
+	created, err := h.store.GetProductByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.index.IndexProduct(*created)
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// HandlerFunc to replace all fields of a product (admin).
+func (h *Handler) handleReplaceProduct(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.store)
+	if !ok {
+		return
+	}
 
-	// Getting a list of products from DB.
-	products, err := h.store.GetProducts()
+	var payload types.RegisterProductPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	if payload.SKU != "" {
+		product.SKU = payload.SKU
+	}
+	product.Name = payload.Name
+	product.Description = payload.Description
+	product.Image = payload.Image
+	product.Price = payload.Price
+	product.CategoryIDs = payload.CategoryIDs
+	product.Tags = normalizeTags(payload.Tags)
+	if product.CategoryIDs == nil {
+		product.CategoryIDs = []int{}
+	}
+
+	err := h.saveProduct(r.Context(), product, &payload.Quantity, stockSetBy(r))
+	if errors.Is(err, types.ErrOutOfStock) || errors.Is(err, types.ErrProductSKUTaken) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if errors.Is(err, types.ErrCategoryNotFound) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
+	h.index.IndexProduct(*product)
+
+	utils.WriteJSON(w, http.StatusOK, product)
+}
+
+// HandlerFunc to update only the given fields of a product (admin).
+func (h *Handler) handleUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.store)
+	if !ok {
+		return
+	}
+
+	var payload types.UpdateProductPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	// Applying only the fields present in the payload.
+	if payload.SKU != nil {
+		product.SKU = *payload.SKU
+	}
+	if payload.Name != nil {
+		product.Name = *payload.Name
+	}
+	if payload.Description != nil {
+		product.Description = *payload.Description
+	}
+	if payload.Image != nil {
+		product.Image = *payload.Image
+	}
+	if payload.Price != nil {
+		product.Price = *payload.Price
+	}
+	if payload.CategoryIDs != nil {
+		product.CategoryIDs = *payload.CategoryIDs
+	}
+	if payload.Tags != nil {
+		product.Tags = normalizeTags(*payload.Tags)
+	}
+
+	err := h.saveProduct(r.Context(), product, payload.Quantity, stockSetBy(r))
+	if errors.Is(err, types.ErrOutOfStock) || errors.Is(err, types.ErrProductSKUTaken) {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+	if errors.Is(err, types.ErrCategoryNotFound) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.index.IndexProduct(*product)
+
+	utils.WriteJSON(w, http.StatusOK, product)
+}
+
+// Insert the product with its categories & tags and, if `quantity` isn't 0,
+// a `movement` (type, actor & reason) for its initial stock, as part of
+// transaction `tx`. Returns the product's ID.
+func (h *Handler) createProductTx(tx *sql.Tx, product types.Product, quantity int, movement types.StockMovement) (int, error) {
+	id, err := h.store.RegisterProductTx(tx, product)
+	if err != nil {
+		return 0, err
+	}
+	if err := h.store.SetProductCategoriesTx(tx, id, product.CategoryIDs); err != nil {
+		return 0, err
+	}
+	if err := h.store.SetProductTagsTx(tx, id, product.Tags); err != nil {
+		return 0, err
+	}
+
+	if quantity == 0 {
+		return id, nil
+	}
+	movement.ProductID = id
+	movement.Delta = quantity
+	_, err = h.ledgerStore.RecordStockMovementTx(tx, movement)
+	return id, err
+}
+
+// Movement recording a stock change made through the product endpoints.
+func stockSetBy(r *http.Request) types.StockMovement {
+	return types.StockMovement{
+		Type:    types.StockMovementAdjustment,
+		ActorID: auth.GetUseIDFromContext(r.Context()),
+		Reason:  "stock set by product update",
+	}
+}
+
+// Save the product's fields, categories & tags and, if `quantity` is given,
+// set its stock to it with a `movement` (type, actor & reason) at the default
+// warehouse, in a single transaction. Returns `types.ErrOutOfStock` if the
+// default warehouse doesn't hold enough to lower the stock,
+// `types.ErrCategoryNotFound` if one of its categories doesn't exist and
+// `types.ErrProductSKUTaken` if another product has its SKU.
+// The product's `Quantity` & `Available` are updated to match.
+func (h *Handler) saveProduct(ctx context.Context, product *types.Product, quantity *int, movement types.StockMovement) error {
+	return h.transactor.WithTx(ctx, func(tx *sql.Tx) error {
+		return h.saveProductTx(tx, product, quantity, movement)
+	})
+}
+
+// Same as `saveProduct` but as part of transaction `tx`.
+func (h *Handler) saveProductTx(tx *sql.Tx, product *types.Product, quantity *int, movement types.StockMovement) error {
+	if err := h.store.UpdateProductTx(tx, *product); err != nil {
+		return err
+	}
+	if err := h.store.SetProductCategoriesTx(tx, product.ID, product.CategoryIDs); err != nil {
+		return err
+	}
+	if err := h.store.SetProductTagsTx(tx, product.ID, product.Tags); err != nil {
+		return err
+	}
+	if quantity == nil {
+		return nil
+	}
+
+	// The delta is worked out from the locked row, sales may have happened
+	// since the product was read.
+	locked, err := h.store.GetProductByIDsForUpdate(tx, []int{product.ID})
+	if err != nil {
+		return err
+	}
+	if len(locked) == 0 {
+		return types.ErrProductNotFound
+	}
+
+	delta := *quantity - locked[0].Quantity
+	if delta != 0 {
+		movement.ProductID = product.ID
+		movement.Delta = delta
+		if _, err := h.ledgerStore.RecordStockMovementTx(tx, movement); err != nil {
+			return err
+		}
+	}
+
+	product.Quantity = *quantity
+	product.Available = max(locked[0].Available+delta, 0)
+	return nil
+}
+
+// HandlerFunc to delete a product (admin).
+func (h *Handler) handleDeleteProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id %q", r.PathValue("id")))
+		return
+	}
+
+	err = h.store.DeleteProduct(id)
+	switch {
+	case errors.Is(err, types.ErrProductNotFound):
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	case errors.Is(err, types.ErrProductInUse):
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.index.RemoveProduct(id)
 
-	// Writing a JSON response.
-	utils.WriteJSON(w, http.StatusOK, products)
+	w.WriteHeader(http.StatusNoContent)
 }
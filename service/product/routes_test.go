@@ -0,0 +1,400 @@
+package product
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestProductAdminHandlers(t *testing.T) {
+	userStore := &mockUserStore{users: map[int]*types.User{
+		1: {ID: 1, Role: types.RoleCustomer},
+		2: {ID: 2, Role: types.RoleAdmin},
+	}}
+	productStore := &mockProductStore{products: map[int]types.Product{}}
+	ledgerStore := &mockLedgerStore{products: productStore}
+	index := &mockProductIndex{indexed: map[int]types.Product{}}
+	handler := NewHandler(productStore, userStore, ledgerStore, nil, index, &mockTransactor{})
+
+	router := http.NewServeMux()
+	handler.RegisterRoutes(router)
+
+	send := func(method, path, body string, userID int) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := auth.CreateJWT(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	product := `{"name":"pen","description":"blue pen","image":"pen.png","price":1.5,"quantity":10,"categoryIDs":[3],"tags":[" Office","office","ink"]}`
+
+	t.Run("should forbid customers from creating products", func(t *testing.T) {
+		rr := send(http.MethodPost, "/products", product, 1)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should create the product for admins", func(t *testing.T) {
+		rr := send(http.MethodPost, "/products", product, 2)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+		if m := ledgerStore.movements; len(m) != 1 || m[0].Delta != 10 || m[0].ActorID != 2 {
+			t.Errorf("expected the initial stock as a movement, got %+v", m)
+		}
+		if p := productStore.products[1]; len(p.CategoryIDs) != 1 || p.CategoryIDs[0] != 3 || len(p.Tags) != 2 || p.Tags[0] != "ink" || p.Tags[1] != "office" {
+			t.Errorf("expected the product's categories & normalized tags, got %+v", p)
+		}
+		if _, ok := index.indexed[1]; !ok {
+			t.Error("expected the product to be indexed for search")
+		}
+	})
+
+	t.Run("should reject unknown categories", func(t *testing.T) {
+		rr := send(http.MethodPatch, "/products/1", `{"categoryIDs":[42]}`, 2)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should only update given fields on patch", func(t *testing.T) {
+		rr := send(http.MethodPatch, "/products/1", `{"quantity":3}`, 2)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if p := productStore.products[1]; p.Quantity != 3 || p.Name != "pen" || len(p.Tags) != 2 {
+			t.Errorf("unexpected product after patch: %+v", p)
+		}
+		if m := ledgerStore.movements[len(ledgerStore.movements)-1]; m.Type != types.StockMovementAdjustment || m.Delta != -7 {
+			t.Errorf("expected an adjustment of -7, got %+v", m)
+		}
+		if p := index.indexed[1]; p.Quantity != 3 {
+			t.Errorf("expected the patched product to be reindexed, got %+v", p)
+		}
+	})
+
+	t.Run("should keep the sku when replacing without one", func(t *testing.T) {
+		rr := send(http.MethodPut, "/products/1", `{"name":"pen","description":"red pen","image":"pen.png","price":2,"quantity":3}`, 2)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if p := productStore.products[1]; p.SKU != types.DefaultProductSKU(1) || p.Description != "red pen" {
+			t.Errorf("expected the default sku to be kept, got %+v", p)
+		}
+
+		if rr := send(http.MethodPatch, "/products/1", `{"sku":""}`, 2); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for clearing the sku, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should remove deleted products from the index", func(t *testing.T) {
+		rr := send(http.MethodDelete, "/products/1", "", 2)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		if _, ok := index.indexed[1]; ok {
+			t.Error("expected the product to be removed from the index")
+		}
+	})
+
+	t.Run("should return not found for unknown products", func(t *testing.T) {
+		rr := send(http.MethodDelete, "/products/42", "", 2)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+type mockUserStore struct {
+	users map[int]*types.User
+}
+
+func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
+	return nil, nil
+}
+
+func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
+	return m.users[id], nil
+}
+
+func (m *mockUserStore) CreateUser(user types.User) error {
+	return nil
+}
+
+type mockProductStore struct {
+	types.ProductStore
+	products  map[int]types.Product
+	facetsFor types.ProductQueryOptions
+}
+
+func (m *mockProductStore) GetProductFacets(opts types.ProductQueryOptions) (*types.ProductFacets, error) {
+	m.facetsFor = opts
+	return &types.ProductFacets{Availability: types.AvailabilityFacet{InStock: 1}}, nil
+}
+
+// Every product on a single page, by ID.
+func (m *mockProductStore) GetProductsWithOptions(opts types.ProductQueryOptions) (*types.ProductPage, error) {
+	if opts.Category != "" && opts.Category != "stationery" {
+		return nil, types.ErrCategoryNotFound
+	}
+
+	page := &types.ProductPage{Products: []types.Product{}}
+	for id := 1; len(page.Products) < len(m.products); id++ {
+		if p, ok := m.products[id]; ok {
+			page.Products = append(page.Products, p)
+		}
+	}
+	return page, nil
+}
+
+func (m *mockProductStore) GetProductBySKU(sku string) (*types.Product, error) {
+	for _, p := range m.products {
+		if p.SKU == sku {
+			return &p, nil
+		}
+	}
+	return nil, types.ErrProductNotFound
+}
+
+func (m *mockProductStore) GetProductByID(id int) (*types.Product, error) {
+	p, ok := m.products[id]
+	if !ok {
+		return nil, types.ErrProductNotFound
+	}
+	return &p, nil
+}
+
+func (m *mockProductStore) RegisterProduct(p types.Product) (int, error) {
+	p.ID = len(m.products) + 1
+	if p.SKU == "" {
+		p.SKU = types.DefaultProductSKU(p.ID)
+	}
+	m.products[p.ID] = p
+	return p.ID, nil
+}
+
+func (m *mockProductStore) RegisterProductTx(tx *sql.Tx, p types.Product) (int, error) {
+	return m.RegisterProduct(p)
+}
+
+// Like the store, the quantity, categories & tags are left alone.
+func (m *mockProductStore) UpdateProductTx(tx *sql.Tx, p types.Product) error {
+	p.Quantity = m.products[p.ID].Quantity
+	p.CategoryIDs = m.products[p.ID].CategoryIDs
+	p.Tags = m.products[p.ID].Tags
+	m.products[p.ID] = p
+	return nil
+}
+
+// Only category 3 exists.
+func (m *mockProductStore) SetProductCategoriesTx(tx *sql.Tx, productID int, categoryIDs []int) error {
+	for _, id := range categoryIDs {
+		if id != 3 {
+			return types.ErrCategoryNotFound
+		}
+	}
+	p := m.products[productID]
+	p.CategoryIDs = categoryIDs
+	m.products[productID] = p
+	return nil
+}
+
+func (m *mockProductStore) SetProductTagsTx(tx *sql.Tx, productID int, tags []string) error {
+	p := m.products[productID]
+	p.Tags = tags
+	m.products[productID] = p
+	return nil
+}
+
+func (m *mockProductStore) GetProductByIDsForUpdate(tx *sql.Tx, ids []int) ([]types.Product, error) {
+	products := []types.Product{}
+	for _, id := range ids {
+		if p, ok := m.products[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+// Applies movements to the mocked products' quantities.
+type mockLedgerStore struct {
+	types.StockLedgerStore
+	products  *mockProductStore
+	movements []types.StockMovement
+}
+
+func (m *mockLedgerStore) RecordStockMovementTx(tx *sql.Tx, movement types.StockMovement) (*types.StockMovement, error) {
+	p := m.products.products[movement.ProductID]
+	p.Quantity += movement.Delta
+	m.products.products[p.ID] = p
+
+	movement.Balance = p.Quantity
+	m.movements = append(m.movements, movement)
+	return &movement, nil
+}
+
+// Records the products handed to the search index.
+type mockProductIndex struct {
+	indexed map[int]types.Product
+}
+
+func (m *mockProductIndex) IndexProduct(p types.Product) {
+	m.indexed[p.ID] = p
+}
+
+func (m *mockProductIndex) RemoveProduct(id int) {
+	delete(m.indexed, id)
+}
+
+type mockTransactor struct{}
+
+func (m *mockTransactor) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return fn(nil)
+}
+
+func (m *mockProductStore) DeleteProduct(id int) error {
+	if _, ok := m.products[id]; !ok {
+		return types.ErrProductNotFound
+	}
+	delete(m.products, id)
+	return nil
+}
+
+func TestParseProductQueryOptions(t *testing.T) {
+	t.Run("should parse all filters", func(t *testing.T) {
+		query, _ := url.ParseQuery("limit=5&sort=price_asc&minPrice=1.5&maxPrice=10&inStock=true&createdAfter=2024-06-01T00:00:00Z&name=pen&category=stationery&tag=Office&attr=Color:Red&attr=Size:+M")
+
+		opts, err := ParseProductQueryOptions(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opts.Limit != 5 || opts.Sort != types.ProductSortPriceAsc || opts.MinPrice.Amount != 150 || opts.MaxPrice.Amount != 1000 ||
+			!opts.InStockOnly || opts.CreatedAfter == nil || opts.NameContains != "pen" || opts.Category != "stationery" || opts.Tag != "office" ||
+			opts.Attributes["Color"] != "Red" || opts.Attributes["Size"] != "M" {
+			t.Errorf("unexpected options: %+v", opts)
+		}
+	})
+
+	for _, raw := range []string{"limit=0", "sort=random", "minPrice=abc", "minPrice=5&maxPrice=1", "inStock=maybe", "createdAfter=yesterday", "attr=Color", "attr=:Red"} {
+		t.Run("should reject "+raw, func(t *testing.T) {
+			query, _ := url.ParseQuery(raw)
+
+			if _, err := ParseProductQueryOptions(query); err == nil {
+				t.Errorf("expected error for %q", raw)
+			}
+		})
+	}
+}
+
+func TestProductDetailHandler(t *testing.T) {
+	productStore := &mockProductStore{products: map[int]types.Product{
+		1: {ID: 1, Name: "pen", Price: types.NewMoney(150), Quantity: 10},
+	}}
+	handler := NewHandler(productStore, nil, nil, nil, nil, nil)
+
+	router := http.NewServeMux()
+	handler.RegisterRoutes(router)
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should return not found for unknown products", func(t *testing.T) {
+		rr := get("/products/42", "")
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should return not found for unknown categories", func(t *testing.T) {
+		if rr := get("/categories/stationery/products", ""); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if rr := get("/categories/garden/products", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should only include facets when asked for", func(t *testing.T) {
+		if rr := get("/products", ""); strings.Contains(rr.Body.String(), `"facets"`) {
+			t.Errorf("expected no facets, got %s", rr.Body.String())
+		}
+
+		rr := get("/products?facets=true&tag=ink", "")
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"inStock":1`) {
+			t.Errorf("expected facets, got %d with %s", rr.Code, rr.Body.String())
+		}
+		if productStore.facetsFor.Tag != "ink" {
+			t.Errorf("expected facets for the list's filters, got %+v", productStore.facetsFor)
+		}
+		if rr := get("/products?facets=maybe", ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should return not modified for a matching etag", func(t *testing.T) {
+		rr := get("/products/1", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		etag := rr.Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("expected an ETag header")
+		}
+
+		rr = get("/products/1", etag)
+		if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+			t.Errorf("expected empty %d response, got %d with %q", http.StatusNotModified, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should return the product once it changed", func(t *testing.T) {
+		etag := get("/products/1", "").Header().Get("ETag")
+
+		productStore.products[1] = types.Product{ID: 1, Name: "pen", Price: types.NewMoney(200), Quantity: 10}
+
+		rr := get("/products/1", etag)
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+}
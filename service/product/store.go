@@ -2,10 +2,17 @@ package product
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/gitKashish/ecommerce-api-go/db"
 	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/go-sql-driver/mysql"
 )
 
 type Store struct {
@@ -16,13 +23,21 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
+// Quantity of a product held by active reservations, the `?` takes the current time.
+const reservedQuantity = "COALESCE((SELECT SUM(r.quantity) FROM stock_reservations r WHERE r.productId = products.id AND r.expiresAt > ?), 0)"
+
+// Product columns followed by `reservedQuantity` (see `scanRowIntoProduct()`).
+const productColumns = "products.*, " + reservedQuantity
+
 // Get a list of products currently in the inventory.
 func (s *Store) GetProducts() ([]types.Product, error) {
-	rows, err := s.db.Query("SELECT * FROM products") // SELECT Query.
+	rows, err := s.db.Query("SELECT "+productColumns+" FROM products", time.Now()) // SELECT Query.
 	if err != nil {
 		return nil, err
 	}
 
+	defer rows.Close()
+
 	products := make([]types.Product, 0)
 	for rows.Next() {
 		// transforming fetched record(row) into `types.Product`.
@@ -32,30 +47,307 @@ func (s *Store) GetProducts() ([]types.Product, error) {
 		}
 		products = append(products, *p)
 	}
-	return products, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return products, loadTaxonomy(s.db, products)
+}
+
+// Page size limits for `GetProductsWithOptions()`.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Sort column & direction for each `types.ProductSort*` option.
+// Whitelisted here, user input never ends up in the query text.
+var productSorts = map[string]struct {
+	column string
+	desc   bool
+}{
+	types.ProductSortNewest:    {"createdAt", true},
+	types.ProductSortOldest:    {"createdAt", false},
+	types.ProductSortPriceAsc:  {"price", false},
+	types.ProductSortPriceDesc: {"price", true},
+	types.ProductSortNameAsc:   {"name", false},
+	types.ProductSortNameDesc:  {"name", true},
+}
+
+// Position of the last product of a page.
+// `Value` is that product's sort column value, `ID` breaks ties.
+type productCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// Get a filtered & sorted page of products along with the total match count.
+// Uses keyset pagination: the cursor points after the last returned product,
+// so pages stay stable while products are being added.
+func (s *Store) GetProductsWithOptions(opts types.ProductQueryOptions) (*types.ProductPage, error) {
+	if opts.Sort == "" {
+		opts.Sort = types.ProductSortNewest
+	}
+	sort, ok := productSorts[opts.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", opts.Sort)
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultPageSize
+	}
+	opts.Limit = min(opts.Limit, MaxPageSize)
+
+	if err := s.checkCategoryFilter(opts); err != nil {
+		return nil, err
+	}
+
+	where, args := productFilters(opts)
+
+	// Total ignores the cursor, it counts every product matching the filters.
+	var total int
+	countQuery := "SELECT COUNT(*) FROM products" + whereClause(where)
+	if err := s.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	if opts.Cursor != "" {
+		cursor, err := decodeProductCursor(opts.Cursor, opts.Sort)
+		if err != nil {
+			return nil, err
+		}
+
+		// (column, id) strictly after the cursor in the sort direction.
+		op := ">"
+		if sort.desc {
+			op = "<"
+		}
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", sort.column, op))
+		value, err := cursorValue(sort.column, cursor.Value)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value, value, cursor.ID)
+	}
+
+	direction := "ASC"
+	if sort.desc {
+		direction = "DESC"
+	}
+	// Fetching one extra row to know if there is a next page.
+	query := fmt.Sprintf("SELECT %s FROM products%s ORDER BY %s %s, id %s LIMIT ?", productColumns, whereClause(where), sort.column, direction, direction)
+	args = append([]any{time.Now()}, args...)
+	args = append(args, opts.Limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]types.Product, 0, opts.Limit)
+	for rows.Next() {
+		p, err := scanRowIntoProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &types.ProductPage{Products: products, Total: total}
+	if len(products) > opts.Limit {
+		page.Products = products[:opts.Limit]
+		page.NextCursor = encodeProductCursor(opts.Sort, sort.column, page.Products[opts.Limit-1])
+	}
+
+	return page, loadTaxonomy(s.db, page.Products)
+}
+
+// SQL conditions (joined with AND) & their args for the filters in `opts`.
+func productFilters(opts types.ProductQueryOptions) ([]string, []any) {
+	where := []string{}
+	args := []any{}
+
+	if opts.MinPrice != nil {
+		where = append(where, "price >= ?")
+		args = append(args, *opts.MinPrice)
+	}
+	if opts.MaxPrice != nil {
+		where = append(where, "price <= ?")
+		args = append(args, *opts.MaxPrice)
+	}
+	if opts.InStockOnly {
+		// Available like `types.Product.Available`, reservations aside.
+		where = append(where, "quantity > "+reservedQuantity)
+		args = append(args, time.Now())
+	}
+	if opts.CreatedAfter != nil {
+		where = append(where, "createdAt > ?")
+		args = append(args, *opts.CreatedAfter)
+	}
+	if opts.NameContains != "" {
+		// Escaping LIKE wildcards so they match literally.
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(opts.NameContains)
+		where = append(where, "name LIKE ?")
+		args = append(args, "%"+escaped+"%")
+	}
+	if opts.Category != "" {
+		// Products in the category or any category below it.
+		where = append(where, `id IN (SELECT pc.productId FROM product_categories pc WHERE pc.categoryId IN (
+			WITH RECURSIVE tree (id) AS (
+				SELECT id FROM categories WHERE slug = ?
+				UNION ALL
+				SELECT c.id FROM categories c JOIN tree ON c.parentId = tree.id
+			)
+			SELECT id FROM tree))`)
+		args = append(args, opts.Category)
+	}
+	if opts.Tag != "" {
+		where = append(where, "id IN (SELECT productId FROM product_tags WHERE tag = ?)")
+		args = append(args, opts.Tag)
+	}
+	if len(opts.Attributes) > 0 {
+		// A single active variant has to have all the values, sorted by name
+		// to keep the query text stable.
+		names := make([]string, 0, len(opts.Attributes))
+		for name := range opts.Attributes {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		pairs := make([]string, len(names))
+		for i, name := range names {
+			pairs[i] = "(o.name = ? AND vov.value = ?)"
+			args = append(args, name, opts.Attributes[name])
+		}
+		where = append(where, fmt.Sprintf(`id IN (SELECT v.productId FROM product_variants v WHERE v.active AND (
+			SELECT COUNT(*) FROM variant_option_values vov JOIN product_options o ON o.id = vov.optionId
+			WHERE vov.variantId = v.id AND (%s)) = ?)`, strings.Join(pairs, " OR ")))
+		args = append(args, len(names))
+	}
+	if opts.IDs != nil {
+		// Sent as a single JSON array: search passes every match, which can be
+		// more than the 65,535 placeholders a statement may have.
+		ids, _ := json.Marshal(opts.IDs)
+		where = append(where, "id IN (SELECT ids.id FROM JSON_TABLE(?, '$[*]' COLUMNS (id INT UNSIGNED PATH '$')) AS ids)")
+		args = append(args, string(ids))
+	}
+
+	return where, args
+}
+
+// Returns `types.ErrCategoryNotFound` if `opts` filter by a category that doesn't exist.
+func (s *Store) checkCategoryFilter(opts types.ProductQueryOptions) error {
+	if opts.Category == "" {
+		return nil
+	}
+
+	var exists bool
+	if err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM categories WHERE slug = ?)", opts.Category).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return types.ErrCategoryNotFound
+	}
+	return nil
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func encodeProductCursor(sort, column string, last types.Product) string {
+	c := productCursor{Sort: sort, ID: last.ID}
+	switch column {
+	case "createdAt":
+		c.Value = last.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "price":
+		c.Value = last.Price.String()
+	case "name":
+		c.Value = last.Name
+	}
+
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Cursors are opaque to clients and only valid for the sort they were issued for.
+func decodeProductCursor(encoded, sort string) (*productCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, types.ErrInvalidCursor
+	}
+
+	c := new(productCursor)
+	if err := json.Unmarshal(b, c); err != nil || c.Sort != sort {
+		return nil, types.ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// Convert a cursor value back to the column's Go type for the query args.
+func cursorValue(column, value string) (any, error) {
+	switch column {
+	case "createdAt":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, types.ErrInvalidCursor
+		}
+		return t, nil
+	case "price":
+		price, err := types.ParseMoney(value)
+		if err != nil {
+			return nil, types.ErrInvalidCursor
+		}
+		return price, nil
+	default:
+		return value, nil
+	}
 }
 
 // Return a list `types.Product` from a list of Product IDs.
 func (s *Store) GetProductByIDs(productIDs []int) ([]types.Product, error) {
+	return getProductByIDs(s.db, productIDs, false)
+}
 
+// Same as `GetProductByIDs` but runs inside `tx` and locks the selected rows...
+// (`SELECT ... FOR UPDATE`) until the transaction commits or rolls back.
+func (s *Store) GetProductByIDsForUpdate(tx *sql.Tx, productIDs []int) ([]types.Product, error) {
+	return getProductByIDs(tx, productIDs, true)
+}
+
+func getProductByIDs(q db.Querier, productIDs []int, forUpdate bool) ([]types.Product, error) {
 	// Creating a query to select product records...
 	// of products with given product ID.
-	placeholders := strings.Repeat(", ?", len(productIDs)-1) // Products ID args placeholder.
-	query := fmt.Sprintf("SELECT * FROM products WHERE id IN (?%s)", placeholders)
-
-	// Convert productIDs to []interface{} (any interface)
-	// Creating a list of product IDs.
-	args := make([]interface{}, len(productIDs))
-	for i, v := range productIDs {
-		args[i] = v
+	placeholders, ids, ok := db.InList(productIDs) // Products ID args placeholder.
+	if !ok {
+		return []types.Product{}, nil
+	}
+	query := fmt.Sprintf("SELECT %s FROM products WHERE id IN (%s)", productColumns, placeholders)
+	if forUpdate {
+		// Locking rows in a consistent (id) order so concurrent checkouts...
+		// with overlapping carts queue up instead of deadlocking.
+		query += " ORDER BY id FOR UPDATE"
 	}
 
+	// The reservations' time first, then the product IDs.
+	args := append([]any{time.Now()}, ids...)
+
 	// Spread all product IDs as arguments in SELECT Query.
 	// replacing the above mentioned `placeholder` "?"s.
-	rows, err := s.db.Query(query, args...) // Query executed. Rows returned.
+	rows, err := q.Query(query, args...) // Query executed. Rows returned.
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	products := []types.Product{} // List to store list(slice) of `types.Product`.
 
@@ -68,17 +360,230 @@ func (s *Store) GetProductByIDs(productIDs []int) ([]types.Product, error) {
 
 		products = append(products, *p)
 	}
+	// Rows are closed first, a transaction can't run another query while they are open.
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return products, nil
+	return products, loadTaxonomy(q, products)
 }
 
-// Register a new product.
-// TODO : Create endpoint with JWT auth middleware. Only `admin` access.
-func (s *Store) RegisterProduct(product types.Product) error {
-	_, err := s.db.Exec("INSERT INTO products (name, description, image, price, quantity) VALUES (?, ?, ?, ?, ?)")
+// Get a single product by its ID.
+// Returns `types.ErrProductNotFound` if there is no such product.
+func (s *Store) GetProductByID(id int) (*types.Product, error) {
+	return s.getProduct("id", id)
+}
+
+// Get a single product by its SKU.
+// Returns `types.ErrProductNotFound` if no product has the SKU.
+func (s *Store) GetProductBySKU(sku string) (*types.Product, error) {
+	return s.getProduct("sku", sku)
+}
+
+// Get the product whose `column` (unique) equals `value`.
+func (s *Store) getProduct(column string, value any) (*types.Product, error) {
+	rows, err := s.db.Query("SELECT "+productColumns+" FROM products WHERE "+column+" = ?", time.Now(), value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, types.ErrProductNotFound
+	}
+
+	product, err := scanRowIntoProduct(rows)
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	products := []types.Product{*product}
+	if err := loadTaxonomy(s.db, products); err != nil {
+		return nil, err
+	}
+	return &products[0], nil
+}
+
+// Set the categories & tags of the products, in place.
+func loadTaxonomy(q db.Querier, products []types.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(products)) // Product ID -> position in `products`.
+	args := make([]any, len(products))
+	for i := range products {
+		products[i].CategoryIDs = []int{}
+		products[i].Tags = []string{}
+		index[products[i].ID] = i
+		args[i] = products[i].ID
+	}
+	placeholders := strings.Repeat(", ?", len(products)-1)
+
+	rows, err := q.Query(fmt.Sprintf("SELECT productId, categoryId FROM product_categories WHERE productId IN (?%s) ORDER BY categoryId", placeholders), args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var productID, categoryID int
+		if err := rows.Scan(&productID, &categoryID); err != nil {
+			rows.Close()
+			return err
+		}
+		p := &products[index[productID]]
+		p.CategoryIDs = append(p.CategoryIDs, categoryID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = q.Query(fmt.Sprintf("SELECT productId, tag FROM product_tags WHERE productId IN (?%s) ORDER BY tag", placeholders), args...)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
+	for rows.Next() {
+		var productID int
+		var tag string
+		if err := rows.Scan(&productID, &tag); err != nil {
+			return err
+		}
+		p := &products[index[productID]]
+		p.Tags = append(p.Tags, tag)
+	}
+
+	return rows.Err()
+}
+
+// Replace the categories the product is listed in, as part of transaction `tx`.
+// Returns `types.ErrCategoryNotFound` if one of them doesn't exist.
+func (s *Store) SetProductCategoriesTx(tx *sql.Tx, productID int, categoryIDs []int) error {
+	if _, err := tx.Exec("DELETE FROM product_categories WHERE productId = ?", productID); err != nil {
+		return err
+	}
+
+	for _, categoryID := range categoryIDs {
+		_, err := tx.Exec("INSERT IGNORE INTO product_categories (productId, categoryId) VALUES (?, ?)", productID, categoryID)
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 { // Foreign key: no such category.
+			return fmt.Errorf("%w: %d", types.ErrCategoryNotFound, categoryID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replace the tags of the product, as part of transaction `tx`.
+// Tags are expected normalized (see `normalizeTags()`).
+func (s *Store) SetProductTagsTx(tx *sql.Tx, productID int, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM product_tags WHERE productId = ?", productID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT IGNORE INTO product_tags (productId, tag) VALUES (?, ?)", productID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get all tags in use with their product count, most used first.
+func (s *Store) GetTags() ([]types.TagCount, error) {
+	rows, err := s.db.Query("SELECT tag, COUNT(*) AS products FROM product_tags GROUP BY tag ORDER BY products DESC, tag")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]types.TagCount, 0)
+	for rows.Next() {
+		t := types.TagCount{}
+		if err := rows.Scan(&t.Tag, &t.Products); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// Lower case & trim the tags, dropping empty & repeated ones.
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	slices.Sort(normalized)
+	return normalized
+}
+
+// Register a new product and return its ID.
+// Exposed to `admin` users only (see `Handler.RegisterRoutes()`).
+// Products start without stock, it is added through the stock ledger.
+func (s *Store) RegisterProduct(product types.Product) (int, error) {
+	return registerProduct(s.db, product)
+}
+
+// Same as `RegisterProduct` but as part of transaction `tx`.
+func (s *Store) RegisterProductTx(tx *sql.Tx, product types.Product) (int, error) {
+	return registerProduct(tx, product)
+}
+
+func registerProduct(q db.Querier, product types.Product) (int, error) {
+	res, err := q.Exec("INSERT INTO products (sku, name, description, image, price, quantity) VALUES (?, ?, ?, ?, ?, 0)",
+		nullableSKU(product.SKU), product.Name, product.Description, product.Image, product.Price)
+	if err != nil {
+		return 0, mapSKUTaken(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	// The default SKU is made of the ID, known only once inserted.
+	if product.SKU == "" {
+		if _, err := q.Exec("UPDATE products SET sku = ? WHERE id = ?", types.DefaultProductSKU(int(id)), id); err != nil {
+			return 0, mapSKUTaken(err)
+		}
+	}
+
+	return int(id), nil
+}
+
+// Delete a product.
+// Products that were already ordered cannot be deleted (`order_items` references them).
+func (s *Store) DeleteProduct(id int) error {
+	res, err := s.db.Exec("DELETE FROM products WHERE id = ?", id)
+	if err != nil {
+		// ER_ROW_IS_REFERENCED_2 : foreign key constraint fails.
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1451 {
+			return types.ErrProductInUse
+		}
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return types.ErrProductNotFound
+	}
+
 	return nil
 }
 
@@ -86,6 +591,8 @@ func (s *Store) RegisterProduct(product types.Product) error {
 // Only scans first row.
 func scanRowIntoProduct(rows *sql.Rows) (*types.Product, error) {
 	product := new(types.Product)
+	var sku sql.NullString
+	var reserved int
 	err := rows.Scan(
 		&product.ID,
 		&product.Name,
@@ -94,21 +601,50 @@ func scanRowIntoProduct(rows *sql.Rows) (*types.Product, error) {
 		&product.Price,
 		&product.Quantity,
 		&product.CreatedAt,
+		&sku,
+		&reserved,
 	)
 
 	if err != nil {
 		return nil, err
 	}
+	product.SKU = sku.String
+	// Lowering stock below what is reserved doesn't cancel reservations.
+	product.Available = max(product.Quantity-reserved, 0)
 	return product, nil
 }
 
-// Update product values in DB.
+// Update product values in DB. The quantity is left alone, it only changes
+// together with a stock movement (see `inventory.Store.RecordStockMovement()`).
 func (s *Store) UpdateProduct(product types.Product) error {
-	_, err := s.db.Exec("UPDATE products SET name = ?, image = ?, description = ?, quantity = ? WHERE id = ?", product.Name, product.Image, product.Description, product.Quantity, product.ID)
+	return updateProduct(s.db, product)
+}
+
+// Update product values in DB as part of transaction `tx`.
+func (s *Store) UpdateProductTx(tx *sql.Tx, product types.Product) error {
+	return updateProduct(tx, product)
+}
+
+func updateProduct(q db.Querier, product types.Product) error {
+	_, err := q.Exec("UPDATE products SET sku = ?, name = ?, image = ?, description = ?, price = ? WHERE id = ?", nullableSKU(product.SKU), product.Name, product.Image, product.Description, product.Price, product.ID)
 
 	if err != nil {
-		return err
+		return mapSKUTaken(err)
 	}
 
 	return nil
 }
+
+// Products are only without a SKU (NULL) until `registerProduct()` sets the default one.
+func nullableSKU(sku string) sql.NullString {
+	return sql.NullString{String: sku, Valid: sku != ""}
+}
+
+// Duplicate key on the unique `sku` column.
+func mapSKUTaken(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return types.ErrProductSKUTaken
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A word of analysed text. `Start` & `End` are byte offsets of the original
+// word, used for highlighting, `Term` is its normalised (lower case, stemmed) form.
+type token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Common words carrying no meaning for relevance, left out of the index & queries.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "the": true, "this": true,
+	"to": true, "with": true,
+}
+
+// Split `text` into lower case words of letters & digits, dropping stopwords
+// and stemming the rest.
+func tokenize(text string) []token {
+	tokens := make([]token, 0)
+	for _, w := range words(text) {
+		if !stopwords[w.Term] {
+			w.Term = stem(w.Term)
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+// Split `text` into lower case words of letters & digits, as typed (no
+// stopwords dropped, no stemming).
+func words(text string) []token {
+	tokens := make([]token, 0)
+
+	start := -1
+	for i, r := range text {
+		wordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if wordRune && start < 0 {
+			start = i
+		}
+		if !wordRune && start >= 0 {
+			tokens = append(tokens, token{Term: strings.ToLower(text[start:i]), Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{Term: strings.ToLower(text[start:]), Start: start, End: len(text)})
+	}
+
+	return tokens
+}
+
+// A light suffix stripping stemmer, so "shoes", "shoe" & "running", "run"
+// end up as the same term. It doesn't try to produce real words, only to map
+// inflections of a word onto the same stem, both when indexing & querying.
+// Plurals are stripped first, then "-ing", "-ed", "-ness" & "-ment".
+func stem(word string) string {
+	// Short words & numbers (sizes, model numbers) are left alone.
+	last, _ := utf8.DecodeLastRuneInString(word)
+	if utf8.RuneCountInString(word) <= 3 || !unicode.IsLetter(last) {
+		return word
+	}
+
+	word = stemPlural(word)
+	for _, suffix := range []string{"ing", "ed", "ness", "ment"} {
+		base, ok := stripSuffix(word, suffix)
+		if !ok {
+			continue
+		}
+		// "running" -> "run", "stopped" -> "stop".
+		if (suffix == "ing" || suffix == "ed") && !hasAnySuffix(base, "ll", "ss", "zz", "ee") {
+			last, size := utf8.DecodeLastRuneInString(base)
+			if previous, _ := utf8.DecodeLastRuneInString(base[:len(base)-size]); previous == last {
+				base = base[:len(base)-size]
+			}
+		}
+		return base
+	}
+
+	return word
+}
+
+func stemPlural(word string) string {
+	if base, ok := stripSuffix(word, "ies"); ok {
+		return base + "y"
+	}
+	if base, ok := stripSuffix(word, "es"); ok {
+		// Only after sibilants ("boxes", "watches"), "shoes" loses just the "s".
+		if hasAnySuffix(base, "s", "x", "z", "ch", "sh") {
+			return base
+		}
+		return base + "e"
+	}
+	// "glass" & "cactus" keep their "s".
+	if base, ok := stripSuffix(word, "s"); ok && !hasAnySuffix(base, "s", "u", "i") {
+		return base
+	}
+	return word
+}
+
+// Strip `suffix` from `word`, only if at least 3 characters are left ("bus" isn't "bu").
+func stripSuffix(word, suffix string) (string, bool) {
+	base, ok := strings.CutSuffix(word, suffix)
+	if !ok || utf8.RuneCountInString(base) < 3 {
+		return word, false
+	}
+	return base, true
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Levenshtein distance between `a` & `b`, giving up (returning `max + 1`)
+// as soon as it exceeds `max`.
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return max + 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// Edits tolerated for a query term, longer words allow more typos.
+func maxTypos(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,89 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// Length (in bytes) of description snippets, give or take a word.
+const SnippetLength = 160
+
+// Wrap the words of `text` whose term is one of `terms` in `<mark>` tags,
+// the rest of the text is HTML escaped.
+func Highlight(text string, terms map[string]bool) string {
+	return mark(text, tokenize(text), terms)
+}
+
+// A `SnippetLength` excerpt of `text` around its first word matching `terms`,
+// highlighted like `Highlight()`. Returns "" if no word matches.
+func Snippet(text string, terms map[string]bool) string {
+	tokens := tokenize(text)
+
+	first := -1
+	for i, t := range tokens {
+		if terms[t.Term] {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	// Starting a few words before the match for context, unless the whole
+	// text fits anyway.
+	start, end := 0, len(text)
+	if len(text) > SnippetLength {
+		startToken := max(first-3, 0)
+		start = tokens[startToken].Start
+		if startToken == 0 {
+			start = 0
+		}
+		end = start + SnippetLength
+		if end >= len(text) {
+			end = len(text)
+		} else {
+			// Cutting at the end of the last whole word.
+			for i := len(tokens) - 1; i >= 0; i-- {
+				if tokens[i].End <= end && tokens[i].Start >= start {
+					end = tokens[i].End
+					break
+				}
+			}
+		}
+	}
+
+	excerpt := make([]token, 0)
+	for _, t := range tokens {
+		if t.Start >= start && t.End <= end {
+			excerpt = append(excerpt, token{Term: t.Term, Start: t.Start - start, End: t.End - start})
+		}
+	}
+
+	snippet := mark(text[start:end], excerpt, terms)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(text) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+func mark(text string, tokens []token, terms map[string]bool) string {
+	var b strings.Builder
+	last := 0
+	for _, t := range tokens {
+		if !terms[t.Term] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:t.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[t.Start:t.End]))
+		b.WriteString("</mark>")
+		last = t.End
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+
+	return b.String()
+}
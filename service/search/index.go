@@ -0,0 +1,280 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Indexed fields of a product & their weight, a match in the name counts
+// three times as much as one in the description.
+const (
+	fieldName = iota
+	fieldDescription
+	numFields
+)
+
+var fieldBoosts = [numFields]float64{fieldName: 3, fieldDescription: 1}
+
+// BM25 parameters: `k1` caps the effect of repeated words, `b` how much
+// long fields are penalised.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Matching a query word through a typo (or a shorter prefix) scores less than an exact match.
+const (
+	typoPenalty   = 0.5
+	prefixPenalty = 0.7
+)
+
+// Occurrences of a term in each field of a product.
+type posting [numFields]int
+
+type document struct {
+	lengths [numFields]int
+	terms   []string
+	// The name as is & its distinct words, for suggestions.
+	name      string
+	nameWords []string
+}
+
+// An in memory inverted index of product names & descriptions, ranked with
+// BM25. It is safe for concurrent use, searches only wait for writes.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[int]*document
+	postings map[string]map[int]*posting
+	// Sum of each field's length over all products, for the average in BM25.
+	totalLengths [numFields]int
+	// Products by the (unstemmed) words of their name, the words are also
+	// kept sorted to find those starting with a prefix (see `Suggest()`).
+	nameWords   map[string]map[int]bool
+	sortedWords []string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:      make(map[int]*document),
+		postings:  make(map[string]map[int]*posting),
+		nameWords: make(map[string]map[int]bool),
+	}
+}
+
+// A product matching a query, `Terms` are the index terms it matched by,
+// used for highlighting (see `Highlight()`).
+type Match struct {
+	ProductID int
+	Score     float64
+	Terms     map[string]bool
+}
+
+// Replace the whole index with `products`.
+func (idx *Index) Rebuild(products []types.Product) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.docs = make(map[int]*document, len(products))
+	idx.postings = make(map[string]map[int]*posting)
+	idx.totalLengths = [numFields]int{}
+	idx.nameWords = make(map[string]map[int]bool)
+	idx.sortedWords = nil
+	for _, p := range products {
+		idx.add(p)
+	}
+}
+
+// Add a product to the index, replacing it if it was indexed already.
+func (idx *Index) IndexProduct(p types.Product) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(p.ID)
+	idx.add(p)
+}
+
+// Remove a product from the index, no-op if it isn't indexed.
+func (idx *Index) RemoveProduct(id int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+}
+
+// Number of indexed products.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.docs)
+}
+
+func (idx *Index) add(p types.Product) {
+	doc := &document{name: p.Name}
+	for field, text := range [numFields]string{fieldName: p.Name, fieldDescription: p.Description} {
+		tokens := tokenize(text)
+		doc.lengths[field] = len(tokens)
+		idx.totalLengths[field] += len(tokens)
+
+		for _, t := range tokens {
+			docs, ok := idx.postings[t.Term]
+			if !ok {
+				docs = make(map[int]*posting)
+				idx.postings[t.Term] = docs
+			}
+			post, ok := docs[p.ID]
+			if !ok {
+				post = &posting{}
+				docs[p.ID] = post
+				doc.terms = append(doc.terms, t.Term)
+			}
+			post[field]++
+		}
+	}
+
+	for _, w := range words(p.Name) {
+		ids, ok := idx.nameWords[w.Term]
+		if !ok {
+			ids = make(map[int]bool)
+			idx.nameWords[w.Term] = ids
+			i, _ := slices.BinarySearch(idx.sortedWords, w.Term)
+			idx.sortedWords = slices.Insert(idx.sortedWords, i, w.Term)
+		}
+		if !ids[p.ID] {
+			ids[p.ID] = true
+			doc.nameWords = append(doc.nameWords, w.Term)
+		}
+	}
+
+	idx.docs[p.ID] = doc
+}
+
+func (idx *Index) remove(id int) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	for field := range doc.lengths {
+		idx.totalLengths[field] -= doc.lengths[field]
+	}
+	for _, w := range doc.nameWords {
+		delete(idx.nameWords[w], id)
+		if len(idx.nameWords[w]) == 0 {
+			delete(idx.nameWords, w)
+			if i, ok := slices.BinarySearch(idx.sortedWords, w); ok {
+				idx.sortedWords = slices.Delete(idx.sortedWords, i, i+1)
+			}
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// Find the products matching any word of `query`, best match first (ties by ID).
+// Words match index terms exactly, with a few typos (see `maxTypos()`) and, for
+// the last word (still being typed), as a prefix.
+func (idx *Index) Search(query string) []Match {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	tokens := tokenize(query)
+	matches := make(map[int]*Match)
+	for i, t := range tokens {
+		// Best score of this query word per product, a product matching it
+		// through several terms (e.g. exactly & by a typo) only counts once.
+		best := make(map[int]float64)
+		for term, weight := range idx.expand(t.Term, i == len(tokens)-1) {
+			for id, score := range idx.scoreTerm(term, weight) {
+				m, ok := matches[id]
+				if !ok {
+					m = &Match{ProductID: id, Terms: make(map[string]bool)}
+					matches[id] = m
+				}
+				m.Terms[term] = true
+				best[id] = max(best[id], score)
+			}
+		}
+		for id, score := range best {
+			matches[id].Score += score
+		}
+	}
+
+	results := make([]Match, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, *m)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ProductID < results[j].ProductID
+	})
+
+	return results
+}
+
+// Index terms matching the query term `q` along with their weight.
+func (idx *Index) expand(q string, prefix bool) map[string]float64 {
+	terms := make(map[string]float64)
+	if _, ok := idx.postings[q]; ok {
+		terms[q] = 1
+	}
+
+	typos := maxTypos(q)
+	if typos == 0 && !prefix {
+		return terms
+	}
+	for term := range idx.postings {
+		if term == q {
+			continue
+		}
+		if prefix && len(q) >= 2 && strings.HasPrefix(term, q) {
+			terms[term] = prefixPenalty
+			continue
+		}
+		if typos > 0 {
+			if d := editDistance(q, term, typos); d <= typos {
+				terms[term] = typoPenalty / float64(d)
+			}
+		}
+	}
+
+	return terms
+}
+
+// BM25 score of every product containing `term`, summed over the boosted fields.
+func (idx *Index) scoreTerm(term string, weight float64) map[int]float64 {
+	docs := idx.postings[term]
+	n := float64(len(idx.docs))
+	df := float64(len(docs))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+	scores := make(map[int]float64, len(docs))
+	for id, post := range docs {
+		doc := idx.docs[id]
+		var score float64
+		for field, freq := range post {
+			if freq == 0 {
+				continue
+			}
+			avgLength := float64(idx.totalLengths[field]) / n
+			tf := float64(freq)
+			norm := 1 - bm25B + bm25B*float64(doc.lengths[field])/avgLength
+			score += fieldBoosts[field] * idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+		scores[id] = score * weight
+	}
+
+	return scores
+}
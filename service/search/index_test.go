@@ -0,0 +1,178 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestStem(t *testing.T) {
+	for word, want := range map[string]string{
+		"shoes":     "shoe",
+		"shoe":      "shoe",
+		"boxes":     "box",
+		"batteries": "battery",
+		"running":   "run",
+		"glasses":   "glass",
+		"glass":     "glass",
+		"earrings":  "ear",
+		"xl":        "xl",
+		"iphone15":  "iphone15",
+		"cafés":     "café",
+		"abççed":    "abç",
+		"abもing":    "abも", // "も" is 3 bytes, the last 2 of them equal.
+	} {
+		if got := stem(word); got != want {
+			t.Errorf("expected stem(%q) to be %q, got %q", word, want, got)
+		}
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	index := NewIndex()
+	index.Rebuild([]types.Product{
+		{ID: 1, Name: "Running Shoes", Description: "Lightweight shoes for daily runs."},
+		{ID: 2, Name: "Leather Wallet", Description: "A slim wallet, pairs well with our running shoes."},
+		{ID: 3, Name: "Wireless Headphones", Description: "Noise cancelling headphones with a long lasting battery."},
+	})
+
+	ids := func(matches []Match) []int {
+		ids := make([]int, len(matches))
+		for i, m := range matches {
+			ids[i] = m.ProductID
+		}
+		return ids
+	}
+
+	t.Run("should match inflections of a word", func(t *testing.T) {
+		got := ids(index.Search("shoe"))
+		if len(got) != 2 {
+			t.Errorf("expected products 1 & 2, got %v", got)
+		}
+	})
+
+	t.Run("should rank name matches above description matches", func(t *testing.T) {
+		got := ids(index.Search("running shoes"))
+		if len(got) != 2 || got[0] != 1 {
+			t.Errorf("expected product 1 first, got %v", got)
+		}
+	})
+
+	t.Run("should tolerate typos", func(t *testing.T) {
+		got := ids(index.Search("headphnes"))
+		if len(got) != 1 || got[0] != 3 {
+			t.Errorf("expected product 3, got %v", got)
+		}
+	})
+
+	t.Run("should not tolerate typos in short words", func(t *testing.T) {
+		// "rum" is one edit away from "run".
+		if got := ids(index.Search("rum")); len(got) != 0 {
+			t.Errorf("expected no match, got %v", got)
+		}
+	})
+
+	t.Run("should match the last word as a prefix", func(t *testing.T) {
+		got := ids(index.Search("wirel"))
+		if len(got) != 1 || got[0] != 3 {
+			t.Errorf("expected product 3, got %v", got)
+		}
+	})
+
+	t.Run("should keep the index in sync with product changes", func(t *testing.T) {
+		index.IndexProduct(types.Product{ID: 2, Name: "Leather Belt", Description: "Brown leather belt."})
+		index.RemoveProduct(3)
+
+		if got := ids(index.Search("wallet")); len(got) != 0 {
+			t.Errorf("expected the old name not to match, got %v", got)
+		}
+		if got := ids(index.Search("belt")); len(got) != 1 || got[0] != 2 {
+			t.Errorf("expected product 2, got %v", got)
+		}
+		if got := ids(index.Search("headphones")); len(got) != 0 {
+			t.Errorf("expected removed products not to match, got %v", got)
+		}
+		if index.Len() != 2 {
+			t.Errorf("expected 2 indexed products, got %d", index.Len())
+		}
+	})
+}
+
+func TestIndexSuggest(t *testing.T) {
+	index := NewIndex()
+	index.Rebuild([]types.Product{
+		{ID: 1, Name: "Blue Pen"},
+		{ID: 2, Name: "Ballpoint Pen Refills"},
+		{ID: 3, Name: "Pencil"},
+		{ID: 4, Name: "Notebook"},
+	})
+
+	names := func(suggestions []types.Suggestion) []string {
+		names := make([]string, len(suggestions))
+		for i, s := range suggestions {
+			names[i] = s.Name
+		}
+		return names
+	}
+
+	t.Run("should complete the last word, names starting with it first", func(t *testing.T) {
+		got := names(index.Suggest("pen"))
+		if len(got) != 3 || got[0] != "Pencil" || got[1] != "Blue Pen" || got[2] != "Ballpoint Pen Refills" {
+			t.Errorf("unexpected suggestions %v", got)
+		}
+	})
+
+	t.Run("should require the words typed before", func(t *testing.T) {
+		got := index.Suggest("blue pe")
+		if len(got) != 1 || got[0].ProductID != 1 {
+			t.Fatalf("expected product 1, got %+v", got)
+		}
+		if want := "<mark>Blue</mark> <mark>Pe</mark>n"; got[0].Highlight != want {
+			t.Errorf("expected highlight %q, got %q", want, got[0].Highlight)
+		}
+	})
+
+	t.Run("should forget removed names", func(t *testing.T) {
+		index.IndexProduct(types.Product{ID: 4, Name: "Sketchbook"})
+
+		if got := index.Suggest("note"); len(got) != 0 {
+			t.Errorf("expected no suggestions, got %+v", got)
+		}
+		if got := names(index.Suggest("sketch")); len(got) != 1 {
+			t.Errorf("expected the new name, got %v", got)
+		}
+	})
+}
+
+func TestHighlight(t *testing.T) {
+	terms := map[string]bool{"shoe": true}
+
+	t.Run("should mark matching words & escape the rest", func(t *testing.T) {
+		got := Highlight("Shoes & Socks <new>", terms)
+		if want := "<mark>Shoes</mark> &amp; Socks &lt;new&gt;"; got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("should cut long text around the first match", func(t *testing.T) {
+		text := "This bundle contains a lot of things that are not really relevant at all, like socks, laces, a bag and many more accessories before it finally mentions the shoes we sell, which are great, comfortable, durable and cheap too."
+		got := Snippet(text, terms)
+
+		if len(got) > SnippetLength+len("……<mark></mark>") {
+			t.Errorf("expected a snippet of about %d bytes, got %d", SnippetLength, len(got))
+		}
+		if !strings.HasPrefix(got, "…") {
+			t.Errorf("expected the snippet to start with an ellipsis, got %q", got)
+		}
+		if !strings.Contains(got, "the <mark>shoes</mark> we sell") {
+			t.Errorf("expected the match in the snippet, got %q", got)
+		}
+	})
+
+	t.Run("should return no snippet without matches", func(t *testing.T) {
+		if got := Snippet("Leather wallet", terms); got != "" {
+			t.Errorf("expected no snippet, got %q", got)
+		}
+	})
+}
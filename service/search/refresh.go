@@ -0,0 +1,32 @@
+package search
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Rebuild `idx` from the stored products every `interval` until `ctx` is
+// done, picking up changes made outside the API (e.g. `cmd/catalog` imports).
+// Meant to run in the background for the lifetime of the API server.
+func RefreshIndex(ctx context.Context, idx *Index, store types.ProductStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			products, err := store.GetProducts()
+			if err != nil {
+				// Searches keep being served from the current index, retrying on the next tick.
+				log.Printf("failed to refresh the search index: %v", err)
+				continue
+			}
+			idx.Rebuild(products)
+		}
+	}
+}
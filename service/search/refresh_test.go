@@ -0,0 +1,39 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestRefreshIndex(t *testing.T) {
+	t.Run("should rebuild the index until the context is done", func(t *testing.T) {
+		idx := NewIndex()
+		store := &mockProductStore{products: []types.Product{{ID: 1, Name: "Blue pen"}, {ID: 2, Name: "Red pen"}}}
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan struct{})
+		go func() {
+			RefreshIndex(ctx, idx, store, time.Millisecond)
+			close(done)
+		}()
+
+		deadline := time.After(time.Second)
+		for idx.Len() < 2 {
+			select {
+			case <-deadline:
+				t.Fatal("expected the index to be rebuilt")
+			case <-time.After(time.Millisecond):
+			}
+		}
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected the refresh to stop")
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gitKashish/ecommerce-api-go/service/product"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+)
+
+// Page size limits for `GET /products/search`.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Suggestion count limits for `GET /products/suggest`.
+const (
+	DefaultSuggestions = 5
+	MaxSuggestions     = 20
+)
+
+type Handler struct {
+	index        *Index
+	productStore types.ProductStore
+}
+
+func NewHandler(index *Index, productStore types.ProductStore) *Handler {
+	return &Handler{index: index, productStore: productStore}
+}
+
+func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /products/search", h.handleSearchProducts)
+	router.HandleFunc("GET /products/suggest", h.handleSuggestProducts)
+}
+
+// HandlerFunc to search products by name & description, best match first.
+// Takes the same filters as `GET /products`.
+func (h *Handler) handleSearchProducts(w http.ResponseWriter, r *http.Request) {
+	// General Flow:
+	// 1. Parse the query (`q`), filtering & pagination query params (`limit`, `cursor`).
+	// 2. Rank the matching products with the search index & drop those not
+	//    passing the filters.
+	// 3. Count facets over all hits if asked for.
+	// 4. Load the page's products from DB & highlight the matched words.
+	// 5. Write a JSON response.
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing search query q"))
+		return
+	}
+
+	opts, err := product.ParseProductQueryOptions(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	facets, err := product.ParseFacetsParam(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// `limit` is validated along with the filters.
+	limit := DefaultPageSize
+	if opts.Limit > 0 {
+		limit = min(opts.Limit, MaxPageSize)
+	}
+
+	// The cursor is the number of hits already returned, results are ranked
+	// so there is no column to page by.
+	offset := 0
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		o, err := strconv.Atoi(v)
+		if err != nil || o <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, types.ErrInvalidCursor)
+			return
+		}
+		offset = o
+	}
+
+	matches := h.index.Search(query)
+	if opts.HasFilters() {
+		matches, err = filterByProduct(h.productStore, opts, matches, func(m Match) int { return m.ProductID })
+		if !writeFilterError(w, err) {
+			return
+		}
+	}
+
+	page := &types.SearchPage{Hits: []types.SearchHit{}, Total: len(matches)}
+	if facets {
+		// Counting over the hits of every page, the filters are already applied.
+		opts.IDs = make([]int, len(matches))
+		for i, m := range matches {
+			opts.IDs[i] = m.ProductID
+		}
+		page.Facets, err = h.productStore.GetProductFacets(opts)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if offset >= len(matches) {
+		utils.WriteJSON(w, http.StatusOK, page)
+		return
+	}
+	end := min(offset+limit, len(matches))
+	matches = matches[offset:end]
+	if end < page.Total {
+		page.NextCursor = strconv.Itoa(end)
+	}
+
+	// Loading current product data (price, stock) from DB, the index only
+	// holds their text.
+	ids := make([]int, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ProductID
+	}
+	products, err := h.productStore.GetProductByIDs(ids)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	byID := make(map[int]types.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	for _, m := range matches {
+		// Deleted since the search ran.
+		p, ok := byID[m.ProductID]
+		if !ok {
+			continue
+		}
+		page.Hits = append(page.Hits, types.SearchHit{
+			Product: p,
+			Score:   m.Score,
+			Highlights: types.SearchHighlights{
+				Name:        Highlight(p.Name, m.Terms),
+				Description: Snippet(p.Description, m.Terms),
+			},
+		})
+	}
+
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// HandlerFunc to autocomplete product names, see `Index.Suggest()`.
+// Takes the same filters as `GET /products`.
+func (h *Handler) handleSuggestProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing search query q"))
+		return
+	}
+
+	opts, err := product.ParseProductQueryOptions(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// `limit` is validated along with the filters.
+	limit := DefaultSuggestions
+	if opts.Limit > 0 {
+		limit = min(opts.Limit, MaxSuggestions)
+	}
+
+	suggestions := h.index.Suggest(query)
+	// Unfiltered suggestions are served from memory alone, filters take a DB query.
+	if opts.HasFilters() {
+		suggestions, err = filterByProduct(h.productStore, opts, suggestions, func(s types.Suggestion) int { return s.ProductID })
+		if !writeFilterError(w, err) {
+			return
+		}
+	}
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	utils.WriteJSON(w, http.StatusOK, suggestions)
+}
+
+// Keep the items (in order) whose product passes the filters of `opts`.
+func filterByProduct[T any](store types.ProductStore, opts types.ProductQueryOptions, items []T, productID func(T) int) ([]T, error) {
+	opts.IDs = make([]int, len(items))
+	for i, item := range items {
+		opts.IDs[i] = productID(item)
+	}
+
+	ids, err := store.GetProductIDs(opts)
+	if err != nil {
+		return nil, err
+	}
+	passing := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		passing[id] = true
+	}
+
+	filtered := make([]T, 0, len(ids))
+	for _, item := range items {
+		if passing[productID(item)] {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
+
+// Util function to write the error response of `filterByProduct()`, if any.
+// Returns true if there was no error.
+func writeFilterError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, types.ErrCategoryNotFound):
+		utils.WriteError(w, http.StatusNotFound, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestSearchHandler(t *testing.T) {
+	products := []types.Product{
+		{ID: 1, Name: "Blue Pen", Description: "A pen with blue ink."},
+		{ID: 2, Name: "Red Pen", Description: "A pen with red ink."},
+		{ID: 3, Name: "Notebook", Description: "Lined paper, goes well with a pen."},
+	}
+	index := NewIndex()
+	index.Rebuild(products)
+	productStore := &mockProductStore{products: products}
+	handler := NewHandler(index, productStore)
+
+	router := http.NewServeMux()
+	handler.RegisterRoutes(router)
+
+	get := func(path string, v any) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr
+	}
+	search := func(query string) (*httptest.ResponseRecorder, types.SearchPage) {
+		var page types.SearchPage
+		rr := get("/products/search?"+query, &page)
+		return rr, page
+	}
+
+	t.Run("should fail without a query", func(t *testing.T) {
+		if rr, _ := search("q=+"); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail for invalid cursors", func(t *testing.T) {
+		if rr, _ := search("q=pen&cursor=abc"); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should return highlighted hits, best match first", func(t *testing.T) {
+		_, page := search("q=blue+pens")
+
+		if page.Total != 3 || len(page.Hits) != 3 {
+			t.Fatalf("expected 3 hits, got %+v", page)
+		}
+		hit := page.Hits[0]
+		if hit.Product.ID != 1 || hit.Highlights.Name != "<mark>Blue</mark> <mark>Pen</mark>" {
+			t.Errorf("expected product 1 highlighted first, got %+v", hit)
+		}
+		if hit.Highlights.Description != "A <mark>pen</mark> with <mark>blue</mark> ink." {
+			t.Errorf("unexpected description highlight %q", hit.Highlights.Description)
+		}
+	})
+
+	t.Run("should apply the list filters & count facets over all hits", func(t *testing.T) {
+		_, page := search("q=pen&inStock=true&facets=true&limit=1")
+
+		if page.Total != 2 || page.Hits[0].Product.ID == 2 {
+			t.Errorf("expected product 2 filtered out, got %+v", page)
+		}
+		if page.Facets == nil || page.Facets.Availability.InStock != 2 || !productStore.facetsFor.InStockOnly {
+			t.Errorf("expected facets of both hits, got %+v", page.Facets)
+		}
+	})
+
+	t.Run("should suggest product names", func(t *testing.T) {
+		var suggestions []types.Suggestion
+		if rr := get("/products/suggest?q=re", &suggestions); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if len(suggestions) != 1 || suggestions[0].ProductID != 2 || suggestions[0].Highlight != "<mark>Re</mark>d Pen" {
+			t.Errorf("unexpected suggestions %+v", suggestions)
+		}
+
+		suggestions = nil
+		get("/products/suggest?q=pen&inStock=true&limit=1", &suggestions)
+		if len(suggestions) != 1 || suggestions[0].ProductID != 1 {
+			t.Errorf("expected only product 1, got %+v", suggestions)
+		}
+	})
+
+	t.Run("should page through the hits", func(t *testing.T) {
+		_, first := search("q=pen&limit=2")
+		if len(first.Hits) != 2 || first.NextCursor != "2" {
+			t.Fatalf("expected 2 hits & a cursor, got %+v", first)
+		}
+
+		_, second := search("q=pen&limit=2&cursor=" + first.NextCursor)
+		if len(second.Hits) != 1 || second.NextCursor != "" || second.Hits[0].Product.ID != 3 {
+			t.Errorf("expected the last hit without a cursor, got %+v", second)
+		}
+	})
+}
+
+type mockProductStore struct {
+	types.ProductStore
+	products  []types.Product
+	facetsFor types.ProductQueryOptions
+}
+
+func (m *mockProductStore) GetProducts() ([]types.Product, error) {
+	return m.products, nil
+}
+
+// Only the `inStock` filter is supported, product 2 is out of stock.
+func (m *mockProductStore) GetProductIDs(opts types.ProductQueryOptions) ([]int, error) {
+	ids := []int{}
+	for _, id := range opts.IDs {
+		if !opts.InStockOnly || id != 2 {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *mockProductStore) GetProductFacets(opts types.ProductQueryOptions) (*types.ProductFacets, error) {
+	m.facetsFor = opts
+	return &types.ProductFacets{Availability: types.AvailabilityFacet{InStock: len(opts.IDs)}}, nil
+}
+
+func (m *mockProductStore) GetProductByIDs(ids []int) ([]types.Product, error) {
+	products := []types.Product{}
+	for _, p := range m.products {
+		for _, id := range ids {
+			if p.ID == id {
+				products = append(products, p)
+			}
+		}
+	}
+	return products, nil
+}
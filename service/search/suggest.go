@@ -0,0 +1,109 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Suggest products whose name completes `query`: the last word of the query is
+// taken as the start of a word of the name, the words before it have to be in
+// the name as typed. Names starting with the query come first, then shorter
+// names. Only the index is read, no DB round trip.
+func (idx *Index) Suggest(query string) []types.Suggestion {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	typed := words(query)
+	if len(typed) == 0 {
+		return []types.Suggestion{}
+	}
+	prefix := typed[len(typed)-1].Term
+	complete := make(map[string]bool, len(typed)-1)
+	for _, w := range typed[:len(typed)-1] {
+		complete[w.Term] = true
+	}
+
+	// Products with a name word starting with the prefix, the sorted words
+	// starting with it are next to each other.
+	candidates := make(map[int]bool)
+	for i := sort.SearchStrings(idx.sortedWords, prefix); i < len(idx.sortedWords) && strings.HasPrefix(idx.sortedWords[i], prefix); i++ {
+		for id := range idx.nameWords[idx.sortedWords[i]] {
+			candidates[id] = true
+		}
+	}
+
+	suggestions := make([]types.Suggestion, 0)
+	for id := range candidates {
+		doc := idx.docs[id]
+		if !hasAllWords(doc, complete) {
+			continue
+		}
+		suggestions = append(suggestions, types.Suggestion{
+			ProductID: id,
+			Name:      doc.name,
+			Highlight: highlightPrefix(doc.name, complete, prefix),
+		})
+	}
+
+	lowerQuery := strings.ToLower(strings.TrimSpace(query))
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		aStarts := strings.HasPrefix(strings.ToLower(a.Name), lowerQuery)
+		bStarts := strings.HasPrefix(strings.ToLower(b.Name), lowerQuery)
+		if aStarts != bStarts {
+			return aStarts
+		}
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) < len(b.Name)
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ProductID < b.ProductID
+	})
+
+	return suggestions
+}
+
+func hasAllWords(doc *document, words map[string]bool) bool {
+	found := 0
+	for _, w := range doc.nameWords {
+		if words[w] {
+			found++
+		}
+	}
+	return found == len(words)
+}
+
+// Wrap the words of `name` in `complete` & the start of those beginning with
+// `prefix` in `<mark>` tags, the rest is HTML escaped.
+func highlightPrefix(name string, complete map[string]bool, prefix string) string {
+	var b strings.Builder
+	last := 0
+	for _, w := range words(name) {
+		end := w.End
+		switch {
+		case complete[w.Term]:
+		case strings.HasPrefix(w.Term, prefix):
+			// Lower casing keeps the length of most text, falling back to the
+			// whole word otherwise.
+			if len(w.Term) == w.End-w.Start {
+				end = w.Start + len(prefix)
+			}
+		default:
+			continue
+		}
+
+		b.WriteString(html.EscapeString(name[last:w.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(name[w.Start:end]))
+		b.WriteString("</mark>")
+		last = end
+	}
+	b.WriteString(html.EscapeString(name[last:]))
+
+	return b.String()
+}
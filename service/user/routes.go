@@ -1,10 +1,11 @@
 package user
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 
-	"github.com/gitKashish/ecommerce-api-go/config"
 	"github.com/gitKashish/ecommerce-api-go/service/auth"
 	"github.com/gitKashish/ecommerce-api-go/types"
 	"github.com/gitKashish/ecommerce-api-go/utils"
@@ -12,16 +13,20 @@ import (
 )
 
 type Handler struct {
-	store types.UserStore
+	store             types.UserStore
+	refreshTokenStore types.RefreshTokenStore
+	cartMerger        types.CartMerger
 }
 
-func NewHandler(store types.UserStore) *Handler {
-	return &Handler{store: store}
+func NewHandler(store types.UserStore, refreshTokenStore types.RefreshTokenStore, cartMerger types.CartMerger) *Handler {
+	return &Handler{store: store, refreshTokenStore: refreshTokenStore, cartMerger: cartMerger}
 }
 
 func (h *Handler) RegisterRoutes(router *http.ServeMux) {
 	router.HandleFunc("POST /login", h.handleLogin)
 	router.HandleFunc("POST /register", h.handleRegister)
+	router.HandleFunc("POST /refresh", h.handleRefresh)
+	router.HandleFunc("POST /logout", h.handleLogout)
 }
 
 // ---- HandlerFunc for USER LOGIN & JWT GENERATION ----
@@ -31,7 +36,8 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 	// 2. Validate payload structure.
 	// 3. Get User by email.
 	// 4. Password Verification.
-	// 5. Generate and respond with JWT token & http.StatusOK.
+	// 5. Merge the guest cart (`X-Cart-Token` header) into the user's cart.
+	// 6. Generate and respond with JWT access & refresh tokens & http.StatusOK.
 
 	var payload types.LoginUserPayload
 
@@ -67,18 +73,96 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.mergeGuestCart(r, u.ID)
+
+	// Login starts a new refresh token family.
+	refreshToken, err := auth.IssueRefreshToken(h.refreshTokenStore, u.ID, "")
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	// Generating JWT token for session authentication...
 	// if payload password is correct.
-	secret := []byte(config.Envs.JWTSecret)
-	token, err := auth.CreateJWT(secret, u.ID)
+	h.writeTokens(w, u.ID, refreshToken)
+}
+
+// ---- HandlerFunc for EXCHANGING A REFRESH TOKEN FOR NEW TOKENS ----
+func (h *Handler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	// General Flow :
+	// 1. Parse & validate payload.
+	// 2. Rotate refresh token (old one can no longer be used).
+	// 3. Respond with new JWT access & refresh tokens.
+
+	var payload types.RefreshTokenPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	// A reused token revokes its whole family, the user has to log in again.
+	userID, refreshToken, err := auth.RotateRefreshToken(h.refreshTokenStore, payload.RefreshToken)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+		utils.WriteError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeTokens(w, userID, refreshToken)
+}
+
+// ---- HandlerFunc for LOGOUT (REFRESH TOKEN REVOCATION) ----
+func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	var payload types.RefreshTokenPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	// Revoking every refresh token issued since the login.
+	// Already issued access tokens stay valid until they expire (`JWT_EXP`).
+	err := auth.RevokeRefreshToken(h.refreshTokenStore, payload.RefreshToken)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		utils.WriteError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "Logged out successfully",
+	})
+}
+
+// Util function to respond with a new JWT access token & the given refresh token.
+func (h *Handler) writeTokens(w http.ResponseWriter, userID int, refreshToken string) {
+	token, err := auth.CreateJWT(userID)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	// sending back the JWT authentication token once auth is completed.
+	// sending back the JWT authentication tokens once auth is completed.
 	utils.WriteJSON(w, http.StatusOK, map[string]string{
-		"token": token,
+		"token":        token,
+		"refreshToken": refreshToken,
 	})
 }
 
@@ -90,7 +174,8 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 	// 3. Checking if user already exists.
 	// 4. If not, hashing user password.
 	// 4. Create a new entry in the DB.
-	// 5. Respond with http.StatusCreated.
+	// 5. Merge the guest cart (`X-Cart-Token` header) into the new user's cart.
+	// 6. Respond with http.StatusCreated.
 
 	var payload types.RegisterUserPayload
 
@@ -142,8 +227,32 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// `CreateUser()` doesn't return the new ID, reading the user back.
+	if r.Header.Get(types.CartTokenHeader) != "" {
+		u, err := h.store.GetUserByEmail(payload.Email)
+		if err != nil {
+			log.Printf("failed to get registered user %s: %v", payload.Email, err)
+		} else {
+			h.mergeGuestCart(r, u.ID)
+		}
+	}
+
 	// Responding with http.StatusCreated.
 	utils.WriteJSON(w, http.StatusCreated, map[string]string{
 		"message": "User registered successfully",
 	})
 }
+
+// Util function merging the guest cart named by the `X-Cart-Token` header
+// (if any) into the user's cart. A failed merge doesn't fail the request,
+// the guest cart is kept and can still be merged on the next login.
+func (h *Handler) mergeGuestCart(r *http.Request, userID int) {
+	token := r.Header.Get(types.CartTokenHeader)
+	if token == "" {
+		return
+	}
+
+	if err := h.cartMerger.MergeGuestCart(r.Context(), token, userID); err != nil {
+		log.Printf("failed to merge guest cart into the cart of user %d: %v", userID, err)
+	}
+}
@@ -2,18 +2,22 @@ package user
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
 	"github.com/gitKashish/ecommerce-api-go/types"
 )
 
 func TestUserServiceHandlers(t *testing.T) {
 	userStore := &mockUserStore{}
-	handler := NewHandler(userStore)
+	refreshTokenStore := &mockRefreshTokenStore{tokens: make(map[string]*types.RefreshToken)}
+	handler := NewHandler(userStore, refreshTokenStore, &mockCartMerger{})
 
 	t.Run("should fail if the user payload is invalid", func(t *testing.T) {
 		payload := types.RegisterUserPayload{
@@ -70,10 +74,114 @@ func TestUserServiceHandlers(t *testing.T) {
 	})
 }
 
+func TestGuestCartMerge(t *testing.T) {
+	password, err := auth.HashPassword("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userStore := &mockUserStore{users: []types.User{{ID: 7, Email: "guest@gmail.com", Password: password}}}
+	cartMerger := &mockCartMerger{}
+	handler := NewHandler(userStore, &mockRefreshTokenStore{tokens: make(map[string]*types.RefreshToken)}, cartMerger)
+
+	router := http.NewServeMux()
+	handler.RegisterRoutes(router)
+
+	t.Run("should merge the guest cart on login", func(t *testing.T) {
+		marshalled, _ := json.Marshal(types.LoginUserPayload{Email: "guest@gmail.com", Password: "hello"})
+
+		req, err := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Cart-Token", "guest-token")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if userID, ok := cartMerger.merged["guest-token"]; !ok || userID != 7 {
+			t.Errorf("expected guest cart merged into user 7, got %v", cartMerger.merged)
+		}
+	})
+}
+
+func TestRefreshTokenHandlers(t *testing.T) {
+	refreshTokenStore := &mockRefreshTokenStore{tokens: make(map[string]*types.RefreshToken)}
+	handler := NewHandler(&mockUserStore{}, refreshTokenStore, &mockCartMerger{})
+
+	router := http.NewServeMux()
+	handler.RegisterRoutes(router)
+
+	send := func(path, refreshToken string) *httptest.ResponseRecorder {
+		marshalled, _ := json.Marshal(types.RefreshTokenPayload{RefreshToken: refreshToken})
+
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should rotate the refresh token and detect reuse", func(t *testing.T) {
+		first, err := auth.IssueRefreshToken(refreshTokenStore, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := send("/refresh", first)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var tokens map[string]string
+		json.NewDecoder(rr.Body).Decode(&tokens)
+		second := tokens["refreshToken"]
+		if second == "" || second == first || tokens["token"] == "" {
+			t.Fatalf("expected new access & refresh tokens, got %v", tokens)
+		}
+
+		// Replaying the first token revokes the whole family...
+		if rr := send("/refresh", first); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+
+		// ...including the token it was rotated into.
+		if rr := send("/refresh", second); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should not refresh after logout", func(t *testing.T) {
+		token, err := auth.IssueRefreshToken(refreshTokenStore, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rr := send("/logout", token); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if rr := send("/refresh", token); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
+
 type mockUserStore struct {
+	users []types.User
 }
 
 func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
 	return nil, fmt.Errorf("user not found")
 }
 
@@ -84,3 +192,58 @@ func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
 func (m *mockUserStore) CreateUser(user types.User) error {
 	return nil
 }
+
+type mockCartMerger struct {
+	merged map[string]int
+}
+
+func (m *mockCartMerger) MergeGuestCart(ctx context.Context, token string, userID int) error {
+	if m.merged == nil {
+		m.merged = make(map[string]int)
+	}
+	m.merged[token] = userID
+	return nil
+}
+
+type mockRefreshTokenStore struct {
+	tokens map[string]*types.RefreshToken
+}
+
+func (m *mockRefreshTokenStore) CreateRefreshToken(token types.RefreshToken) error {
+	token.ID = len(m.tokens) + 1
+	m.tokens[token.TokenHash] = &token
+	return nil
+}
+
+func (m *mockRefreshTokenStore) GetRefreshTokenByHash(hash string) (*types.RefreshToken, error) {
+	token, ok := m.tokens[hash]
+	if !ok {
+		return nil, auth.ErrInvalidRefreshToken
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (m *mockRefreshTokenStore) MarkRefreshTokenUsed(id int) (bool, error) {
+	for _, token := range m.tokens {
+		if token.ID == id {
+			if token.UsedAt != nil {
+				return false, nil
+			}
+			now := time.Now()
+			token.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockRefreshTokenStore) RevokeRefreshTokenFamily(familyID string) error {
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.FamilyID == familyID {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
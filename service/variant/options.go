@@ -0,0 +1,63 @@
+package variant
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Check the options of a product: names & values must be unique.
+func CheckProductOptions(options []types.ProductOption) error {
+	for i, o := range options {
+		if slices.ContainsFunc(options[:i], func(prev types.ProductOption) bool { return prev.Name == o.Name }) {
+			return fmt.Errorf("%w: option %q listed twice", types.ErrInvalidOptions, o.Name)
+		}
+		for j, value := range o.Values {
+			if slices.Contains(o.Values[:j], value) {
+				return fmt.Errorf("%w: %s %q listed twice", types.ErrInvalidOptions, o.Name, value)
+			}
+		}
+	}
+	return nil
+}
+
+// Turn the option values picked for a variant (option name -> value) into
+// the variant's options, in the product's option order.
+//
+// Rules:
+//   - every option of the product must be picked, with one of its values.
+//   - options the product doesn't have can't be picked.
+//   - no other variant (than `variantID`, 0 for a new one) of the product may
+//     pick the same values.
+//
+// Returns `types.ErrInvalidOptions` if a rule is broken.
+func ResolveOptions(options []types.ProductOption, picked map[string]string, variants []types.Variant, variantID int) ([]types.VariantOption, error) {
+	resolved := make([]types.VariantOption, 0, len(options))
+	for _, o := range options {
+		value, ok := picked[o.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing %s", types.ErrInvalidOptions, o.Name)
+		}
+		if !slices.Contains(o.Values, value) {
+			return nil, fmt.Errorf("%w: unknown %s %q", types.ErrInvalidOptions, o.Name, value)
+		}
+		resolved = append(resolved, types.VariantOption{Name: o.Name, Value: value})
+	}
+
+	if len(picked) > len(resolved) {
+		for name := range picked {
+			if !slices.ContainsFunc(options, func(o types.ProductOption) bool { return o.Name == name }) {
+				return nil, fmt.Errorf("%w: unknown option %q", types.ErrInvalidOptions, name)
+			}
+		}
+	}
+
+	for _, v := range variants {
+		if v.ID != variantID && slices.Equal(v.Options, resolved) {
+			return nil, fmt.Errorf("%w: same options as variant %s", types.ErrInvalidOptions, v.SKU)
+		}
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,82 @@
+package variant
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestCheckProductOptions(t *testing.T) {
+	t.Run("should fail if an option is listed twice", func(t *testing.T) {
+		err := CheckProductOptions([]types.ProductOption{
+			{Name: "Size", Values: []string{"S"}},
+			{Name: "Size", Values: []string{"M"}},
+		})
+
+		if !errors.Is(err, types.ErrInvalidOptions) {
+			t.Errorf("expected ErrInvalidOptions, got %v", err)
+		}
+	})
+
+	t.Run("should fail if a value is listed twice", func(t *testing.T) {
+		err := CheckProductOptions([]types.ProductOption{
+			{Name: "Size", Values: []string{"S", "M", "S"}},
+		})
+
+		if !errors.Is(err, types.ErrInvalidOptions) {
+			t.Errorf("expected ErrInvalidOptions, got %v", err)
+		}
+	})
+}
+
+func TestResolveOptions(t *testing.T) {
+	options := []types.ProductOption{
+		{Name: "Size", Values: []string{"S", "M"}},
+		{Name: "Color", Values: []string{"Red", "Blue"}},
+	}
+	variants := []types.Variant{
+		{ID: 1, SKU: "TEE-S-RED", Options: []types.VariantOption{{Name: "Size", Value: "S"}, {Name: "Color", Value: "Red"}}},
+	}
+
+	t.Run("should resolve the values in the product's option order", func(t *testing.T) {
+		resolved, err := ResolveOptions(options, map[string]string{"Color": "Blue", "Size": "M"}, variants, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(resolved) != 2 || resolved[0] != (types.VariantOption{Name: "Size", Value: "M"}) || resolved[1].Value != "Blue" {
+			t.Errorf("unexpected options %+v", resolved)
+		}
+	})
+
+	t.Run("should fail if an option is missing", func(t *testing.T) {
+		_, err := ResolveOptions(options, map[string]string{"Size": "M"}, variants, 0)
+
+		if !errors.Is(err, types.ErrInvalidOptions) {
+			t.Errorf("expected ErrInvalidOptions, got %v", err)
+		}
+	})
+
+	t.Run("should fail for unknown options & values", func(t *testing.T) {
+		for _, picked := range []map[string]string{
+			{"Size": "XL", "Color": "Red"},
+			{"Size": "M", "Color": "Red", "Fit": "Slim"},
+		} {
+			if _, err := ResolveOptions(options, picked, variants, 0); !errors.Is(err, types.ErrInvalidOptions) {
+				t.Errorf("expected ErrInvalidOptions for %v, got %v", picked, err)
+			}
+		}
+	})
+
+	t.Run("should fail if another variant has the same options", func(t *testing.T) {
+		picked := map[string]string{"Size": "S", "Color": "Red"}
+
+		if _, err := ResolveOptions(options, picked, variants, 0); !errors.Is(err, types.ErrInvalidOptions) {
+			t.Errorf("expected ErrInvalidOptions, got %v", err)
+		}
+		if _, err := ResolveOptions(options, picked, variants, 1); err != nil {
+			t.Errorf("expected the variant to keep its own options, got %v", err)
+		}
+	})
+}
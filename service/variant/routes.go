@@ -0,0 +1,332 @@
+package variant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+type Handler struct {
+	store            types.VariantStore
+	productStore     types.ProductStore
+	ledgerStore      types.StockLedgerStore
+	userStore        types.UserStore
+	idempotencyStore types.IdempotencyStore
+	transactor       types.Transactor
+}
+
+func NewHandler(store types.VariantStore, productStore types.ProductStore, ledgerStore types.StockLedgerStore, userStore types.UserStore, idempotencyStore types.IdempotencyStore, transactor types.Transactor) *Handler {
+	return &Handler{store: store, productStore: productStore, ledgerStore: ledgerStore, userStore: userStore, idempotencyStore: idempotencyStore, transactor: transactor}
+}
+
+func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /products/{id}/variants", h.handleGetVariants)
+
+	// Option & variant management, `admin` users only.
+	router.HandleFunc("PUT /products/{id}/options", auth.WithRole(idempotency.WithIdempotencyKey(h.handleReplaceOptions, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("POST /products/{id}/variants", auth.WithRole(idempotency.WithIdempotencyKey(h.handleCreateVariant, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("PUT /products/{id}/variants/{variantID}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleReplaceVariant, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("DELETE /products/{id}/variants/{variantID}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleDeleteVariant, h.idempotencyStore), h.userStore, types.RoleAdmin))
+}
+
+// HandlerFunc to get the options & variants of a product.
+func (h *Handler) handleGetVariants(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	options, err := h.store.GetProductOptions(product.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	variants, err := h.store.GetVariantsByProductID(product.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSONWithETag(w, r, http.StatusOK, types.ProductVariants{Options: options, Variants: variants})
+}
+
+// HandlerFunc to replace the options of a product (admin).
+// Options & values still used by variants can't be removed.
+func (h *Handler) handleReplaceOptions(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	var payload types.ProductOptionsPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	if payload.Options == nil {
+		payload.Options = []types.ProductOption{}
+	}
+	if err := CheckProductOptions(payload.Options); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.transactor.WithTx(r.Context(), func(tx *sql.Tx) error {
+		// Serializing option & variant changes of the product.
+		if _, err := h.productStore.GetProductByIDsForUpdate(tx, []int{product.ID}); err != nil {
+			return err
+		}
+		return h.store.SetProductOptionsTx(tx, product.ID, payload.Options)
+	})
+	if !writeStoreError(w, err) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, payload.Options)
+}
+
+// HandlerFunc to create a variant of a product (admin), along with an
+// `adjustment` movement for its initial stock.
+func (h *Handler) handleCreateVariant(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	payload, ok := parseVariantPayload(w, r)
+	if !ok {
+		return
+	}
+
+	variant := toVariant(payload, product.ID)
+	err := h.saveVariant(r.Context(), &variant, payload, auth.GetUseIDFromContext(r.Context()))
+	if !writeStoreError(w, err) {
+		return
+	}
+
+	created, err := h.store.GetVariantByID(variant.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// HandlerFunc to replace all fields of a variant (admin).
+// Setting `quantity` records an `adjustment` for the difference.
+func (h *Handler) handleReplaceVariant(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	existing, ok := h.getVariantFromPath(w, r, product.ID)
+	if !ok {
+		return
+	}
+
+	payload, ok := parseVariantPayload(w, r)
+	if !ok {
+		return
+	}
+
+	variant := toVariant(payload, product.ID)
+	variant.ID = existing.ID
+	variant.CreatedAt = existing.CreatedAt
+	err := h.saveVariant(r.Context(), &variant, payload, auth.GetUseIDFromContext(r.Context()))
+	if !writeStoreError(w, err) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, variant)
+}
+
+// Create (`ID` 0) or update the variant & set its stock to the payload's
+// quantity with an `adjustment` movement by `actorID` at the default
+// warehouse, in a single transaction. The variant's options, `ID`, `Quantity`
+// & `Available` are updated to match.
+func (h *Handler) saveVariant(ctx context.Context, variant *types.Variant, payload types.VariantPayload, actorID int) error {
+	return h.transactor.WithTx(ctx, func(tx *sql.Tx) error {
+		// Serializing option & variant changes of the product, two variants
+		// with the same options can't be saved at once.
+		if _, err := h.productStore.GetProductByIDsForUpdate(tx, []int{variant.ProductID}); err != nil {
+			return err
+		}
+
+		options, err := h.store.GetProductOptions(variant.ProductID)
+		if err != nil {
+			return err
+		}
+		variants, err := h.store.GetVariantsByProductIDsTx(tx, []int{variant.ProductID})
+		if err != nil {
+			return err
+		}
+
+		variant.Options, err = ResolveOptions(options, payload.Options, variants, variant.ID)
+		if err != nil {
+			return err
+		}
+
+		var current types.Variant
+		reason := "stock set by variant update"
+		if variant.ID == 0 {
+			reason = "initial stock"
+			variant.ID, err = h.store.CreateVariantTx(tx, *variant)
+		} else {
+			err = h.store.UpdateVariantTx(tx, *variant)
+			for _, v := range variants {
+				if v.ID == variant.ID {
+					current = v
+					break
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		delta := payload.Quantity - current.Quantity
+		if delta != 0 {
+			_, err := h.ledgerStore.RecordStockMovementTx(tx, types.StockMovement{
+				ProductID: variant.ProductID,
+				VariantID: variant.ID,
+				Type:      types.StockMovementAdjustment,
+				Delta:     delta,
+				ActorID:   actorID,
+				Reason:    reason,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		variant.Quantity = payload.Quantity
+		variant.Available = max(current.Available+delta, 0)
+		return nil
+	})
+}
+
+// HandlerFunc to delete a variant (admin).
+// Variants that were stocked or ordered can only be deactivated.
+func (h *Handler) handleDeleteVariant(w http.ResponseWriter, r *http.Request) {
+	product, ok := utils.GetProductFromPath(w, r, h.productStore)
+	if !ok {
+		return
+	}
+
+	variant, ok := h.getVariantFromPath(w, r, product.ID)
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteVariant(variant.ID)
+	switch {
+	case errors.Is(err, types.ErrVariantNotFound):
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	case errors.Is(err, types.ErrVariantInUse):
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Util function to parse & validate a variant payload.
+// Writes the error response itself and returns false if it is invalid.
+func parseVariantPayload(w http.ResponseWriter, r *http.Request) (types.VariantPayload, bool) {
+	var payload types.VariantPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return payload, false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return payload, false
+	}
+
+	return payload, true
+}
+
+// Util function mapping store errors of option & variant writes to responses.
+// Returns true if there was no error.
+func writeStoreError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, types.ErrInvalidOptions):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	case errors.Is(err, types.ErrProductNotFound), errors.Is(err, types.ErrVariantNotFound):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case errors.Is(err, types.ErrVariantSKUTaken), errors.Is(err, types.ErrOptionValueInUse), errors.Is(err, types.ErrOutOfStock):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+	return false
+}
+
+// Build the variant of product `productID` described by the payload, its
+// options are resolved separately (see `ResolveOptions()`).
+func toVariant(payload types.VariantPayload, productID int) types.Variant {
+	active := true
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+	return types.Variant{
+		ProductID: productID,
+		SKU:       payload.SKU,
+		Price:     payload.Price,
+		Image:     payload.Image,
+		Active:    active,
+	}
+}
+
+// Util function to load the variant addressed by the `{variantID}` path
+// wildcard, it must belong to product `productID`.
+// Writes the error response itself and returns false if it could not be loaded.
+func (h *Handler) getVariantFromPath(w http.ResponseWriter, r *http.Request, productID int) (*types.Variant, bool) {
+	id, err := strconv.Atoi(r.PathValue("variantID"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid variant id %q", r.PathValue("variantID")))
+		return nil, false
+	}
+
+	variant, err := h.store.GetVariantByID(id)
+	if err == nil && variant.ProductID != productID {
+		err = types.ErrVariantNotFound
+	}
+	if errors.Is(err, types.ErrVariantNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return variant, true
+}
@@ -0,0 +1,414 @@
+package variant
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Get the options of a product with their values, in display order.
+func (s *Store) GetProductOptions(productID int) ([]types.ProductOption, error) {
+	return getProductOptions(s.db, productID)
+}
+
+func getProductOptions(q db.Querier, productID int) ([]types.ProductOption, error) {
+	rows, err := q.Query(`SELECT o.name, v.value FROM product_options o
+		LEFT JOIN product_option_values v ON v.optionId = o.id
+		WHERE o.productId = ? ORDER BY o.position, o.id, v.position, v.value`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := make([]types.ProductOption, 0)
+	for rows.Next() {
+		var name string
+		var value sql.NullString
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		if len(options) == 0 || options[len(options)-1].Name != name {
+			options = append(options, types.ProductOption{Name: name, Values: []string{}})
+		}
+		if value.Valid {
+			last := &options[len(options)-1]
+			last.Values = append(last.Values, value.String)
+		}
+	}
+
+	return options, rows.Err()
+}
+
+// Replace the options of a product, as part of transaction `tx`.
+// Options & values are kept (by name) when they are still listed, so the
+// variants using them are left alone. Returns `types.ErrOptionValueInUse` if
+// a removed option or value is used by a variant and `types.ErrInvalidOptions`
+// if an option is added while the product has variants (they'd have no value for it).
+func (s *Store) SetProductOptionsTx(tx *sql.Tx, productID int, options []types.ProductOption) error {
+	existing, err := getOptionIDs(tx, productID)
+	if err != nil {
+		return err
+	}
+
+	// Removed options go first, their names may be reused below.
+	for name, optionID := range existing {
+		if slices.ContainsFunc(options, func(o types.ProductOption) bool { return o.Name == name }) {
+			continue
+		}
+		if err := checkValueInUse(tx, "optionId = ?", optionID); err != nil {
+			return fmt.Errorf("%w: option %q", err, name)
+		}
+		if _, err := tx.Exec("DELETE FROM product_options WHERE id = ?", optionID); err != nil {
+			return err
+		}
+	}
+
+	var hasVariants bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM product_variants WHERE productId = ?)", productID).Scan(&hasVariants); err != nil {
+		return err
+	}
+
+	for i, o := range options {
+		optionID, ok := existing[o.Name]
+		if ok {
+			if _, err := tx.Exec("UPDATE product_options SET position = ? WHERE id = ?", i, optionID); err != nil {
+				return err
+			}
+		} else {
+			if hasVariants {
+				return fmt.Errorf("%w: option %q can't be added to a product with variants", types.ErrInvalidOptions, o.Name)
+			}
+			res, err := tx.Exec("INSERT INTO product_options (productId, name, position) VALUES (?, ?, ?)", productID, o.Name, i)
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+				return fmt.Errorf("%w: option %q listed twice", types.ErrInvalidOptions, o.Name)
+			}
+			if err != nil {
+				return err
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			optionID = int(id)
+		}
+
+		if err := setOptionValues(tx, optionID, o.Values); err != nil {
+			return fmt.Errorf("%w: option %q", err, o.Name)
+		}
+	}
+
+	return nil
+}
+
+// Option IDs of a product by name.
+func getOptionIDs(q db.Querier, productID int) (map[string]int, error) {
+	rows, err := q.Query("SELECT id, name FROM product_options WHERE productId = ?", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		ids[name] = id
+	}
+
+	return ids, rows.Err()
+}
+
+func setOptionValues(tx *sql.Tx, optionID int, values []string) error {
+	rows, err := tx.Query("SELECT value FROM product_option_values WHERE optionId = ?", optionID)
+	if err != nil {
+		return err
+	}
+	existing := make([]string, 0)
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			rows.Close()
+			return err
+		}
+		existing = append(existing, value)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, value := range existing {
+		if slices.Contains(values, value) {
+			continue
+		}
+		if err := checkValueInUse(tx, "optionId = ? AND value = ?", optionID, value); err != nil {
+			return fmt.Errorf("%w: value %q", err, value)
+		}
+		if _, err := tx.Exec("DELETE FROM product_option_values WHERE optionId = ? AND value = ?", optionID, value); err != nil {
+			return err
+		}
+	}
+
+	for i, value := range values {
+		_, err := tx.Exec("INSERT INTO product_option_values (optionId, value, position) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE position = VALUES(position)",
+			optionID, value, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns `types.ErrOptionValueInUse` if a variant picked an option value matching `where`.
+func checkValueInUse(tx *sql.Tx, where string, args ...any) error {
+	var inUse bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM variant_option_values WHERE "+where+")", args...).Scan(&inUse); err != nil {
+		return err
+	}
+	if inUse {
+		return types.ErrOptionValueInUse
+	}
+	return nil
+}
+
+// Variant columns followed by the quantity held by active reservations
+// (see `scanRowIntoVariant()`), the `?` takes the current time.
+const variantColumns = "v.id, v.productId, v.sku, v.price, v.image, v.quantity, v.active, v.createdAt, COALESCE((SELECT SUM(r.quantity) FROM stock_reservations r WHERE r.variantId = v.id AND r.expiresAt > ?), 0)"
+
+// Get the variants of a product, oldest first.
+func (s *Store) GetVariantsByProductID(productID int) ([]types.Variant, error) {
+	return getVariants(s.db, "v.productId = ?", productID)
+}
+
+// Get the variants of the products, active or not.
+func (s *Store) GetVariantsByProductIDs(productIDs []int) ([]types.Variant, error) {
+	return getVariantsByProductIDs(s.db, productIDs)
+}
+
+// Same as `GetVariantsByProductIDs` but as part of transaction `tx`. Variant
+// quantities only change along with their product's, callers holding the
+// products' row locks read stable quantities.
+func (s *Store) GetVariantsByProductIDsTx(tx *sql.Tx, productIDs []int) ([]types.Variant, error) {
+	return getVariantsByProductIDs(tx, productIDs)
+}
+
+func getVariantsByProductIDs(q db.Querier, productIDs []int) ([]types.Variant, error) {
+	placeholders, args, ok := db.InList(productIDs)
+	if !ok {
+		return []types.Variant{}, nil
+	}
+
+	return getVariants(q, fmt.Sprintf("v.productId IN (%s)", placeholders), args...)
+}
+
+// Get a single variant by its ID.
+// Returns `types.ErrVariantNotFound` if there is no such variant.
+func (s *Store) GetVariantByID(id int) (*types.Variant, error) {
+	variants, err := getVariants(s.db, "v.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return nil, types.ErrVariantNotFound
+	}
+
+	return &variants[0], nil
+}
+
+func getVariants(q db.Querier, where string, args ...any) ([]types.Variant, error) {
+	query := fmt.Sprintf("SELECT %s FROM product_variants v WHERE %s ORDER BY v.id", variantColumns, where)
+	rows, err := q.Query(query, append([]any{time.Now()}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make([]types.Variant, 0)
+	for rows.Next() {
+		v, err := scanRowIntoVariant(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		variants = append(variants, *v)
+	}
+	// Rows are closed first, a transaction can't run another query while they are open.
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return variants, loadVariantOptions(q, variants)
+}
+
+func scanRowIntoVariant(rows *sql.Rows) (*types.Variant, error) {
+	v := new(types.Variant)
+	var price sql.NullString
+	var reserved int
+	err := rows.Scan(&v.ID, &v.ProductID, &v.SKU, &price, &v.Image, &v.Quantity, &v.Active, &v.CreatedAt, &reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	if price.Valid {
+		p, err := types.ParseMoney(price.String)
+		if err != nil {
+			return nil, err
+		}
+		v.Price = &p
+	}
+	// Lowering stock below what is reserved doesn't cancel reservations.
+	v.Available = max(v.Quantity-reserved, 0)
+	v.Options = []types.VariantOption{}
+	return v, nil
+}
+
+// Set the option values of the variants, in place, in option order.
+func loadVariantOptions(q db.Querier, variants []types.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(variants)) // Variant ID -> position in `variants`.
+	args := make([]any, len(variants))
+	for i := range variants {
+		index[variants[i].ID] = i
+		args[i] = variants[i].ID
+	}
+	placeholders := strings.Repeat(", ?", len(variants)-1)
+
+	rows, err := q.Query(fmt.Sprintf(`SELECT vo.variantId, o.name, vo.value FROM variant_option_values vo
+		JOIN product_options o ON o.id = vo.optionId
+		WHERE vo.variantId IN (?%s) ORDER BY o.position, o.id`, placeholders), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var variantID int
+		o := types.VariantOption{}
+		if err := rows.Scan(&variantID, &o.Name, &o.Value); err != nil {
+			return err
+		}
+		v := &variants[index[variantID]]
+		v.Options = append(v.Options, o)
+	}
+
+	return rows.Err()
+}
+
+// Create a new variant without stock & return its ID, as part of `tx`.
+// Stock is added through the stock ledger. Returns `types.ErrVariantSKUTaken`
+// if another variant uses the same SKU.
+func (s *Store) CreateVariantTx(tx *sql.Tx, v types.Variant) (int, error) {
+	res, err := tx.Exec("INSERT INTO product_variants (productId, sku, price, image, active) VALUES (?, ?, ?, ?, ?)",
+		v.ProductID, v.SKU, v.Price, v.Image, v.Active)
+	if err != nil {
+		return 0, mapSKUTaken(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := setVariantOptions(tx, int(id), v.ProductID, v.Options); err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Replace all fields of a variant except its quantity, as part of `tx`.
+func (s *Store) UpdateVariantTx(tx *sql.Tx, v types.Variant) error {
+	res, err := tx.Exec("UPDATE product_variants SET sku = ?, price = ?, image = ?, active = ? WHERE id = ?",
+		v.SKU, v.Price, v.Image, v.Active, v.ID)
+	if err != nil {
+		return mapSKUTaken(err)
+	}
+
+	if err := db.CheckUpdated(tx, res, "product_variants", v.ID, types.ErrVariantNotFound); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM variant_option_values WHERE variantId = ?", v.ID); err != nil {
+		return err
+	}
+	return setVariantOptions(tx, v.ID, v.ProductID, v.Options)
+}
+
+func setVariantOptions(tx *sql.Tx, variantID, productID int, options []types.VariantOption) error {
+	optionIDs, err := getOptionIDs(tx, productID)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range options {
+		optionID, ok := optionIDs[o.Name]
+		if !ok {
+			return fmt.Errorf("%w: unknown option %q", types.ErrInvalidOptions, o.Name)
+		}
+		_, err := tx.Exec("INSERT INTO variant_option_values (variantId, optionId, value) VALUES (?, ?, ?)", variantID, optionID, o.Value)
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 { // Foreign key: no such option value.
+			return fmt.Errorf("%w: unknown %s %q", types.ErrInvalidOptions, o.Name, o.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete a variant. Variants that were stocked or ordered stay in the ledger
+// & order history, they can only be deactivated (`types.ErrVariantInUse`).
+func (s *Store) DeleteVariant(id int) error {
+	res, err := s.db.Exec("DELETE FROM product_variants WHERE id = ?", id)
+	if err != nil {
+		// ER_ROW_IS_REFERENCED_2 : foreign key constraint fails.
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1451 {
+			return types.ErrVariantInUse
+		}
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return types.ErrVariantNotFound
+	}
+
+	return nil
+}
+
+// Duplicate key on the unique `sku` column.
+func mapSKUTaken(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return types.ErrVariantSKUTaken
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package warehouse
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+// Allocation strategies (`STOCK_ALLOCATION_STRATEGY`).
+const (
+	// Take each item from the warehouses in priority order.
+	StrategyPriority = "priority"
+	// Ship the whole order from the first warehouse (by priority) holding it all.
+	StrategySingle = "single"
+	// Take each item from the warehouses holding the most of it first.
+	StrategyMostStock = "most-stock"
+)
+
+// Work out the warehouses the items of an order ship from, returning the
+// allocations of each item (same order as `items`).
+//
+// Rules:
+//   - only active warehouses holding available `stock` are allocated from.
+//   - `StrategySingle` falls back to `StrategyPriority` when no warehouse
+//     holds the whole order.
+//   - without `allowSplit` the whole order ships from the first warehouse
+//     (by priority) holding it all, whatever the strategy.
+//
+// Returns `types.ErrOutOfStock` if the items can't be allocated, and an error
+// for an unknown `strategy`.
+func Allocate(items []types.CartItem, warehouses []types.Warehouse, stock []types.WarehouseStock, strategy string, allowSplit bool) ([][]types.StockAllocation, error) {
+	switch strategy {
+	case StrategyPriority, StrategySingle, StrategyMostStock:
+	default:
+		return nil, fmt.Errorf("unknown stock allocation strategy %q", strategy)
+	}
+
+	active := make([]types.Warehouse, 0, len(warehouses))
+	for _, w := range warehouses {
+		if w.Active {
+			active = append(active, w)
+		}
+	}
+
+	type key struct{ warehouseID, productID int }
+	available := make(map[key]int, len(stock))
+	for _, s := range stock {
+		available[key{s.WarehouseID, s.ProductID}] = s.Available
+	}
+
+	if strategy == StrategySingle || !allowSplit {
+		// Total quantity per product, the same product may be listed more than once.
+		needed := make(map[int]int)
+		for _, item := range items {
+			needed[item.ProductID] += item.Quantity
+		}
+
+		for _, w := range active {
+			holdsAll := true
+			for productID, quantity := range needed {
+				if available[key{w.ID, productID}] < quantity {
+					holdsAll = false
+					break
+				}
+			}
+			if !holdsAll {
+				continue
+			}
+
+			allocations := make([][]types.StockAllocation, len(items))
+			for i, item := range items {
+				allocations[i] = []types.StockAllocation{{ProductID: item.ProductID, VariantID: item.VariantID, WarehouseID: w.ID, Quantity: item.Quantity}}
+			}
+			return allocations, nil
+		}
+
+		if !allowSplit {
+			return nil, fmt.Errorf("%w: no single warehouse holds the whole order", types.ErrOutOfStock)
+		}
+	}
+
+	allocations := make([][]types.StockAllocation, len(items))
+	for i, item := range items {
+		candidates := append([]types.Warehouse(nil), active...)
+		if strategy == StrategyMostStock {
+			// Stable, warehouses holding as much keep their priority order.
+			sort.SliceStable(candidates, func(a, b int) bool {
+				return available[key{candidates[a].ID, item.ProductID}] > available[key{candidates[b].ID, item.ProductID}]
+			})
+		}
+
+		remaining := item.Quantity
+		for _, w := range candidates {
+			k := key{w.ID, item.ProductID}
+			take := min(available[k], remaining)
+			if take <= 0 {
+				continue
+			}
+
+			allocations[i] = append(allocations[i], types.StockAllocation{ProductID: item.ProductID, VariantID: item.VariantID, WarehouseID: w.ID, Quantity: take})
+			available[k] -= take
+			remaining -= take
+			if remaining == 0 {
+				break
+			}
+		}
+
+		if remaining > 0 {
+			return nil, fmt.Errorf("%w: product %d", types.ErrOutOfStock, item.ProductID)
+		}
+	}
+
+	return allocations, nil
+}
@@ -0,0 +1,81 @@
+package warehouse
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gitKashish/ecommerce-api-go/types"
+)
+
+func TestAllocate(t *testing.T) {
+	// In priority order, like `Store.GetWarehouses()` returns them.
+	warehouses := []types.Warehouse{
+		{ID: 1, Active: true},
+		{ID: 2, Active: true},
+		{ID: 3, Active: false},
+	}
+	stock := []types.WarehouseStock{
+		{WarehouseID: 1, ProductID: 1, Available: 2},
+		{WarehouseID: 2, ProductID: 1, Available: 5},
+		{WarehouseID: 2, ProductID: 2, Available: 1},
+		{WarehouseID: 3, ProductID: 1, Available: 100},
+	}
+
+	t.Run("should take items from warehouses in priority order", func(t *testing.T) {
+		got, err := Allocate([]types.CartItem{{ProductID: 1, Quantity: 3}}, warehouses, stock, StrategyPriority, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []types.StockAllocation{{ProductID: 1, WarehouseID: 1, Quantity: 2}, {ProductID: 1, WarehouseID: 2, Quantity: 1}}
+		if len(got) != 1 || len(got[0]) != 2 || got[0][0] != want[0] || got[0][1] != want[1] {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("should ship from a single warehouse holding the whole order", func(t *testing.T) {
+		items := []types.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}}
+		got, err := Allocate(items, warehouses, stock, StrategySingle, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, allocations := range got {
+			if len(allocations) != 1 || allocations[0].WarehouseID != 2 {
+				t.Errorf("expected everything from warehouse 2, got %+v", got)
+			}
+		}
+	})
+
+	t.Run("should take from the warehouse holding the most first", func(t *testing.T) {
+		got, err := Allocate([]types.CartItem{{ProductID: 1, Quantity: 1}}, warehouses, stock, StrategyMostStock, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got[0]) != 1 || got[0][0].WarehouseID != 2 {
+			t.Errorf("expected warehouse 2, got %+v", got)
+		}
+	})
+
+	t.Run("should not split orders when splitting is off", func(t *testing.T) {
+		_, err := Allocate([]types.CartItem{{ProductID: 1, Quantity: 6}}, warehouses, stock, StrategyPriority, false)
+		if !errors.Is(err, types.ErrOutOfStock) {
+			t.Errorf("expected out of stock, got %v", err)
+		}
+	})
+
+	t.Run("should not allocate from inactive warehouses", func(t *testing.T) {
+		_, err := Allocate([]types.CartItem{{ProductID: 1, Quantity: 8}}, warehouses, stock, StrategyPriority, true)
+		if !errors.Is(err, types.ErrOutOfStock) {
+			t.Errorf("expected out of stock, got %v", err)
+		}
+	})
+
+	t.Run("should reject unknown strategies", func(t *testing.T) {
+		_, err := Allocate([]types.CartItem{{ProductID: 1, Quantity: 1}}, warehouses, stock, "most_stock", true)
+		if err == nil || errors.Is(err, types.ErrOutOfStock) {
+			t.Errorf("expected unknown strategy error, got %v", err)
+		}
+	})
+}
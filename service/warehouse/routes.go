@@ -0,0 +1,155 @@
+package warehouse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gitKashish/ecommerce-api-go/service/auth"
+	"github.com/gitKashish/ecommerce-api-go/service/idempotency"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/gitKashish/ecommerce-api-go/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+type Handler struct {
+	store            types.WarehouseStore
+	userStore        types.UserStore
+	idempotencyStore types.IdempotencyStore
+}
+
+func NewHandler(store types.WarehouseStore, userStore types.UserStore, idempotencyStore types.IdempotencyStore) *Handler {
+	return &Handler{store: store, userStore: userStore, idempotencyStore: idempotencyStore}
+}
+
+// Warehouse management, `admin` users only. Stock is moved in & out of
+// warehouses through the stock ledger (see `inventory.Handler`).
+func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /warehouses", auth.WithRole(h.handleGetWarehouses, h.userStore, types.RoleAdmin))
+	router.HandleFunc("POST /warehouses", auth.WithRole(idempotency.WithIdempotencyKey(h.handleCreateWarehouse, h.idempotencyStore), h.userStore, types.RoleAdmin))
+	router.HandleFunc("GET /warehouses/{id}", auth.WithRole(h.handleGetWarehouse, h.userStore, types.RoleAdmin))
+	router.HandleFunc("PUT /warehouses/{id}", auth.WithRole(idempotency.WithIdempotencyKey(h.handleReplaceWarehouse, h.idempotencyStore), h.userStore, types.RoleAdmin))
+}
+
+// HandlerFunc to list all warehouses in allocation order (admin).
+func (h *Handler) handleGetWarehouses(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := h.store.GetWarehouses()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, warehouses)
+}
+
+// HandlerFunc to get a single warehouse (admin).
+func (h *Handler) handleGetWarehouse(w http.ResponseWriter, r *http.Request) {
+	warehouse, ok := h.getWarehouseFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, warehouse)
+}
+
+// HandlerFunc to create a warehouse (admin).
+func (h *Handler) handleCreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	payload, ok := parseWarehousePayload(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := h.store.CreateWarehouse(payload.ToWarehouse())
+	if !writeStoreError(w, err) {
+		return
+	}
+
+	warehouse, err := h.store.GetWarehouseByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, warehouse)
+}
+
+// HandlerFunc to replace all fields of a warehouse (admin).
+// Set `active` to false to stop allocating orders to a warehouse, its stock stays.
+func (h *Handler) handleReplaceWarehouse(w http.ResponseWriter, r *http.Request) {
+	existing, ok := h.getWarehouseFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	payload, ok := parseWarehousePayload(w, r)
+	if !ok {
+		return
+	}
+
+	warehouse := payload.ToWarehouse()
+	warehouse.ID = existing.ID
+	warehouse.CreatedAt = existing.CreatedAt
+
+	if !writeStoreError(w, h.store.UpdateWarehouse(warehouse)) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, warehouse)
+}
+
+// Util function to parse & validate a warehouse payload.
+// Writes the error response itself and returns false if it is invalid.
+func parseWarehousePayload(w http.ResponseWriter, r *http.Request) (types.WarehousePayload, bool) {
+	var payload types.WarehousePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return payload, false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return payload, false
+	}
+
+	return payload, true
+}
+
+// Util function mapping store errors of create & update to responses.
+// Returns true if there was no error.
+func writeStoreError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, types.ErrWarehouseNotFound):
+		utils.WriteError(w, http.StatusNotFound, err)
+	case errors.Is(err, types.ErrWarehouseCodeTaken):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+	return false
+}
+
+// Util function to load the warehouse addressed by the `{id}` path wildcard.
+// Writes the error response itself and returns false if it could not be loaded.
+func (h *Handler) getWarehouseFromPath(w http.ResponseWriter, r *http.Request) (*types.Warehouse, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid warehouse id %q", r.PathValue("id")))
+		return nil, false
+	}
+
+	warehouse, err := h.store.GetWarehouseByID(id)
+	if errors.Is(err, types.ErrWarehouseNotFound) {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return warehouse, true
+}
@@ -0,0 +1,147 @@
+package warehouse
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gitKashish/ecommerce-api-go/db"
+	"github.com/gitKashish/ecommerce-api-go/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const warehouseColumns = "id, code, name, priority, active, createdAt"
+
+// Get all warehouses in allocation order: by priority, then ID.
+func (s *Store) GetWarehouses() ([]types.Warehouse, error) {
+	return getWarehouses(s.db, "SELECT "+warehouseColumns+" FROM warehouses ORDER BY priority, id")
+}
+
+// Get a single warehouse by its ID.
+// Returns `types.ErrWarehouseNotFound` if there is no such warehouse.
+func (s *Store) GetWarehouseByID(id int) (*types.Warehouse, error) {
+	warehouses, err := getWarehouses(s.db, "SELECT "+warehouseColumns+" FROM warehouses WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(warehouses) == 0 {
+		return nil, types.ErrWarehouseNotFound
+	}
+
+	return &warehouses[0], nil
+}
+
+func getWarehouses(q db.Querier, query string, args ...any) ([]types.Warehouse, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	warehouses := make([]types.Warehouse, 0)
+	for rows.Next() {
+		w := types.Warehouse{}
+		if err := rows.Scan(&w.ID, &w.Code, &w.Name, &w.Priority, &w.Active, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, w)
+	}
+
+	return warehouses, rows.Err()
+}
+
+// Create a new warehouse and return its ID, it starts without stock.
+// Returns `types.ErrWarehouseCodeTaken` if another warehouse uses the same code.
+func (s *Store) CreateWarehouse(w types.Warehouse) (int, error) {
+	res, err := s.db.Exec("INSERT INTO warehouses (code, name, priority, active) VALUES (?, ?, ?, ?)",
+		w.Code, w.Name, w.Priority, w.Active)
+	if err != nil {
+		return 0, mapCodeTaken(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Replace all fields of a warehouse.
+func (s *Store) UpdateWarehouse(w types.Warehouse) error {
+	res, err := s.db.Exec("UPDATE warehouses SET code = ?, name = ?, priority = ?, active = ? WHERE id = ?",
+		w.Code, w.Name, w.Priority, w.Active, w.ID)
+	if err != nil {
+		return mapCodeTaken(err)
+	}
+
+	return db.CheckUpdated(s.db, res, "warehouses", w.ID, types.ErrWarehouseNotFound)
+}
+
+// Duplicate key on the unique `code` column.
+func mapCodeTaken(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return types.ErrWarehouseCodeTaken
+	}
+	return err
+}
+
+// Get the stock of the products at each warehouse holding any.
+// Active reservations of orders (other than `orderID`) at the warehouse
+// aren't `Available`, carts reserve stock across all warehouses.
+func (s *Store) GetProductStock(productIDs []int, orderID int) ([]types.WarehouseStock, error) {
+	return getProductStock(s.db, productIDs, orderID, false)
+}
+
+// Same as `GetProductStock` but locks the stock rows until `tx` ends.
+// Callers hold the products' row locks, new reservations of them wait for `tx`.
+func (s *Store) GetProductStockForUpdate(tx *sql.Tx, productIDs []int, orderID int) ([]types.WarehouseStock, error) {
+	return getProductStock(tx, productIDs, orderID, true)
+}
+
+func getProductStock(q db.Querier, productIDs []int, orderID int, forUpdate bool) ([]types.WarehouseStock, error) {
+	placeholders, ids, ok := db.InList(productIDs)
+	if !ok {
+		return []types.WarehouseStock{}, nil
+	}
+	args := append([]any{time.Now(), orderID}, ids...)
+
+	query := fmt.Sprintf(`SELECT s.warehouseId, s.productId, s.quantity,
+		COALESCE((SELECT SUM(r.quantity) FROM stock_reservations r
+			WHERE r.warehouseId = s.warehouseId AND r.productId = s.productId
+			AND r.expiresAt > ? AND NOT (r.orderId <=> ?)), 0)
+		FROM warehouse_stock s WHERE s.productId IN (%s)
+		ORDER BY s.warehouseId, s.productId`, placeholders)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stock := make([]types.WarehouseStock, 0)
+	for rows.Next() {
+		ws := types.WarehouseStock{}
+		var reserved int
+		if err := rows.Scan(&ws.WarehouseID, &ws.ProductID, &ws.Quantity, &reserved); err != nil {
+			return nil, err
+		}
+		ws.Available = max(ws.Quantity-reserved, 0)
+		stock = append(stock, ws)
+	}
+
+	return stock, rows.Err()
+}
@@ -0,0 +1,267 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Money columns are `DECIMAL(10,2)`, amounts are kept in minor units (cents)
+// so totals add up exactly the way MySQL stores them.
+const (
+	moneyScale = 2
+	moneyUnit  = 100
+	// Largest amount a `DECIMAL(10,2)` column holds (99999999.99).
+	MaxMoneyAmount = 99999999_99
+)
+
+// An amount of money in minor units of `Currency` (ISO 4217 code).
+//
+// Rounding rules, whenever more than 2 decimals show up they are rounded
+// half away from zero, which is what MySQL does when a value is stored in
+// a `DECIMAL(10,2)` column:
+//   - parsing (`ParseMoney()`, JSON & DB values) rounds to the cent.
+//   - `Mul()` & `Add()` are exact.
+//   - `MulRatio()` (percentages, taxes...) rounds the result once.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// Currency of all amounts stored in the DB, "USD" until the commands set
+// the configured one (`CURRENCY`) with `SetDefaultCurrency()` on startup.
+var defaultCurrency = "USD"
+
+func SetDefaultCurrency(code string) {
+	defaultCurrency = code
+}
+
+func DefaultCurrency() string {
+	return defaultCurrency
+}
+
+// Create an amount in minor units of the default currency.
+func NewMoney(amount int64) Money {
+	return Money{Amount: amount, Currency: DefaultCurrency()}
+}
+
+// Parse a decimal string (e.g. "12.5", "-0.125") in the default currency,
+// rounding half away from zero to the cent.
+func ParseMoney(s string) (Money, error) {
+	amount, err := parseMinorUnits(s)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(amount), nil
+}
+
+func parseMinorUnits(s string) (int64, error) {
+	invalid := fmt.Errorf("invalid amount %q", s)
+
+	s = strings.TrimSpace(s)
+	// A single sign at most, "-+5" or "--5" are not amounts.
+	negative := strings.HasPrefix(s, "-")
+	if negative || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, invalid
+	}
+	for _, part := range []string{whole, frac} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, invalid
+		}
+	}
+
+	// Digit right after the cents decides the rounding, the rest can't change it.
+	roundUp := len(frac) > moneyScale && frac[moneyScale] >= '5'
+	if len(frac) > moneyScale {
+		frac = frac[:moneyScale]
+	}
+	frac += strings.Repeat("0", moneyScale-len(frac))
+
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, invalid
+	}
+
+	if roundUp {
+		units++
+	}
+	if negative {
+		units = -units
+	}
+	return units, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Sum of two amounts, both must be in the same currency.
+func (m Money) Add(o Money) Money {
+	m.mustMatch(o)
+	return Money{Amount: m.Amount + o.Amount, Currency: m.currency(o)}
+}
+
+// Difference of two amounts, both must be in the same currency.
+func (m Money) Sub(o Money) Money {
+	m.mustMatch(o)
+	return Money{Amount: m.Amount - o.Amount, Currency: m.currency(o)}
+}
+
+// Amount times a quantity, exact.
+func (m Money) Mul(quantity int) Money {
+	return Money{Amount: m.Amount * int64(quantity), Currency: m.Currency}
+}
+
+// Amount times `num / den` rounded half away from zero to the cent.
+// E.g. 15% of an amount is `MulRatio(15, 100)`.
+func (m Money) MulRatio(num, den int64) Money {
+	if den == 0 {
+		panic("money: division by zero")
+	}
+	product := m.Amount * num
+	q, r := product/den, product%den
+	// |remainder| * 2 >= |den| means half or more, away from zero.
+	if 2*abs(r) >= abs(den) {
+		if (product < 0) != (den < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+	return Money{Amount: q, Currency: m.Currency}
+}
+
+// Smallest of two amounts in the same currency.
+func (m Money) Min(o Money) Money {
+	m.mustMatch(o)
+	if o.Amount < m.Amount {
+		return o
+	}
+	return m
+}
+
+// Decimal form with exactly two places, e.g. "12.50".
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/moneyUnit, amount%moneyUnit)
+}
+
+// Encoded as `{"amount": "12.50", "currency": "USD"}`, the amount is a string
+// so clients don't parse it into a float either.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.String(), m.currency(m)})
+}
+
+// Accepts the encoded object (amount as string or number) or a bare
+// number / string amount in the default currency, e.g. `"price": 12.5`.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = []byte(strings.TrimSpace(string(data)))
+	if string(data) == "null" {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		var v struct {
+			Amount   json.RawMessage `json:"amount"`
+			Currency string          `json:"currency"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if err := m.UnmarshalJSON(v.Amount); err != nil {
+			return err
+		}
+		if v.Currency != "" && !strings.EqualFold(v.Currency, m.Currency) {
+			return fmt.Errorf("unsupported currency %q, expected %s", v.Currency, m.Currency)
+		}
+		return nil
+	}
+
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// `sql.Scanner`, reads a `DECIMAL(10,2)` column (in the default currency).
+func (m *Money) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		*m = NewMoney(v * moneyUnit)
+		return nil
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// `driver.Valuer`, amounts are sent as decimal strings so nothing goes through a float.
+func (m Money) Value() (driver.Value, error) {
+	if abs(m.Amount) > MaxMoneyAmount {
+		return nil, fmt.Errorf("amount %s out of DECIMAL(10,2) range", m)
+	}
+	if m.Currency != "" && m.Currency != DefaultCurrency() {
+		return nil, fmt.Errorf("cannot store %s amount, store currency is %s", m.Currency, DefaultCurrency())
+	}
+	return m.String(), nil
+}
+
+// A zero value `Money{}` has no currency yet, it takes the other operand's.
+func (m Money) currency(o Money) string {
+	switch {
+	case m.Currency != "":
+		return m.Currency
+	case o.Currency != "":
+		return o.Currency
+	default:
+		return DefaultCurrency()
+	}
+}
+
+// Mixing currencies is a programming error, amounts are never converted.
+func (m Money) mustMatch(o Money) {
+	if m.Currency != "" && o.Currency != "" && m.Currency != o.Currency {
+		panic(fmt.Sprintf("money: currency mismatch %s != %s", m.Currency, o.Currency))
+	}
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
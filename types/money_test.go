@@ -0,0 +1,163 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	// Expected values are what MySQL stores for the input in a `DECIMAL(10,2)` column.
+	cases := map[string]string{
+		"12":      "12.00",
+		"12.5":    "12.50",
+		"0.1":     "0.10",
+		".5":      "0.50",
+		"1.":      "1.00",
+		"19.994":  "19.99",
+		"19.995":  "20.00",
+		"19.9951": "20.00",
+		"0.005":   "0.01",
+		"0.0049":  "0.00",
+		"-0.005":  "-0.01",
+		"-19.994": "-19.99",
+		"99.999":  "100.00",
+	}
+
+	for in, want := range cases {
+		t.Run("should parse "+in, func(t *testing.T) {
+			m, err := ParseMoney(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.String() != want {
+				t.Errorf("expected %s, got %s", want, m)
+			}
+		})
+	}
+
+	for _, in := range []string{"", "-", ".", "abc", "1.2.3", "1e3", "--1", "-+5", "+-5", "++5", "1,5"} {
+		t.Run("should reject "+in, func(t *testing.T) {
+			if _, err := ParseMoney(in); err == nil {
+				t.Errorf("expected an error for %q", in)
+			}
+		})
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	t.Run("should add exactly where floats don't", func(t *testing.T) {
+		a, _ := ParseMoney("0.1")
+		b, _ := ParseMoney("0.2")
+		if got := a.Add(b).String(); got != "0.30" {
+			t.Errorf("expected 0.30, got %s", got)
+		}
+
+		total := NewMoney(0)
+		for range 10 {
+			total = total.Add(a)
+		}
+		if total.String() != "1.00" {
+			t.Errorf("expected 1.00, got %s", total)
+		}
+	})
+
+	t.Run("should match DECIMAL(10,2) line totals", func(t *testing.T) {
+		// SELECT CAST(19.99 AS DECIMAL(10,2)) * 3 => 59.97
+		price, _ := ParseMoney("19.99")
+		if got := price.Mul(3).String(); got != "59.97" {
+			t.Errorf("expected 59.97, got %s", got)
+		}
+	})
+
+	t.Run("should round ratios half away from zero", func(t *testing.T) {
+		cases := []struct {
+			amount   string
+			num, den int64
+			want     string
+		}{
+			{"19.99", 15, 100, "3.00"}, // 2.9985
+			{"0.05", 1, 2, "0.03"},     // 0.025
+			{"0.05", -1, 2, "-0.03"},   // -0.025
+			{"10.00", 1, 3, "3.33"},    // 3.333...
+			{"10.00", 2, 3, "6.67"},    // 6.666...
+		}
+		for _, c := range cases {
+			m, _ := ParseMoney(c.amount)
+			if got := m.MulRatio(c.num, c.den).String(); got != c.want {
+				t.Errorf("%s * %d/%d: expected %s, got %s", c.amount, c.num, c.den, c.want, got)
+			}
+		}
+	})
+
+	t.Run("should panic on currency mismatch", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		Money{Amount: 1, Currency: "USD"}.Add(Money{Amount: 1, Currency: "EUR"})
+	})
+}
+
+func TestMoneyJSON(t *testing.T) {
+	t.Run("should encode amount as a string", func(t *testing.T) {
+		b, err := json.Marshal(NewMoney(1250))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"amount":"12.50","currency":"` + DefaultCurrency() + `"}`
+		if string(b) != want {
+			t.Errorf("expected %s, got %s", want, b)
+		}
+	})
+
+	t.Run("should decode numbers, strings & objects", func(t *testing.T) {
+		for _, in := range []string{`12.5`, `"12.50"`, `{"amount":"12.5"}`, `{"amount":12.5,"currency":"` + DefaultCurrency() + `"}`} {
+			var m Money
+			if err := json.Unmarshal([]byte(in), &m); err != nil {
+				t.Fatalf("%s: %v", in, err)
+			}
+			if m != NewMoney(1250) {
+				t.Errorf("%s: expected 12.50, got %s", in, m)
+			}
+		}
+	})
+
+	t.Run("should reject other currencies", func(t *testing.T) {
+		var m Money
+		if err := json.Unmarshal([]byte(`{"amount":"1","currency":"XXX"}`), &m); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestMoneySQL(t *testing.T) {
+	t.Run("should scan DECIMAL columns", func(t *testing.T) {
+		var m Money
+		if err := m.Scan([]byte("12.30")); err != nil {
+			t.Fatal(err)
+		}
+		if m != NewMoney(1230) {
+			t.Errorf("expected 12.30, got %s", m)
+		}
+	})
+
+	t.Run("should store decimal strings", func(t *testing.T) {
+		v, err := NewMoney(-5).Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != "-0.05" {
+			t.Errorf("expected -0.05, got %v", v)
+		}
+	})
+
+	t.Run("should reject amounts DECIMAL(10,2) can't hold", func(t *testing.T) {
+		if _, err := NewMoney(MaxMoneyAmount + 1).Value(); err == nil {
+			t.Error("expected an error")
+		}
+		if _, err := NewMoney(MaxMoneyAmount).Value(); err != nil {
+			t.Error(err)
+		}
+	})
+}
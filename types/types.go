@@ -1,6 +1,16 @@
 package types
 
-import "time"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
 
 type User struct {
 	ID        int       `json:"id"`
@@ -9,8 +19,15 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  string    `json:"password"`
 	CreatedAt time.Time `json:"createAt"`
+	Role      string    `json:"role"`
 }
 
+// User roles, stored in `users.role`.
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
 type UserStore interface {
 	GetUserByEmail(email string) (*User, error)
 	GetUserByID(id int) (*User, error)
@@ -29,58 +46,1146 @@ type LoginUserPayload struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// Server side record of an issued refresh token, only its hash is stored.
+// Every rotation issues a new token in the same family, so a replayed
+// (already used) token can revoke all tokens descending from the same login.
+type RefreshToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"userID"`
+	FamilyID  string     `json:"familyID"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+	RevokedAt *time.Time `json:"revokedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type RefreshTokenStore interface {
+	CreateRefreshToken(RefreshToken) error
+	GetRefreshTokenByHash(hash string) (*RefreshToken, error)
+	// Marks the token as used, returns false if it was already used.
+	MarkRefreshTokenUsed(id int) (bool, error)
+	RevokeRefreshTokenFamily(familyID string) error
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
 type Product struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Image       string    `json:"image"`
-	Price       float64   `json:"price"`
-	Quantity    int       `json:"quantity"`
+	ID int `json:"id"`
+	// Unique, bulk imports match products by it. Products created without
+	// one get `DefaultProductSKU()`. Separate from the SKUs of the product's variants.
+	SKU         string `json:"sku"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+	Price       Money  `json:"price"`
+	Quantity    int    `json:"quantity"`
+	// Available to sell: on hand (`Quantity`) minus active reservations.
+	Available int `json:"available"`
+	// Categories the product is listed in & its tags (lower case, sorted).
+	CategoryIDs []int     `json:"categoryIDs"`
+	Tags        []string  `json:"tags"`
 	CreatedAt   time.Time `json:"createdAt"`
 }
 
+// SKU of a product created without one, e.g. "P-42".
+func DefaultProductSKU(id int) string {
+	return "P-" + strconv.Itoa(id)
+}
+
+// Used for creating (POST) and replacing (PUT) a product.
+// Replacing without a `SKU` keeps the product's SKU.
 type RegisterProductPayload struct {
-	Name        string  `json:"name" validate:"required"`
-	Description string  `json:"description" validate:"required"`
-	Image       string  `json:"image" validate:"required"`
-	Price       float64 `json:"price" validate:"required"`
-	Quantity    int     `json:"quantity" validate:"required"`
+	SKU         string `json:"sku" validate:"omitempty,max=64,printascii,excludes= "`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description" validate:"required"`
+	Image       string `json:"image" validate:"required"`
+	Price       Money  `json:"price" validate:"required,gt=0"`
+	Quantity    int    `json:"quantity" validate:"gte=0"`
+	// Replace the product's categories & tags, none if left out.
+	CategoryIDs []int    `json:"categoryIDs" validate:"dive,gt=0"`
+	Tags        []string `json:"tags" validate:"dive,required,max=64"`
+}
+
+// Used for partially updating (PATCH) a product, `nil` fields are left unchanged.
+type UpdateProductPayload struct {
+	SKU         *string   `json:"sku" validate:"omitempty,min=1,max=64,printascii,excludes= "`
+	Name        *string   `json:"name" validate:"omitempty,min=1"`
+	Description *string   `json:"description" validate:"omitempty,min=1"`
+	Image       *string   `json:"image" validate:"omitempty,min=1"`
+	Price       *Money    `json:"price" validate:"omitempty,gt=0"`
+	Quantity    *int      `json:"quantity" validate:"omitempty,gte=0"`
+	CategoryIDs *[]int    `json:"categoryIDs" validate:"omitempty,dive,gt=0"`
+	Tags        *[]string `json:"tags" validate:"omitempty,dive,required,max=64"`
+}
+
+// File formats of bulk product imports & exports.
+const (
+	ProductFileCSV   = "csv"
+	ProductFileJSONL = "jsonl"
+)
+
+// A row of a bulk product import or export, matched to existing products by
+// `SKU`. Importing creates the product if no product has the SKU (`Name`,
+// `Description`, `Image` & `Price` are required then) and otherwise updates
+// it, `nil` fields are left unchanged.
+type ProductRecord struct {
+	SKU         string    `json:"sku" validate:"required,max=64,printascii,excludes= "`
+	Name        *string   `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Description *string   `json:"description,omitempty" validate:"omitempty,min=1"`
+	Image       *string   `json:"image,omitempty" validate:"omitempty,min=1,max=255"`
+	Price       *Money    `json:"price,omitempty" validate:"omitempty,gt=0"`
+	Quantity    *int      `json:"quantity,omitempty" validate:"omitempty,gte=0"`
+	CategoryIDs *[]int    `json:"categoryIDs,omitempty" validate:"omitempty,dive,gt=0"`
+	Tags        *[]string `json:"tags,omitempty" validate:"omitempty,dive,required,max=64"`
+}
+
+// Outcome of a bulk product import. On a dry run nothing is saved, the
+// counts & errors are what the import would have done.
+type ImportReport struct {
+	DryRun  bool `json:"dryRun"`
+	Rows    int  `json:"rows"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	Failed  int  `json:"failed"`
+	// The first `MaxImportErrors` failed rows, `Failed` counts them all.
+	Errors []ImportError `json:"errors"`
+}
+
+// Failed rows listed in an `ImportReport`, beyond it they are only counted.
+const MaxImportErrors = 1000
+
+// A row that was not imported, `Row` is its line in the file.
+type ImportError struct {
+	Row   int    `json:"row"`
+	SKU   string `json:"sku,omitempty"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+var (
+	ErrUnknownFileFormat = errors.New("unknown file format, expected csv or jsonl")
+	ErrInvalidColumns    = errors.New("invalid columns")
+)
+
+// Sort orders accepted by `ProductStore.GetProductsWithOptions()`.
+const (
+	ProductSortNewest    = "newest"
+	ProductSortOldest    = "oldest"
+	ProductSortPriceAsc  = "price_asc"
+	ProductSortPriceDesc = "price_desc"
+	ProductSortNameAsc   = "name_asc"
+	ProductSortNameDesc  = "name_desc"
+)
+
+// Filtering, sorting & (cursor based) pagination options for listing products.
+// Zero values mean "no filter".
+type ProductQueryOptions struct {
+	Limit        int
+	Cursor       string // `ProductPage.NextCursor` of the previous page.
+	Sort         string // One of ProductSort*, defaults to ProductSortNewest.
+	MinPrice     *Money
+	MaxPrice     *Money
+	InStockOnly  bool
+	CreatedAfter *time.Time
+	NameContains string
+	Category     string // Category slug, products in its subcategories match too.
+	Tag          string
+	// Variant option name to value, products with an active variant having
+	// all of them match.
+	Attributes map[string]string
+	// Only these products (e.g. search matches), nil for no restriction. Any
+	// number of IDs works, they are sent as a single query argument.
+	IDs []int
+}
+
+// Whether any filter is set, paging & sorting options aside.
+func (opts ProductQueryOptions) HasFilters() bool {
+	return opts.MinPrice != nil || opts.MaxPrice != nil || opts.InStockOnly ||
+		opts.CreatedAfter != nil || opts.NameContains != "" || opts.Category != "" ||
+		opts.Tag != "" || len(opts.Attributes) > 0 || opts.IDs != nil
 }
 
+// A tag & the number of products carrying it.
+type TagCount struct {
+	Tag      string `json:"tag"`
+	Products int    `json:"products"`
+}
+
+// A single page of products.
+// `NextCursor` is empty on the last page, `Total` counts all matching products.
+// `Facets` are only set when asked for.
+type ProductPage struct {
+	Products   []Product      `json:"products"`
+	NextCursor string         `json:"nextCursor"`
+	Total      int            `json:"total"`
+	Facets     *ProductFacets `json:"facets,omitempty"`
+}
+
+// Product counts of the values of each filter, over all products matching
+// the current filters. Values without products are left out.
+type ProductFacets struct {
+	Categories   []CategoryFacet   `json:"categories"`
+	Prices       []PriceBucket     `json:"prices"`
+	Availability AvailabilityFacet `json:"availability"`
+	Tags         []TagCount        `json:"tags"`
+	Attributes   []AttributeFacet  `json:"attributes"`
+}
+
+// Products count those of the category's subcategories, like the category filter.
+type CategoryFacet struct {
+	ID       int    `json:"id"`
+	ParentID *int   `json:"parentID"`
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	Products int    `json:"products"`
+}
+
+// Products priced from `Min` up to, but not including, `Max` (nil for no upper bound).
+type PriceBucket struct {
+	Min      Money  `json:"min"`
+	Max      *Money `json:"max"`
+	Products int    `json:"products"`
+}
+
+type AvailabilityFacet struct {
+	InStock    int `json:"inStock"`
+	OutOfStock int `json:"outOfStock"`
+}
+
+// A variant option (e.g. "Color") & the products having an active variant with each value.
+type AttributeFacet struct {
+	Name   string       `json:"name"`
+	Values []FacetValue `json:"values"`
+}
+
+type FacetValue struct {
+	Value    string `json:"value"`
+	Products int    `json:"products"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrProductInUse    = errors.New("product is referenced by existing orders")
+	ErrOutOfStock      = errors.New("not enough stock")
+	ErrProductSKUTaken = errors.New("product sku already exists")
+)
+
 type ProductStore interface {
 	GetProducts() ([]Product, error)
+	GetProductsWithOptions(opts ProductQueryOptions) (*ProductPage, error)
+	GetProductByID(id int) (*Product, error)
 	GetProductByIDs(ps []int) ([]Product, error)
+	// Returns `ErrProductNotFound` if no product has the SKU.
+	GetProductBySKU(sku string) (*Product, error)
+	// Products are created without stock & `UpdateProduct` leaves the quantity
+	// alone, stock only changes through the ledger (see `StockLedgerStore`).
+	// Products created without a SKU get `DefaultProductSKU()`.
+	// Both return `ErrProductSKUTaken` if another product has the same SKU.
+	RegisterProduct(Product) (int, error)
 	UpdateProduct(Product) error
+	DeleteProduct(id int) error
+	// Transaction-aware variants, rows read with `ForUpdate` stay locked until the tx ends.
+	GetProductByIDsForUpdate(tx *sql.Tx, ps []int) ([]Product, error)
+	RegisterProductTx(tx *sql.Tx, p Product) (int, error)
+	UpdateProductTx(tx *sql.Tx, p Product) error
+	// Replace the product's categories or tags.
+	SetProductCategoriesTx(tx *sql.Tx, productID int, categoryIDs []int) error
+	SetProductTagsTx(tx *sql.Tx, productID int, tags []string) error
+	// All tags in use, most used first.
+	GetTags() ([]TagCount, error)
+	// Facet counts & IDs of the products matching the filters of `opts`,
+	// paging & sorting options are ignored.
+	GetProductFacets(opts ProductQueryOptions) (*ProductFacets, error)
+	GetProductIDs(opts ProductQueryOptions) ([]int, error)
+}
+
+// An uploaded product image. Its files (the original & resized variants) are
+// kept in a `BlobStorage` under `Key`, `URLs` point at them.
+type ProductImage struct {
+	ID        int    `json:"id"`
+	ProductID int    `json:"productID"`
+	Position  int    `json:"position"`
+	Alt       string `json:"alt"`
+	// Sniffed type & size of the original.
+	ContentType string           `json:"contentType"`
+	Width       int              `json:"width"`
+	Height      int              `json:"height"`
+	Key         string           `json:"-"`
+	URLs        ProductImageURLs `json:"urls"`
+	CreatedAt   time.Time        `json:"createdAt"`
+}
+
+// Resized variants fit in a square (see `media.ImageSizes`), never upscaled.
+type ProductImageURLs struct {
+	Original      string `json:"original"`
+	Medium        string `json:"medium"`
+	MediumWebP    string `json:"mediumWebp"`
+	Thumbnail     string `json:"thumbnail"`
+	ThumbnailWebP string `json:"thumbnailWebp"`
 }
 
+// Used for changing (PATCH) an image, the file itself can't be replaced.
+type UpdateProductImagePayload struct {
+	Alt string `json:"alt" validate:"max=255"`
+}
+
+// Used for reordering a product's images, lists all of them, first one is the primary image.
+type ReorderProductImagesPayload struct {
+	ImageIDs []int `json:"imageIDs" validate:"required,min=1,dive,gt=0"`
+}
+
+var (
+	ErrImageNotFound    = errors.New("image not found")
+	ErrImageTooLarge    = errors.New("image too large")
+	ErrUnsupportedImage = errors.New("unsupported image type, expected JPEG, PNG, GIF or WebP")
+	ErrInvalidImageList = errors.New("image IDs must list every image of the product once")
+)
+
+type ProductImageStore interface {
+	// Images of the product, in display order.
+	GetProductImages(productID int) ([]ProductImage, error)
+	GetProductImage(productID, id int) (*ProductImage, error)
+	// Add an image after the product's other images, returns its ID.
+	CreateProductImage(ProductImage) (int, error)
+	UpdateProductImage(ProductImage) error
+	DeleteProductImage(productID, id int) error
+	// Set the positions of the product's images to the order of `ids`.
+	ReorderProductImagesTx(tx *sql.Tx, productID int, ids []int) error
+	// Set `products.image`, kept pointing at the primary (first) image.
+	SetPrimaryImage(productID int, url string) error
+}
+
+// Stores files by key ("products/1/ab12/thumb.webp"), pluggable so images can
+// live on local disk or an object store.
+type BlobStorage interface {
+	Put(ctx context.Context, key, contentType string, r io.Reader) error
+	// No error if there is no such file.
+	Delete(ctx context.Context, key string) error
+	// Public URL the file is served at.
+	URL(key string) string
+}
+
+// Keeps a search index in sync with product writes, see `search.Index`.
+type ProductIndex interface {
+	IndexProduct(Product)
+	RemoveProduct(id int)
+}
+
+// A product matching a search query, `Highlights` hold its matched text with
+// the matching words wrapped in `<mark>` tags (HTML escaped otherwise).
+type SearchHit struct {
+	Product    Product          `json:"product"`
+	Score      float64          `json:"score"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+// `Description` is a snippet around the first match, empty if only the name matched.
+type SearchHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// A single page of search hits, best match first.
+// `NextCursor` is empty on the last page, `Total` counts all matching products.
+// `Facets` are only set when asked for.
+type SearchPage struct {
+	Hits       []SearchHit    `json:"hits"`
+	NextCursor string         `json:"nextCursor"`
+	Total      int            `json:"total"`
+	Facets     *ProductFacets `json:"facets,omitempty"`
+}
+
+// An autocomplete suggestion, `Highlight` is the product's name with the
+// typed prefix wrapped in `<mark>` tags.
+type Suggestion struct {
+	ProductID int    `json:"productID"`
+	Name      string `json:"name"`
+	Highlight string `json:"highlight"`
+}
+
+// Order statuses, see `order.CanTransition()` for the allowed changes.
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusFulfilled = "fulfilled"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// A row of `order_status_history`.
+// `FromStatus` is empty for the order's creation, `ChangedBy` is 0 for system changes.
+type OrderStatusChange struct {
+	ID         int       `json:"id"`
+	OrderID    int       `json:"orderID"`
+	FromStatus string    `json:"fromStatus"`
+	ToStatus   string    `json:"toStatus"`
+	ChangedBy  int       `json:"changedBy"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type UpdateOrderStatusPayload struct {
+	Status string `json:"status" validate:"required"`
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+type CancelOrderPayload struct {
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+var (
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrIllegalTransition   = errors.New("illegal order status transition")
+	ErrOrderNotCancellable = errors.New("order can no longer be cancelled")
+)
+
 type OrderStore interface {
 	CreateOrder(Order) (int, error)
 	CreateOrderItem(OrderItem) error
+	// Orders of a user, newest first, with IDs below `beforeID` (0 for the first page).
+	GetOrdersByUserID(userID, limit, beforeID int) ([]Order, error)
+	GetOrderByID(id int) (*Order, error)
+	GetOrderItemsByOrderID(orderID int) ([]OrderItem, error)
+	GetOrderStatusHistory(orderID int) ([]OrderStatusChange, error)
+	GetOrderDiscounts(orderID int) ([]OrderDiscount, error)
+	// Transaction-aware lifecycle methods, the order row stays locked until the tx ends.
+	GetOrderByIDForUpdate(tx *sql.Tx, id int) (*Order, error)
+	GetOrderItemsByOrderIDTx(tx *sql.Tx, orderID int) ([]OrderItem, error)
+	UpdateOrderStatusTx(tx *sql.Tx, id int, status string) error
+	CreateOrderStatusChangeTx(tx *sql.Tx, change OrderStatusChange) error
+	SetOrderStockCommittedTx(tx *sql.Tx, id int) error
+	// Transaction-aware variants.
+	CreateOrderTx(tx *sql.Tx, o Order) (int, error)
+	CreateOrderItemTx(tx *sql.Tx, oi OrderItem) error
+	CreateOrderDiscountTx(tx *sql.Tx, d OrderDiscount) error
+}
+
+// Runs `fn` inside a single DB transaction, committing only if it returns nil.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error
 }
 
 type Order struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"userID"`
-	Total     float64   `json:"total"`
-	Status    string    `json:"status"`
-	Address   string    `json:"address"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID     int `json:"id"`
+	UserID int `json:"userID"`
+	// `Total` is `Subtotal` (sum of the items) minus `Discount` (coupons)
+	// plus `Shipping` & `Tax`.
+	Subtotal Money  `json:"subtotal"`
+	Discount Money  `json:"discount"`
+	Shipping Money  `json:"shipping"`
+	Tax      Money  `json:"tax"`
+	Total    Money  `json:"total"`
+	Status   string `json:"status"`
+	// Whether the ordered quantities were taken off stock. Until the order
+	// is paid they are only reserved (see `StockReservation`).
+	StockCommitted bool   `json:"stockCommitted"`
+	Address        string `json:"address"`
+	// Shipping (`Address`) & billing address snapshots taken at checkout (see `Address.Format()`).
+	BillingAddress string    `json:"billingAddress"`
+	CreatedAt      time.Time `json:"createdAt"`
 }
 
+// `ProductName` & `ProductImage` are copied from the product at checkout...
+// so later product edits don't rewrite order history.
 type OrderItem struct {
+	ID        int `json:"id"`
+	OrderID   int `json:"orderID"`
+	ProductID int `json:"productID"`
+	// Variant ordered & its SKU, for products with variants.
+	VariantID int    `json:"variantID,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	// Warehouse the item ships from, an ordered product may be split across warehouses.
+	WarehouseID  int       `json:"warehouseID,omitempty"`
+	Quantity     int       `json:"quantity"`
+	Price        Money     `json:"price"`
+	ProductName  string    `json:"productName"`
+	ProductImage string    `json:"productImage"`
+	CreateAt     time.Time `json:"createdAt"`
+}
+
+// A coupon applied to an order at checkout.
+// `Code` is copied from the coupon, `Amount` is what it took off the order.
+type OrderDiscount struct {
 	ID        int       `json:"id"`
 	OrderID   int       `json:"orderID"`
-	ProductID int       `json:"productID"`
-	Quantity  int       `json:"quantity"`
-	Price     float64   `json:"price"`
-	CreateAt  time.Time `json:"createdAt"`
+	CouponID  int       `json:"couponID"`
+	Code      string    `json:"code"`
+	Amount    Money     `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// An order along with its line items.
+type OrderDetail struct {
+	Order
+	Items     []OrderItem         `json:"items"`
+	Discounts []OrderDiscount     `json:"discounts"`
+	History   []OrderStatusChange `json:"history"`
 }
 
+// A single page of a user's orders.
+// `NextCursor` is empty on the last page.
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"nextCursor"`
+}
+
+// `VariantID` is required for products with variants, 0 for the others.
 type CartItem struct {
 	ProductID int `json:"productID"`
+	VariantID int `json:"variantID,omitempty"`
 	Quantity  int `json:"quantity"`
 }
 
+// Either reference a saved address (`AddressID`) or send one inline (`Address`).
+// Without both the user's default shipping address is used. The billing
+// address is the user's default billing address, falling back to shipping.
+// Without `Items` the user's saved cart is checked out (and emptied).
 type CartCheckoutPayload struct {
-	Items []CartItem `json:"items" validate:"required"`
+	Items       []CartItem      `json:"items"`
+	AddressID   int             `json:"addressID" validate:"gte=0"`
+	Address     *AddressPayload `json:"address"`
+	CouponCodes []string        `json:"couponCodes" validate:"max=5,dive,required,max=64"`
+}
+
+// Used for pricing a cart without checking out (`POST /cart/quote`).
+// Without `Items` the user's saved cart is priced.
+type CartQuotePayload struct {
+	Items       []CartItem `json:"items"`
+	CouponCodes []string   `json:"couponCodes" validate:"max=5,dive,required,max=64"`
+}
+
+// A priced cart item of a quote. `Warning` explains why it can't be
+// bought as is, e.g. not enough stock (`Available` units left).
+type CartQuoteLine struct {
+	ProductID int    `json:"productID"`
+	VariantID int    `json:"variantID,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Money  `json:"unitPrice"`
+	LineTotal Money  `json:"lineTotal"`
+	Available int    `json:"available"`
+	Warning   string `json:"warning,omitempty"`
+}
+
+// What checking out the cart would cost right now, nothing is reserved.
+// `Purchasable` is false if checkout would fail, `Warnings` say why.
+type CartQuote struct {
+	Lines       []CartQuoteLine `json:"lines"`
+	Subtotal    Money           `json:"subtotal"`
+	Discounts   []OrderDiscount `json:"discounts"`
+	Discount    Money           `json:"discount"`
+	Shipping    Money           `json:"shipping"`
+	Tax         Money           `json:"tax"`
+	Total       Money           `json:"total"`
+	Purchasable bool            `json:"purchasable"`
+	Warnings    []string        `json:"warnings"`
+}
+
+// A server side cart, kept across sessions & devices.
+// Guest carts have no `UserID`, they are named by an opaque token instead
+// (only its hash is stored, `Token` is only set when the cart is created).
+type Cart struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"userID"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Guest carts only, extended on every use.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// An item of a saved cart. `PriceAtAdd` is the product's price when it was
+// added, checkout always charges the current price.
+type CartLine struct {
+	ProductID  int       `json:"productID"`
+	VariantID  int       `json:"variantID,omitempty"`
+	Quantity   int       `json:"quantity"`
+	PriceAtAdd Money     `json:"priceAtAdd"`
+	AddedAt    time.Time `json:"addedAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// A saved cart along with its quote (current prices, discounts & warnings).
+// `PriceChanged` lists the products whose price changed since they were added.
+type CartView struct {
+	Cart
+	Items        []CartLine `json:"items"`
+	PriceChanged []int      `json:"priceChanged"`
+	Quote        *CartQuote `json:"quote"`
+}
+
+// Used for adding a product to the saved cart, adds to the quantity already in it.
+type AddCartItemPayload struct {
+	ProductID int `json:"productID" validate:"required,gt=0"`
+	VariantID int `json:"variantID" validate:"gte=0"`
+	Quantity  int `json:"quantity" validate:"required,gt=0"`
+}
+
+// Used for changing the quantity of a product in the saved cart.
+type UpdateCartItemPayload struct {
+	Quantity int `json:"quantity" validate:"required,gt=0"`
+}
+
+var (
+	ErrCartNotFound     = errors.New("cart not found")
+	ErrCartItemNotFound = errors.New("product is not in the cart")
+)
+
+type CartStore interface {
+	// Get the user's cart, creating an empty one on first use.
+	GetCartByUserID(userID int) (*Cart, error)
+	CreateGuestCart(tokenHash string, expiresAt time.Time) (*Cart, error)
+	// Expired guest carts are not found.
+	GetCartByTokenHash(tokenHash string) (*Cart, error)
+	ExtendGuestCart(cartID int, expiresAt time.Time) error
+	// Delete expired guest carts along with their items & reservations, returns how many.
+	DeleteExpiredGuestCarts() (int, error)
+	GetCartLines(cartID int) ([]CartLine, error)
+	// Locks the cart's items until `tx` ends, so checkout empties exactly what it ordered.
+	GetCartLinesForUpdate(tx *sql.Tx, cartID int) ([]CartLine, error)
+	// Items are keyed by product & variant (0 for products without variants).
+	AddCartItem(cartID, productID, variantID, quantity int, price Money) error
+	UpdateCartItem(cartID, productID, variantID, quantity int) error
+	RemoveCartItem(cartID, productID, variantID int) error
+	ClearCartTx(tx *sql.Tx, cartID int) error
+	// Used when merging carts: set an item as is & drop the merged (guest) cart.
+	SetCartItemTx(tx *sql.Tx, cartID int, line CartLine) error
+	DeleteCartTx(tx *sql.Tx, cartID int) error
+}
+
+// Header naming a guest cart. Sent back on the request creating the cart,
+// clients send it with every cart request & on login / registration.
+const CartTokenHeader = "X-Cart-Token"
+
+// Merges a guest cart (named by its token) into a user's cart on login & registration.
+type CartMerger interface {
+	MergeGuestCart(ctx context.Context, token string, userID int) error
+}
+
+// A saved address of a user (address book).
+type Address struct {
+	ID                int       `json:"id"`
+	UserID            int       `json:"userID"`
+	FullName          string    `json:"fullName"`
+	Line1             string    `json:"line1"`
+	Line2             string    `json:"line2"`
+	City              string    `json:"city"`
+	State             string    `json:"state"`
+	PostalCode        string    `json:"postalCode"`
+	Country           string    `json:"country"`
+	Phone             string    `json:"phone"`
+	IsDefaultShipping bool      `json:"isDefaultShipping"`
+	IsDefaultBilling  bool      `json:"isDefaultBilling"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// Render the address as the multi-line text snapshot stored on orders.
+func (a Address) Format() string {
+	lines := []string{a.FullName, a.Line1}
+	if a.Line2 != "" {
+		lines = append(lines, a.Line2)
+	}
+	lines = append(lines, strings.TrimSpace(strings.Join([]string{a.City, a.State, a.PostalCode}, " ")), a.Country)
+	if a.Phone != "" {
+		lines = append(lines, a.Phone)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Used for creating (POST) and replacing (PUT) an address, also accepted inline at checkout.
+type AddressPayload struct {
+	FullName          string `json:"fullName" validate:"required,max=255"`
+	Line1             string `json:"line1" validate:"required,max=255"`
+	Line2             string `json:"line2" validate:"max=255"`
+	City              string `json:"city" validate:"required,max=255"`
+	State             string `json:"state" validate:"max=255"`
+	PostalCode        string `json:"postalCode" validate:"required,max=32"`
+	Country           string `json:"country" validate:"required,iso3166_1_alpha2_ci"`
+	Phone             string `json:"phone" validate:"max=32"`
+	IsDefaultShipping bool   `json:"isDefaultShipping"`
+	IsDefaultBilling  bool   `json:"isDefaultBilling"`
+}
+
+// Build an `Address` of the user from the payload.
+func (p AddressPayload) ToAddress(userID int) Address {
+	return Address{
+		UserID:            userID,
+		FullName:          p.FullName,
+		Line1:             p.Line1,
+		Line2:             p.Line2,
+		City:              p.City,
+		State:             p.State,
+		PostalCode:        p.PostalCode,
+		Country:           strings.ToUpper(p.Country),
+		Phone:             p.Phone,
+		IsDefaultShipping: p.IsDefaultShipping,
+		IsDefaultBilling:  p.IsDefaultBilling,
+	}
+}
+
+var ErrAddressNotFound = errors.New("address not found")
+
+// Setting a default address clears the flag on the user's other addresses.
+type AddressStore interface {
+	GetAddressesByUserID(userID int) ([]Address, error)
+	GetAddressByID(id int) (*Address, error)
+	CreateAddress(Address) (int, error)
+	UpdateAddress(Address) error
+	DeleteAddress(id int) error
+}
+
+// Coupon types, stored in `coupons.type`.
+const (
+	CouponTypePercentage = "percentage"
+	CouponTypeFixed      = "fixed"
+)
+
+// A discount code redeemable at checkout.
+//
+// `PercentOff` (percentage coupons) or `AmountOff` (fixed coupons) is taken off
+// the items the coupon applies to: the whole cart, or only `ProductIDs` &
+// the products of `CategoryIDs` (or their subcategories) if either is set.
+// Those items must add up to at least `MinSpend`. `nil` limits & validity
+// bounds mean unlimited. A coupon that isn't `Stackable` must be used alone.
+type Coupon struct {
+	ID           int        `json:"id"`
+	Code         string     `json:"code"`
+	Description  string     `json:"description"`
+	Type         string     `json:"type"`
+	PercentOff   int        `json:"percentOff"`
+	AmountOff    Money      `json:"amountOff"`
+	MinSpend     Money      `json:"minSpend"`
+	ProductIDs   []int      `json:"productIDs"`
+	CategoryIDs  []int      `json:"categoryIDs"`
+	UsageLimit   *int       `json:"usageLimit"`
+	PerUserLimit *int       `json:"perUserLimit"`
+	StartsAt     *time.Time `json:"startsAt"`
+	EndsAt       *time.Time `json:"endsAt"`
+	Stackable    bool       `json:"stackable"`
+	Active       bool       `json:"active"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	// Products in `CategoryIDs` or their subcategories, loaded by the store.
+	CategoryProductIDs []int `json:"-"`
+}
+
+// Used for creating (POST) and replacing (PUT) a coupon.
+// `Active` defaults to true.
+type CouponPayload struct {
+	Code         string     `json:"code" validate:"required,max=64,printascii,excludes= "`
+	Description  string     `json:"description" validate:"max=255"`
+	Type         string     `json:"type" validate:"required,oneof=percentage fixed"`
+	PercentOff   int        `json:"percentOff" validate:"gte=0,lte=100"`
+	AmountOff    Money      `json:"amountOff" validate:"gte=0"`
+	MinSpend     Money      `json:"minSpend" validate:"gte=0"`
+	ProductIDs   []int      `json:"productIDs" validate:"dive,gt=0"`
+	CategoryIDs  []int      `json:"categoryIDs" validate:"dive,gt=0"`
+	UsageLimit   *int       `json:"usageLimit" validate:"omitempty,gt=0"`
+	PerUserLimit *int       `json:"perUserLimit" validate:"omitempty,gt=0"`
+	StartsAt     *time.Time `json:"startsAt"`
+	EndsAt       *time.Time `json:"endsAt"`
+	Stackable    bool       `json:"stackable"`
+	Active       *bool      `json:"active"`
+}
+
+// Build a `Coupon` from the payload, codes are case-insensitive (stored upper case).
+func (p CouponPayload) ToCoupon() Coupon {
+	c := Coupon{
+		Code:         strings.ToUpper(p.Code),
+		Description:  p.Description,
+		Type:         p.Type,
+		PercentOff:   p.PercentOff,
+		AmountOff:    p.AmountOff,
+		MinSpend:     p.MinSpend,
+		ProductIDs:   p.ProductIDs,
+		CategoryIDs:  p.CategoryIDs,
+		UsageLimit:   p.UsageLimit,
+		PerUserLimit: p.PerUserLimit,
+		StartsAt:     p.StartsAt,
+		EndsAt:       p.EndsAt,
+		Stackable:    p.Stackable,
+		Active:       p.Active == nil || *p.Active,
+	}
+	if c.ProductIDs == nil {
+		c.ProductIDs = []int{}
+	}
+	if c.CategoryIDs == nil {
+		c.CategoryIDs = []int{}
+	}
+	return c
+}
+
+var (
+	ErrCouponNotFound  = errors.New("coupon not found")
+	ErrCouponCodeTaken = errors.New("coupon code already exists")
+	// A coupon that exists but can't be redeemed on this cart.
+	ErrInvalidCoupon = errors.New("invalid coupon")
+)
+
+type CouponStore interface {
+	GetCoupons() ([]Coupon, error)
+	GetCouponByID(id int) (*Coupon, error)
+	CreateCoupon(Coupon) (int, error)
+	UpdateCoupon(Coupon) error
+	// Coupons with the given codes, unknown codes are left out.
+	GetCouponsByCodes(codes []string) ([]Coupon, error)
+	// Locks the coupons (`SELECT ... FOR UPDATE`) so usage limits hold under concurrent checkouts.
+	GetCouponsByCodesForUpdate(tx *sql.Tx, codes []string) ([]Coupon, error)
+	// Times the coupon was redeemed, overall & by the user. Cancelled orders don't count.
+	GetCouponUsage(couponID, userID int) (total, byUser int, err error)
+	// Same count inside checkout's `tx`, taken after locking the coupon so it can't go stale.
+	GetCouponUsageTx(tx *sql.Tx, couponID, userID int) (total, byUser int, err error)
+}
+
+// Stored outcome of a request made with an `Idempotency-Key` header.
+// `StatusCode` is 0 while the first request is still in flight.
+type IdempotencyKey struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"userID"`
+	Key         string `json:"key"`
+	RequestHash string `json:"requestHash"`
+	StatusCode  int    `json:"statusCode"`
+	// Nil for responses stored before headers were.
+	ResponseHeaders http.Header `json:"responseHeaders"`
+	ResponseBody    []byte      `json:"responseBody"`
+	CreatedAt       time.Time   `json:"createdAt"`
+}
+
+type IdempotencyStore interface {
+	// Claims `key` for the user. If the key was already claimed the existing
+	// record is returned along with `created = false`.
+	ReserveIdempotencyKey(userID int, key, requestHash string) (record *IdempotencyKey, created bool, err error)
+	SaveIdempotencyResponse(userID int, key string, statusCode int, header http.Header, body []byte) error
+	DeleteIdempotencyKey(userID int, key string) error
+}
+
+// Stock held for a cart (before checkout) or a pending order (until it is paid).
+// Reserved quantities aren't available to others until the reservation
+// expires, is released or becomes a stock decrement once the order is paid.
+type StockReservation struct {
+	ID        int `json:"id"`
+	ProductID int `json:"productID"`
+	// Reservations of a variant hold the product's stock too.
+	VariantID int `json:"variantID,omitempty"`
+	CartID    int `json:"cartID,omitempty"`
+	OrderID   int `json:"orderID,omitempty"`
+	// Orders reserve stock at the warehouse they were allocated to, carts across all.
+	WarehouseID int       `json:"warehouseID,omitempty"`
+	Quantity    int       `json:"quantity"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type ReservationStore interface {
+	GetCartReservations(cartID int) ([]StockReservation, error)
+	// Quantities held by active reservations, by product. Reservations of
+	// `cartID` & `orderID` (0 for none) are left out, they don't hold stock
+	// against their own cart or order.
+	GetReservedQuantities(productIDs []int, cartID, orderID int) (map[int]int, error)
+	// Same, with the reservations locked so the sums hold until `tx` ends.
+	GetReservedQuantitiesTx(tx *sql.Tx, productIDs []int, cartID, orderID int) (map[int]int, error)
+	// Same, by variant.
+	GetReservedVariantQuantities(variantIDs []int, cartID, orderID int) (map[int]int, error)
+	GetReservedVariantQuantitiesTx(tx *sql.Tx, variantIDs []int, cartID, orderID int) (map[int]int, error)
+	CreateReservationTx(tx *sql.Tx, r StockReservation) error
+	DeleteCartReservationsTx(tx *sql.Tx, cartID int) error
+	DeleteOrderReservationsTx(tx *sql.Tx, orderID int) error
+	// Deletes reservations that expired, returning how many were deleted.
+	DeleteExpiredReservations() (int, error)
+}
+
+// Stock movement types.
+const (
+	StockMovementSale       = "sale"
+	StockMovementRestock    = "restock" // Cancelled order.
+	StockMovementAdjustment = "adjustment"
+	StockMovementReturn     = "return"
+	StockMovementImport     = "import"
+)
+
+// Entry of the append-only stock ledger. A product's quantity is the sum of
+// its movements' `Delta`, `Balance` is that sum right after the movement.
+// Movements with a `VariantID` move the variant's quantity too.
+type StockMovement struct {
+	ID          int    `json:"id"`
+	ProductID   int    `json:"productID"`
+	VariantID   int    `json:"variantID,omitempty"`
+	WarehouseID int    `json:"warehouseID"`
+	Type        string `json:"type"`
+	Delta       int    `json:"delta"`
+	// The product's quantity across all warehouses.
+	Balance int `json:"balance"`
+	OrderID int `json:"orderID,omitempty"`
+	// User behind the movement, 0 for movements not made by a user.
+	ActorID   int       `json:"actorID,omitempty"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type StockMovementPage struct {
+	Movements  []StockMovement `json:"movements"`
+	NextCursor string          `json:"nextCursor"`
+}
+
+// Manual movement posted by an admin. Sales & restocks are recorded by the
+// order lifecycle, imports by bulk imports.
+type StockMovementPayload struct {
+	Type  string `json:"type" validate:"required,oneof=adjustment return"`
+	Delta int    `json:"delta" validate:"required"`
+	// 0 for the default warehouse.
+	WarehouseID int    `json:"warehouseID" validate:"gte=0"`
+	VariantID   int    `json:"variantID" validate:"gte=0"`
+	OrderID     int    `json:"orderID" validate:"gte=0"`
+	Reason      string `json:"reason" validate:"required,max=255"`
+}
+
+// A product whose quantity doesn't match the sum of its movements,
+// e.g. after the `products` table was edited by hand. With `WarehouseID`
+// it's the product's stock at that warehouse, with `VariantID` the variant's
+// quantity, otherwise the product's total quantity.
+type StockDrift struct {
+	ProductID      int `json:"productID"`
+	WarehouseID    int `json:"warehouseID,omitempty"`
+	VariantID      int `json:"variantID,omitempty"`
+	Quantity       int `json:"quantity"`
+	LedgerQuantity int `json:"ledgerQuantity"`
+}
+
+type StockLedgerStore interface {
+	// Movements of a product, newest first, with IDs below `beforeID` (0 for the first page).
+	GetStockMovements(productID, limit, beforeID int) ([]StockMovement, error)
+	// Append a movement and apply its delta to the product's quantity.
+	// Returns `ErrProductNotFound`, or `ErrOutOfStock` if the quantity would drop below 0.
+	RecordStockMovement(m StockMovement) (*StockMovement, error)
+	RecordStockMovementTx(tx *sql.Tx, m StockMovement) (*StockMovement, error)
+	GetStockDrift() ([]StockDrift, error)
+	// Reset drifted quantities to the sum of their movements, returning the drift fixed.
+	ReconcileStock() ([]StockDrift, error)
+}
+
+// Warehouse created by the migrations, holding the stock from before
+// warehouses existed. Movements without a warehouse apply to it.
+const DefaultWarehouseID = 1
+
+// A location stock is held at. Checkout allocates from active warehouses
+// in `Priority` order (lowest first), see `warehouse.Allocate()`.
+type Warehouse struct {
+	ID        int       `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Priority  int       `json:"priority"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Used for creating (POST) and replacing (PUT) a warehouse.
+// `Active` defaults to true.
+type WarehousePayload struct {
+	Code     string `json:"code" validate:"required,max=32,printascii,excludes= "`
+	Name     string `json:"name" validate:"required,max=255"`
+	Priority int    `json:"priority" validate:"gte=0"`
+	Active   *bool  `json:"active"`
+}
+
+// Build a `Warehouse` from the payload, codes are stored lower case.
+func (p WarehousePayload) ToWarehouse() Warehouse {
+	return Warehouse{
+		Code:     strings.ToLower(p.Code),
+		Name:     p.Name,
+		Priority: p.Priority,
+		Active:   p.Active == nil || *p.Active,
+	}
+}
+
+// Stock of a product at a warehouse. `Available` leaves out what is
+// reserved there for unpaid orders.
+type WarehouseStock struct {
+	WarehouseID int `json:"warehouseID"`
+	ProductID   int `json:"productID"`
+	Quantity    int `json:"quantity"`
+	Available   int `json:"available"`
+}
+
+// Quantity of a product (variant) taken from a warehouse for an order item.
+type StockAllocation struct {
+	ProductID   int `json:"productID"`
+	VariantID   int `json:"variantID,omitempty"`
+	WarehouseID int `json:"warehouseID"`
+	Quantity    int `json:"quantity"`
+}
+
+var (
+	ErrWarehouseNotFound  = errors.New("warehouse not found")
+	ErrWarehouseCodeTaken = errors.New("warehouse code already exists")
+)
+
+type WarehouseStore interface {
+	// All warehouses, in allocation order (priority, then ID).
+	GetWarehouses() ([]Warehouse, error)
+	GetWarehouseByID(id int) (*Warehouse, error)
+	CreateWarehouse(Warehouse) (int, error)
+	UpdateWarehouse(Warehouse) error
+	// Stock of the products at each warehouse holding any. Reservations of
+	// `orderID` (0 for none) don't count against `Available`.
+	GetProductStock(productIDs []int, orderID int) ([]WarehouseStock, error)
+	// Same, with the stock rows & reservations locked until `tx` ends.
+	GetProductStockForUpdate(tx *sql.Tx, productIDs []int, orderID int) ([]WarehouseStock, error)
+}
+
+// A node of the category tree. `ParentID` is `nil` for top level categories,
+// siblings are ordered by `Position`, then name.
+type Category struct {
+	ID          int        `json:"id"`
+	ParentID    *int       `json:"parentID"`
+	Name        string     `json:"name"`
+	Slug        string     `json:"slug"`
+	Description string     `json:"description"`
+	Position    int        `json:"position"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	Children    []Category `json:"children,omitempty"`
+}
+
+// Used for creating (POST) and replacing (PUT) a category.
+// `Slug` defaults to the name, see `Slugify()`.
+type CategoryPayload struct {
+	ParentID    *int   `json:"parentID" validate:"omitempty,gt=0"`
+	Name        string `json:"name" validate:"required,max=255"`
+	Slug        string `json:"slug" validate:"max=128"`
+	Description string `json:"description" validate:"max=1000"`
+	Position    int    `json:"position" validate:"gte=0"`
+}
+
+// Build a `Category` from the payload. The slug may end up empty if neither
+// it nor the name has any letters or digits.
+func (p CategoryPayload) ToCategory() Category {
+	slug := p.Slug
+	if slug == "" {
+		slug = p.Name
+	}
+	return Category{
+		ParentID:    p.ParentID,
+		Name:        p.Name,
+		Slug:        Slugify(slug),
+		Description: p.Description,
+		Position:    p.Position,
+	}
+}
+
+// Lower case `s`, keeping letters & digits and joining the words in between
+// with dashes, e.g. "Men's T-Shirts" becomes "men-s-t-shirts".
+func Slugify(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
+
+var (
+	ErrCategoryNotFound  = errors.New("category not found")
+	ErrCategorySlugTaken = errors.New("category slug already exists")
+	ErrCategoryCycle     = errors.New("a category can't be moved under itself or its subcategories")
+	ErrCategoryInUse     = errors.New("category has subcategories or is used by coupons")
+)
+
+type CategoryStore interface {
+	// All categories (flat), siblings in display order.
+	GetCategories() ([]Category, error)
+	GetCategoryByID(id int) (*Category, error)
+	GetCategoryBySlug(slug string) (*Category, error)
+	CreateCategory(Category) (int, error)
+	UpdateCategory(Category) error
+	// Products are taken out of a deleted category, they aren't deleted.
+	DeleteCategory(id int) error
+}
+
+// An option type of a product (e.g. size) & the values its variants pick from.
+type ProductOption struct {
+	Name   string   `json:"name" validate:"required,max=64"`
+	Values []string `json:"values" validate:"required,min=1,dive,required,max=64"`
+}
+
+// Used for replacing (PUT) the options of a product, in display order.
+type ProductOptionsPayload struct {
+	Options []ProductOption `json:"options" validate:"dive"`
+}
+
+// The value a variant picks for an option.
+type VariantOption struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// A purchasable version of a product, one value for each of its options
+// (in option order). `Price` overrides the product's price when set, an empty
+// `Image` means the product's image. `Quantity` only changes through the
+// stock ledger, `Available` leaves out active reservations.
+type Variant struct {
+	ID        int             `json:"id"`
+	ProductID int             `json:"productID"`
+	SKU       string          `json:"sku"`
+	Price     *Money          `json:"price"`
+	Image     string          `json:"image"`
+	Options   []VariantOption `json:"options"`
+	Quantity  int             `json:"quantity"`
+	Available int             `json:"available"`
+	Active    bool            `json:"active"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// Option values joined for display, e.g. "M / Red".
+func (v Variant) Title() string {
+	values := make([]string, len(v.Options))
+	for i, o := range v.Options {
+		values[i] = o.Value
+	}
+	return strings.Join(values, " / ")
+}
+
+// The options of a product along with its variants.
+type ProductVariants struct {
+	Options  []ProductOption `json:"options"`
+	Variants []Variant       `json:"variants"`
+}
+
+// Used for creating (POST) and replacing (PUT) a variant.
+// `Options` maps each option name of the product to one of its values.
+// `Active` defaults to true, `Quantity` sets the stock like for products.
+type VariantPayload struct {
+	SKU      string            `json:"sku" validate:"required,max=64,printascii,excludes= "`
+	Price    *Money            `json:"price" validate:"omitempty,gt=0"`
+	Image    string            `json:"image" validate:"max=255"`
+	Options  map[string]string `json:"options" validate:"required"`
+	Quantity int               `json:"quantity" validate:"gte=0"`
+	Active   *bool             `json:"active"`
+}
+
+var (
+	ErrVariantNotFound  = errors.New("variant not found")
+	ErrVariantSKUTaken  = errors.New("variant sku already exists")
+	ErrVariantRequired  = errors.New("product has variants, a variant must be chosen")
+	ErrVariantInUse     = errors.New("variant has stock movements or orders, deactivate it instead")
+	ErrInvalidOptions   = errors.New("invalid variant options")
+	ErrOptionValueInUse = errors.New("option value is used by variants")
+)
+
+type VariantStore interface {
+	GetProductOptions(productID int) ([]ProductOption, error)
+	// Replace the options of a product, values still used by variants can't be removed.
+	SetProductOptionsTx(tx *sql.Tx, productID int, options []ProductOption) error
+	GetVariantsByProductID(productID int) ([]Variant, error)
+	// Variants of the products, active or not.
+	GetVariantsByProductIDs(productIDs []int) ([]Variant, error)
+	GetVariantsByProductIDsTx(tx *sql.Tx, productIDs []int) ([]Variant, error)
+	GetVariantByID(id int) (*Variant, error)
+	CreateVariantTx(tx *sql.Tx, v Variant) (int, error)
+	// Replace all fields of a variant except its quantity (see `StockLedgerStore`).
+	UpdateVariantTx(tx *sql.Tx, v Variant) error
+	DeleteVariant(id int) error
 }
@@ -1,14 +1,34 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 
+	"github.com/gitKashish/ecommerce-api-go/types"
 	"github.com/go-playground/validator/v10"
 )
 
-var Validate = validator.New()
+var Validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// Money is validated by its minor units, so `gt=0` means "more than 0.00".
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(types.Money).Amount
+	}, types.Money{})
+	// ISO 3166-1 alpha-2 code in any case, stored uppercased (`types.AddressPayload.ToAddress()`).
+	v.RegisterValidation("iso3166_1_alpha2_ci", func(fl validator.FieldLevel) bool {
+		return v.Var(strings.ToUpper(fl.Field().String()), "iso3166_1_alpha2") == nil
+	})
+	return v
+}
 
 func ParseJSON(r *http.Request, payload any) error {
 	if r.Body == nil {
@@ -26,3 +46,68 @@ func WriteJSON(w http.ResponseWriter, status int, v any) error {
 func WriteError(w http.ResponseWriter, status int, err error) {
 	WriteJSON(w, status, map[string]string{"error": err.Error()})
 }
+
+// Same as `WriteJSON()` but tags the response with a strong `ETag` (hash of the body)...
+// and responds with http.StatusNotModified (no body) if the request's
+// `If-None-Match` header already matches it.
+func WriteJSONWithETag(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	body = append(body, '\n') // Same output as `json.Encoder`.
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// `If-None-Match` uses weak comparison (RFC 9110 13.1.2)...
+// so `W/"x"` matches `"x"`.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Load the product addressed by the `{id}` path wildcard, shared by the
+// handlers of product sub-resources (images, variants, stock...).
+// Writes the error response itself and returns false if it could not be loaded.
+func GetProductFromPath(w http.ResponseWriter, r *http.Request, store types.ProductStore) (*types.Product, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id %q", r.PathValue("id")))
+		return nil, false
+	}
+
+	product, err := store.GetProductByID(id)
+	if errors.Is(err, types.ErrProductNotFound) {
+		WriteError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return product, true
+}